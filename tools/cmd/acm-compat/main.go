@@ -0,0 +1,67 @@
+// Command acm-compat checks every policy against the ACM releases a mixed
+// fleet runs: the template functions and context fields its hub and spoke
+// templates use, and the policy kinds, fields and field values it sets (an
+// OperatorPolicy, spec.customMessage, spec.hubTemplateOptions, ...), against
+// the release that added them.
+//
+//	cd tools
+//	go run ./cmd/acm-compat -acm 2.12,2.14
+//
+// A spoke runs the governance add-on of the hub that manages it, so the
+// releases to check are those of the hubs. -values is the valuesObject the
+// autoshift chart passes Helm policies; without it they render with their own
+// defaults. The exit status is 1 when anything is reported.
+//
+// Needs helm and kustomize, like TestPipeline_EndToEnd.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/resolver"
+)
+
+func main() {
+	repo := flag.String("repo", "..", "repository root")
+	versions := flag.String("acm", "", "comma-separated ACM releases to check, e.g. 2.12,2.14")
+	values := flag.String("values", "", "values file Helm policies render with (the autoshift chart's valuesObject)")
+	flag.Parse()
+
+	n, err := run(*repo, *versions, *values)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "acm-compat:", err)
+		os.Exit(1)
+	}
+	if n > 0 {
+		os.Exit(1)
+	}
+}
+
+func run(repo, versions, values string) (int, error) {
+	if versions == "" {
+		return 0, fmt.Errorf("no ACM release given (-acm)")
+	}
+	dep := resolver.DefaultDeployment()
+	dep.ValuesFile = values
+	n := 0
+	for _, s := range strings.Split(versions, ",") {
+		v, err := resolver.ParseACMVersion(s)
+		if err != nil {
+			return 0, err
+		}
+		problems, err := resolver.CheckACMPolicies(filepath.Join(repo, "policies"), dep, v)
+		if err != nil {
+			return 0, err
+		}
+		for _, p := range problems {
+			fmt.Printf("ACM %s: %s\n", v, p)
+		}
+		n += len(problems)
+	}
+	fmt.Printf("\n%d problems\n", n)
+	return n, nil
+}
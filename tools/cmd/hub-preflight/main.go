@@ -0,0 +1,95 @@
+// Command hub-preflight checks a new AutoShift version against an export of
+// a live hub before it is rolled out there: it resolves every policy's hub
+// templates for every real ManagedCluster its placements select, with the
+// export in place of tools/testdata, and reports what would fail.
+//
+//	oc get managedclusters,managedclustersetbindings -A -o yaml > /tmp/hub/acm.yaml
+//	oc get secrets,configmaps -A -o yaml > /tmp/hub/config.yaml
+//	cd tools
+//	go run ./cmd/hub-preflight -values /tmp/hub/values.yaml /tmp/hub
+//
+// Each argument is a dump file or a directory of them, read the way
+// testdata-import reads them. Export whatever the policies' hub templates
+// read: the ManagedClusters (their labels and ClusterClaims drive placement
+// and the templates), the ManagedClusterSetBindings, the rendered-config
+// ConfigMaps and every Secret and ConfigMap fromSecret, fromConfigMap and
+// lookup read. The export holds real credentials; keep it off shared disks.
+//
+// Reported are charts that do not render, hub templates that fail (a missing
+// Secret or ConfigMap), hub templates that print <no value> (a label or
+// config key the cluster lacks) and autoshift.io labels on a cluster no
+// _example*.yaml file declares (a typo). -values is the valuesObject the
+// autoshift chart passes Helm policies; without it they render with their own
+// defaults. The exit status is 1 when anything is reported.
+//
+// Needs helm and kustomize, like TestPipeline_EndToEnd.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/resolver"
+	"github.com/auto-shift/autoshiftv2/tools/internal/valueslint"
+)
+
+func main() {
+	repo := flag.String("repo", "..", "repository root")
+	values := flag.String("values", "", "values file Helm policies render with (the autoshift chart's valuesObject)")
+	namespace := flag.String("namespace", "", "policy namespace (default "+resolver.DefaultDeployment().Namespace()+")")
+	cluster := flag.String("cluster", "", "only check this ManagedCluster")
+	flag.Parse()
+
+	n, err := run(*repo, *values, *namespace, *cluster, flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "hub-preflight:", err)
+		os.Exit(1)
+	}
+	if n > 0 {
+		os.Exit(1)
+	}
+}
+
+func run(repo, values, namespace, cluster string, args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("no hub export given")
+	}
+	snapshot, err := resolver.LoadHubSnapshot(args)
+	if err != nil {
+		return 0, err
+	}
+	var fleet []resolver.FleetCluster
+	for _, c := range resolver.SnapshotFleet(snapshot) {
+		if cluster == "" || c.Name == cluster {
+			fleet = append(fleet, c)
+		}
+	}
+	if len(fleet) == 0 {
+		return 0, fmt.Errorf("the export holds no ManagedCluster to check")
+	}
+	lint, err := valueslint.Load(repo)
+	if err != nil {
+		return 0, err
+	}
+
+	dep := resolver.DefaultDeployment()
+	if namespace != "" {
+		dep.Placeholders["POLICY_NAMESPACE"] = namespace
+	}
+	dep.ValuesFile = values
+	r, err := resolver.NewResolver(snapshot)
+	if err != nil {
+		return 0, err
+	}
+	findings, err := resolver.Preflight(filepath.Join(repo, "policies"), dep, r, snapshot, fleet, lint)
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	fmt.Printf("\n%d clusters checked, %d problems\n", len(fleet), len(findings))
+	return len(findings), nil
+}
@@ -0,0 +1,88 @@
+// Command hub-requirements runs the lint pipeline over policies/ and lists
+// the objects the policies read on the hub that AutoShift does not create
+// itself: the Secrets and ConfigMaps (AWS and vSphere credentials, AAP
+// config, vault tokens, ...) an operator has to put on the hub before
+// deploying.
+//
+//	cd tools
+//	go run ./cmd/hub-requirements                   # checklist on stdout
+//	go run ./cmd/hub-requirements -out hub-prereqs  # checklist and manifests
+//
+// With -out it writes hub-requirements.md, the checklist, and one
+// hub-requirements-<profile>.yaml skeleton manifest per cluster profile, with
+// CHANGEME at every key the policies read. The list is derived from the
+// objects tools/testdata answers the policies' lookups, fromSecret and
+// fromConfigMap calls with, so a branch the testdata does not reach is not
+// covered.
+//
+// Needs helm and kustomize, like TestPipeline_EndToEnd.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/resolver"
+)
+
+func main() {
+	repo := flag.String("repo", "..", "repository root")
+	out := flag.String("out", "", "directory to write the checklist and per-profile manifests to")
+	flag.Parse()
+
+	if err := run(*repo, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "hub-requirements:", err)
+		os.Exit(1)
+	}
+}
+
+func run(repo, out string) error {
+	lint, err := resolver.NewLint(repo)
+	if err != nil {
+		return err
+	}
+	dep := resolver.DefaultDeployment()
+	dep.Trace = true
+
+	results, err := lint.Run(dep)
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			fmt.Fprintf(os.Stderr, "skipped %s: %v\n", res.Policy, res.Err)
+		}
+	}
+
+	reqs := resolver.MergeHubRequirements(results)
+	checklist := resolver.HubChecklist(reqs)
+	if out == "" {
+		fmt.Print(checklist)
+		return nil
+	}
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(out, "hub-requirements.md"), []byte(checklist), 0o644); err != nil {
+		return err
+	}
+	names := []string{resolver.PrimaryProfile}
+	for _, p := range lint.Profiles {
+		names = append(names, p.Name)
+	}
+	for _, name := range names {
+		manifest := resolver.HubManifest(reqs, name)
+		if manifest == "" {
+			continue
+		}
+		path := filepath.Join(out, "hub-requirements-"+name+".yaml")
+		if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+			return err
+		}
+		fmt.Println(path)
+	}
+	fmt.Printf("%d hub requirements\n", len(reqs))
+	return nil
+}
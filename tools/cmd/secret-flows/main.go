@@ -0,0 +1,73 @@
+// Command secret-flows runs the lint pipeline over policies/ and reports, for
+// every policy and cluster profile, which Secrets its templates read and
+// where the values land.
+//
+//	cd tools
+//	go run ./cmd/secret-flows                # every flow
+//	go run ./cmd/secret-flows -policy stable/cluster-install
+//
+// Each line names the template side that read the Secret (hub or spoke), the
+// Secret and key, the object and field the value lands in, and how it gets
+// there: verbatim, templated into a longer string, or protected (encrypted by
+// the hub, see protect). A Secret read without any value landing in an object
+// is listed as read only. The values are traced with markers in place of the
+// testdata Secrets' contents, so only the branches the testdata reaches are
+// covered.
+//
+// Needs helm and kustomize, like TestPipeline_EndToEnd.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/resolver"
+)
+
+func main() {
+	repo := flag.String("repo", "..", "repository root")
+	policy := flag.String("policy", "", "only report this policy (e.g. stable/cluster-install)")
+	flag.Parse()
+
+	if err := run(*repo, *policy); err != nil {
+		fmt.Fprintln(os.Stderr, "secret-flows:", err)
+		os.Exit(1)
+	}
+}
+
+func run(repo, policy string) error {
+	lint, err := resolver.NewLint(repo)
+	if err != nil {
+		return err
+	}
+	dep := resolver.DefaultDeployment()
+	dep.Trace = true
+
+	results, err := lint.Run(dep)
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			fmt.Fprintf(os.Stderr, "skipped %s: %v\n", res.Policy, res.Err)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POLICY\tPROFILE\tSIDE\tSECRET\tKEY\tDESTINATION\tFIELD\tMODE")
+	n := 0
+	for _, f := range resolver.AllSecretFlows(results) {
+		if policy != "" && f.Policy != policy {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", f.Policy, f.Profile, f.Side, f.Secret, f.Key, f.Dest, f.Field, f.Mode)
+		n++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d secret flows\n", n)
+	return nil
+}
@@ -0,0 +1,158 @@
+// Command testdata-import turns `oc get -o yaml` (or -o json) dumps from a
+// real cluster into tools/testdata fixtures.
+//
+//	cd tools
+//	oc get infrastructure,clusterversion,nodes -o yaml > /tmp/dumps/cluster.yaml
+//	go run ./cmd/testdata-import -hosts /tmp/hosts.yaml /tmp/dumps            # write into testdata/
+//	go run ./cmd/testdata-import -hosts /tmp/hosts.yaml -dry-run /tmp/dumps   # print them instead
+//
+// Each argument is a dump file or a directory of them; a dump holds one
+// object, a List, or several documents. Every object is sanitized: managed
+// fields, uids, resourceVersions, timestamps, the last applied configuration
+// and status noise are dropped, and a Secret's values become
+// CHANGEME-<key>. Hostnames and IPs are rewritten through the -hosts file, a
+// YAML map of real value to replacement:
+//
+//	prod-east.corp.example.net: test-cluster.test.example.com
+//	10.42.7.12: 10.0.0.10
+//
+// Keep that file out of the repository. Keys are matched literally, so map
+// each IP address on its own. An IP address the map leaves behind stops the
+// import, and so does a hostname it leaves in a Node's name or addresses, in
+// the Infrastructure, DNS or Ingress config, or anywhere under the cluster's
+// base or apps domain — so a real one is never written.
+//
+// Objects are grouped by kind into imported-<resource>[.<group>].yaml. An
+// object whose identity a testdata file already holds is skipped, and a file
+// that already exists only gets new objects appended.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/resolver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func main() {
+	repo := flag.String("repo", "..", "repository root")
+	out := flag.String("out", "", "directory to write fixtures into (default <repo>/tools/testdata)")
+	hostsPath := flag.String("hosts", "", "YAML map of real hostname or IP to its replacement (required)")
+	dryRun := flag.Bool("dry-run", false, "print the fixtures instead of writing them")
+	flag.Parse()
+
+	if err := run(*repo, *out, *hostsPath, *dryRun, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "testdata-import:", err)
+		os.Exit(1)
+	}
+}
+
+func run(repo, out, hostsPath string, dryRun bool, args []string) error {
+	if hostsPath == "" {
+		return fmt.Errorf("-hosts is required: map every real hostname and IP in the dumps to a test one")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("no dumps given")
+	}
+	testdataDir := filepath.Join(repo, "tools", "testdata")
+	if out == "" {
+		out = testdataDir
+	}
+	hosts, err := resolver.LoadHostMap(hostsPath)
+	if err != nil {
+		return err
+	}
+	paths, err := resolver.ClusterExportPaths(args)
+	if err != nil {
+		return err
+	}
+
+	// An overlay is loaded on top of testdata/, so an object either already
+	// holds would only shadow or duplicate it.
+	dirs := []string{testdataDir}
+	if absPath(out) != absPath(testdataDir) {
+		dirs = append(dirs, out)
+	}
+	have := map[string]resolver.Fixture{}
+	for _, dir := range dirs {
+		existing, _, err := resolver.LintFixtures(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, f := range existing {
+			have[f.ID()] = f
+		}
+	}
+
+	var objs []map[string]interface{}
+	var ids []string
+	var domains []string
+	for _, path := range paths {
+		raw, err := resolver.ReadClusterExport(path)
+		if err != nil {
+			return err
+		}
+		for _, obj := range raw {
+			obj = resolver.SanitizeObject(obj, hosts)
+			if u := (unstructured.Unstructured{Object: obj}); u.GetAPIVersion() == "" || u.GetKind() == "" || u.GetName() == "" {
+				fmt.Fprintf(os.Stderr, "skipped an object in %s: no apiVersion, kind or metadata.name\n", path)
+				continue
+			}
+			f := resolver.Fixture{File: filepath.Base(path), Object: obj}
+			if prev, ok := have[f.ID()]; ok {
+				fmt.Printf("skipped %s: already in %s\n", f.ID(), prev.File)
+				continue
+			}
+			domains = append(domains, resolver.ClusterDomains(obj)...)
+			objs = append(objs, obj)
+			ids = append(ids, f.ID())
+		}
+	}
+	// Domains are gathered from every dump first: a route host can sit in a
+	// different dump than the DNS config that names its base domain.
+	var unmappedIPs, unmappedHosts []string
+	for i, obj := range objs {
+		for _, ip := range resolver.UnmappedIPs(obj, hosts) {
+			unmappedIPs = append(unmappedIPs, fmt.Sprintf("%s (%s)", ip, ids[i]))
+		}
+		for _, h := range resolver.UnmappedHosts(obj, domains, hosts) {
+			unmappedHosts = append(unmappedHosts, fmt.Sprintf("%s (%s)", h, ids[i]))
+		}
+	}
+
+	files := resolver.ImportFiles(objs)
+	if dryRun {
+		for _, f := range files {
+			fmt.Printf("\n# %s\n---\n%s", f.Name, strings.Join(f.Docs, "---\n"))
+		}
+	}
+	if len(unmappedIPs) > 0 || len(unmappedHosts) > 0 {
+		for _, u := range unmappedIPs {
+			fmt.Fprintln(os.Stderr, "unmapped IP:", u)
+		}
+		for _, u := range unmappedHosts {
+			fmt.Fprintln(os.Stderr, "unmapped hostname:", u)
+		}
+		return fmt.Errorf("%d IP addresses and %d hostnames are not in %s; map them before importing", len(unmappedIPs), len(unmappedHosts), hostsPath)
+	}
+	if dryRun {
+		return nil
+	}
+	written, err := resolver.WriteStubs(out, files)
+	for _, path := range written {
+		fmt.Println("wrote", path)
+	}
+	return err
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
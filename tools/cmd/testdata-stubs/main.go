@@ -0,0 +1,96 @@
+// Command testdata-stubs runs the lint pipeline over policies/ and writes a
+// stub for every lookup that came back empty into tools/testdata/.
+//
+//	cd tools
+//	go run ./cmd/testdata-stubs                       # write stubs into testdata/
+//	go run ./cmd/testdata-stubs -dry-run              # print them instead
+//	go run ./cmd/testdata-stubs -out testdata/aws     # write into an overlay directory
+//
+// Stubs are grouped by kind into stub-<resource>[.<group>].yaml. Each object
+// carries the namespace, name and selector labels of its lookup, and CHANGEME
+// at every field the templates read from it; edit them into realistic values.
+// A file that already exists keeps its content and only gets new objects
+// appended. A lookup a template expects to miss (e.g. checking that an object
+// does not exist yet) should not get a stub — delete it.
+//
+// Needs helm and kustomize, like TestPipeline_EndToEnd.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/resolver"
+)
+
+func main() {
+	repo := flag.String("repo", "..", "repository root")
+	out := flag.String("out", "", "directory to write stubs into (default <repo>/tools/testdata)")
+	dryRun := flag.Bool("dry-run", false, "print the stubs instead of writing them")
+	flag.Parse()
+
+	if err := run(*repo, *out, *dryRun); err != nil {
+		fmt.Fprintln(os.Stderr, "testdata-stubs:", err)
+		os.Exit(1)
+	}
+}
+
+func run(repo, out string, dryRun bool) error {
+	testdataDir := filepath.Join(repo, "tools", "testdata")
+	if out == "" {
+		out = testdataDir
+	}
+	// Stubs already in an overlay answer their lookups too, so a re-run only
+	// scaffolds what is still missing.
+	var overlays []string
+	if absPath(out) != absPath(testdataDir) {
+		overlays = append(overlays, out)
+	}
+	lint, err := resolver.NewLint(repo, overlays...)
+	if err != nil {
+		return err
+	}
+
+	results, err := lint.Run(resolver.DefaultDeployment())
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			fmt.Fprintf(os.Stderr, "skipped %s: %v\n", res.Policy, res.Err)
+		}
+	}
+
+	lookups := resolver.MergeLookups(results)
+	if len(lookups) == 0 {
+		fmt.Println("no failed lookups")
+		return nil
+	}
+	for _, l := range lookups {
+		fmt.Printf("%s (%s)\n", l, strings.Join(l.Policies, ", "))
+	}
+
+	files := resolver.Stubs(lookups)
+	if dryRun {
+		for _, f := range files {
+			fmt.Printf("\n# %s\n---\n%s", f.Name, strings.Join(f.Docs, "---\n"))
+		}
+		return nil
+	}
+	written, err := resolver.WriteStubs(out, files)
+	for _, path := range written {
+		fmt.Println("wrote", path)
+	}
+	return err
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
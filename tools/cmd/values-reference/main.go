@@ -0,0 +1,48 @@
+// Command values-reference regenerates the label and config key reference in
+// docs/values-reference.md from the _example*.yaml files and the keys each
+// policy's templates read.
+//
+//	cd tools
+//	go run ./cmd/values-reference           # rewrite the generated section
+//	go run ./cmd/values-reference -check    # fail if the committed page is stale
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/valuesdoc"
+)
+
+func main() {
+	repo := flag.String("repo", "..", "repository root")
+	check := flag.Bool("check", false, "exit non-zero if the committed reference differs from the generated one")
+	flag.Parse()
+
+	if err := run(*repo, *check); err != nil {
+		fmt.Fprintln(os.Stderr, "values-reference:", err)
+		os.Exit(1)
+	}
+}
+
+func run(repo string, check bool) error {
+	path := filepath.Join(repo, valuesdoc.DocFile)
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := valuesdoc.Generate(repo, current)
+	if err != nil {
+		return err
+	}
+	if check {
+		if !bytes.Equal(current, out) {
+			return fmt.Errorf("%s is out of date; run `go run ./cmd/values-reference` from tools/", valuesdoc.DocFile)
+		}
+		return nil
+	}
+	return os.WriteFile(path, out, 0o644)
+}
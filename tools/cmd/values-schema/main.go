@@ -0,0 +1,80 @@
+// Command values-schema generates autoshift/values.schema.json from the
+// _example*.yaml files and .github/config-schema-refinements.yaml.
+//
+//	cd tools
+//	go run ./cmd/values-schema           # rewrite the schema
+//	go run ./cmd/values-schema -check    # fail if the committed schema is stale
+//	go run ./cmd/values-schema -validate ../autoshift/values/clustersets/hub.yaml
+//
+// -validate runs the values profile lint: schema violations plus labels no
+// example declares, each reported at its file and line.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/valueslint"
+	"github.com/auto-shift/autoshiftv2/tools/internal/valuesschema"
+)
+
+func main() {
+	repo := flag.String("repo", "..", "repository root")
+	check := flag.Bool("check", false, "exit non-zero if the committed schema differs from the generated one")
+	validate := flag.Bool("validate", false, "lint the values files given as arguments against the examples and the generated schema")
+	flag.Parse()
+
+	if err := run(*repo, *check, *validate, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "values-schema:", err)
+		os.Exit(1)
+	}
+}
+
+func run(repo string, check, validate bool, files []string) error {
+	if validate {
+		linter, err := valueslint.Load(repo)
+		if err != nil {
+			return err
+		}
+		failed := false
+		for _, f := range files {
+			findings, err := linter.LintFile(f, f)
+			if err != nil {
+				return err
+			}
+			for _, finding := range findings {
+				fmt.Println(finding)
+				failed = true
+			}
+		}
+		if failed {
+			return fmt.Errorf("values do not match the examples")
+		}
+		return nil
+	}
+
+	doc, err := valuesschema.Build(repo)
+	if err != nil {
+		return err
+	}
+
+	out, err := valuesschema.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(repo, valuesschema.ChartSchemaFile)
+	if check {
+		current, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(current, out) {
+			return fmt.Errorf("%s is out of date; run `go run ./cmd/values-schema` from tools/", valuesschema.ChartSchemaFile)
+		}
+		return nil
+	}
+	return os.WriteFile(path, out, 0o644)
+}
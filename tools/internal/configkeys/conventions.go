@@ -24,6 +24,13 @@ type Conventions struct {
 	Shared []string `yaml:"shared"`
 	// Aliases map a config key to the policy directory that owns it.
 	Aliases map[string]string `yaml:"aliases"`
+	// MissingOK keys are read by policies but intentionally absent from every
+	// _example*.yaml, e.g. values the chart injects or blocks shown only as a
+	// commented-out template.
+	MissingOK []string `yaml:"missing_ok"`
+	// CrossReads list, per policy directory, the keys owned by another policy
+	// that it is allowed to read.
+	CrossReads map[string][]string `yaml:"cross_reads"`
 }
 
 // Report is the outcome of checking declared keys against the convention.
@@ -188,3 +195,17 @@ func BuildReport(declared map[string][]string, dirs []string, conv *Conventions)
 	sort.Strings(rep.StaleAliases)
 	return rep
 }
+
+// MissingKeys returns the consumed keys declared in no _example*.yaml file and
+// not recorded in missing_ok, sorted. consumed maps a config key to the
+// policies that read it (see ScanReads).
+func MissingKeys(declared, consumed map[string][]string, conv *Conventions) []string {
+	var missing []string
+	for key := range consumed {
+		if _, ok := declared[key]; !ok && !contains(conv.MissingOK, key) {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,68 @@
+package configkeys
+
+import (
+	"path"
+	"sort"
+)
+
+// CrossRead is one config key read by a policy that does not own it.
+type CrossRead struct {
+	// Policy is the reading policy path, e.g. "stable/gitops-dev".
+	Policy string
+	// Key is the top-level rendered-config key it reads.
+	Key string
+	// Owner is the policy directory that owns Key, or "" when no policy does.
+	Owner string
+}
+
+// Owner returns the policy directory that owns key: the directory whose
+// lowerCamelCase form or own name is key, or the target of an alias. The plain
+// directory name counts because operator version allow-lists are keyed by the
+// <key>-version label base (config.cert-manager.versions). Returns "" for
+// shared keys and keys no policy owns.
+func Owner(key string, dirs []string, conv *Conventions) string {
+	if contains(conv.Shared, key) {
+		return ""
+	}
+	if dir, ok := conv.Aliases[key]; ok {
+		return dir
+	}
+	for _, d := range dirs {
+		if key == CamelCase(d) || key == d {
+			return d
+		}
+	}
+	return ""
+}
+
+// FindCrossReads checks each policy's config reads against ownership. A read is
+// allowed when the key is the policy's own, is shared, or is recorded for that
+// policy under cross_reads in the conventions file. Everything else couples the
+// reader's release to another policy's config and is returned, sorted by policy
+// then key.
+//
+// reads maps a policy path ("stable/gitops-dev") to the keys it reads, as
+// produced by ScanReads.
+func FindCrossReads(reads map[string][]string, dirs []string, conv *Conventions) []CrossRead {
+	var out []CrossRead
+	for policy, keys := range reads {
+		dir := path.Base(policy)
+		for _, key := range keys {
+			if contains(conv.Shared, key) || contains(conv.CrossReads[dir], key) {
+				continue
+			}
+			owner := Owner(key, dirs, conv)
+			if owner == dir {
+				continue
+			}
+			out = append(out, CrossRead{Policy: policy, Key: key, Owner: owner})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Policy != out[j].Policy {
+			return out[i].Policy < out[j].Policy
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
@@ -0,0 +1,50 @@
+package configkeys
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindCrossReads(t *testing.T) {
+	dirs := []string{"cert-manager", "gitops-dev", "openshift-gitops", "workload-partitioning"}
+	conv := &Conventions{
+		Shared:     []string{"networking"},
+		Aliases:    map[string]string{"gitops": "openshift-gitops"},
+		CrossReads: map[string][]string{"gitops-dev": {"gitops"}},
+	}
+	reads := map[string][]string{
+		// Own camelCase key, own directory-name key and a shared key.
+		"stable/cert-manager": {"cert-manager", "certManager", "networking"},
+		// Allowed by cross_reads.
+		"stable/gitops-dev": {"gitops"},
+		// Another policy's key, and a key nobody owns.
+		"stable/workload-partitioning": {"certManager", "mystery", "workloadPartitioning"},
+	}
+
+	got := FindCrossReads(reads, dirs, conv)
+	want := []CrossRead{
+		{Policy: "stable/workload-partitioning", Key: "certManager", Owner: "cert-manager"},
+		{Policy: "stable/workload-partitioning", Key: "mystery", Owner: ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindCrossReads =\n  %+v\nwant\n  %+v", got, want)
+	}
+}
+
+func TestOwner_AliasAndShared(t *testing.T) {
+	dirs := []string{"openshift-gitops", "user-workload-monitoring"}
+	conv := &Conventions{
+		Shared:  []string{"hosts"},
+		Aliases: map[string]string{"uwm": "user-workload-monitoring"},
+	}
+	for key, want := range map[string]string{
+		"uwm":             "user-workload-monitoring",
+		"openshiftGitops": "openshift-gitops",
+		"hosts":           "",
+		"unknown":         "",
+	} {
+		if got := Owner(key, dirs, conv); got != want {
+			t.Errorf("Owner(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package configkeys
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// actionRe matches one template action, hub ({{hub ... hub}}) or spoke
+// ({{ ... }}). The match is non-greedy, so a spoke action that embeds a quoted
+// hub template stops at the inner hub}}; the reads inside are still scanned.
+var actionRe = regexp.MustCompile(`(?s)\{\{(.*?)\}\}`)
+
+// assignRe matches a variable declaration or assignment at the start of an
+// action: $config := ... or $config = ...
+var assignRe = regexp.MustCompile(`^\$([A-Za-z_]\w*)\s*:?=\s*(.*)$`)
+
+// rootTailRe matches what may follow the final fromYaml of an expression that
+// yields the whole parsed config: an optional | default dict and closing parens.
+var rootTailRe = regexp.MustCompile(`^\s*(\|\s*default\s+\(?dict\)?\s*)?[\s)]*$`)
+
+var (
+	// index $config "key"
+	indexReadRe = regexp.MustCompile(`index\s+\$([A-Za-z_]\w*)\s+"([A-Za-z_][\w-]*)"`)
+	// hasKey $config "key"
+	hasKeyReadRe = regexp.MustCompile(`hasKey\s+\$([A-Za-z_]\w*)\s+"([A-Za-z_][\w-]*)"`)
+	// $config.key
+	fieldReadRe = regexp.MustCompile(`\$([A-Za-z_]\w*)\.([A-Za-z_]\w*)`)
+	// dig "key" ... $config — dig takes the keys first and the map last.
+	digReadRe = regexp.MustCompile(`dig\s+"([A-Za-z_][\w-]*)"[^$]*?\$([A-Za-z_]\w*)`)
+	// index (... | fromYaml | default dict) "key" — the parsed config indexed inline.
+	inlineReadRe = regexp.MustCompile(`fromYaml(?:\s*\|\s*default\s+\(?dict\)?)?\s*\)+\s*"([A-Za-z_][\w-]*)"`)
+	// .config.key — a config block addressed by path.
+	pathReadRe = regexp.MustCompile(`\.config\.([A-Za-z_]\w*)`)
+)
+
+// ScanReads returns the top-level rendered-config keys read by the templates in
+// rendered, sorted and deduplicated.
+//
+// Policies parse the rendered-config ConfigMap with fromYaml and then address
+// one section of it. ScanReads follows that shape rather than any variable
+// name: a variable assigned from an expression whose result is the parsed
+// config (it ends in fromYaml, optionally | default dict) is a config root, and
+// index/hasKey/dig/field accesses on a root are reads. Actions are visited in
+// source order, so a later reassignment of the same variable to something else
+// stops it counting as a root.
+//
+// The result is deliberately conservative: an access through a variable the
+// scanner cannot prove holds the config root is not reported.
+func ScanReads(rendered string) []string {
+	roots := map[string]bool{}
+	found := map[string]bool{}
+
+	for _, m := range actionRe.FindAllStringSubmatch(rendered, -1) {
+		action := trimAction(m[1])
+		collectReads(action, roots, found)
+		if a := assignRe.FindStringSubmatch(action); a != nil {
+			roots[a[1]] = isConfigRoot(a[2])
+		}
+	}
+	// Path-style reads don't need a root variable and may sit outside actions
+	// (e.g. in a values file rendered into the output).
+	for _, m := range pathReadRe.FindAllStringSubmatchIndex(rendered, -1) {
+		key := rendered[m[2]:m[3]]
+		if isAPIGroupSuffix(rendered[m[3]:]) {
+			continue
+		}
+		found[key] = true
+	}
+
+	out := make([]string, 0, len(found))
+	for k := range found {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// trimAction strips the hub marker and whitespace-trim dashes from the inside
+// of a template action.
+func trimAction(s string) string {
+	s = strings.TrimPrefix(s, "hub")
+	s = strings.TrimSuffix(s, "hub")
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "-")
+	s = strings.TrimSuffix(s, "-")
+	return strings.TrimSpace(s)
+}
+
+// isConfigRoot reports whether expr evaluates to the whole parsed config: it
+// parses something named config with fromYaml and indexes nothing afterwards.
+func isConfigRoot(expr string) bool {
+	idx := strings.LastIndex(expr, "fromYaml")
+	if idx < 0 || !strings.Contains(expr[:idx], "config") {
+		return false
+	}
+	return rootTailRe.MatchString(expr[idx+len("fromYaml"):])
+}
+
+// collectReads adds every config key read in one action to found.
+func collectReads(action string, roots, found map[string]bool) {
+	for _, re := range []*regexp.Regexp{indexReadRe, hasKeyReadRe, fieldReadRe} {
+		for _, m := range re.FindAllStringSubmatch(action, -1) {
+			if roots[m[1]] {
+				found[m[2]] = true
+			}
+		}
+	}
+	for _, m := range digReadRe.FindAllStringSubmatch(action, -1) {
+		if roots[m[2]] {
+			found[m[1]] = true
+		}
+	}
+	for _, m := range inlineReadRe.FindAllStringSubmatch(action, -1) {
+		found[m[1]] = true
+	}
+}
+
+// isAPIGroupSuffix reports whether rest continues a DNS-style API group, so
+// config.openshift.io is not mistaken for a read of the "openshift" key.
+func isAPIGroupSuffix(rest string) bool {
+	for _, tld := range []string{".io", ".com", ".k8s"} {
+		if strings.HasPrefix(rest, tld) {
+			return true
+		}
+	}
+	return false
+}
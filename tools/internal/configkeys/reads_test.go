@@ -0,0 +1,82 @@
+package configkeys
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanReads(t *testing.T) {
+	cases := []struct {
+		name     string
+		rendered string
+		want     []string
+	}{
+		{
+			name: "index on a variable holding the parsed config",
+			rendered: `{{hub- $rcm := (lookup "v1" "ConfigMap" .PolicyMetadata.namespace "x.rendered-config") | default dict hub}}
+{{hub- $config := (index ($rcm.data | default dict) "config" | default "" | fromYaml | default dict) hub}}
+{{hub- $acs := (index $config "acs" | default dict) hub}}
+{{hub- $auth := (index $acs "auth" | default dict) hub}}`,
+			want: []string{"acs"},
+		},
+		{
+			name: "inline index of the parsed config",
+			rendered: `{{hub- $cfg := (index (index (index ((lookup "v1" "ConfigMap" "ns" "x")) "data" | default dict) "config" | default "" | fromYaml | default dict) "gitops" | default dict) hub}}
+{{hub- $v := (dig "versions" (list) $cfg) hub}}`,
+			want: []string{"gitops"},
+		},
+		{
+			name: "dig and field access on a root",
+			rendered: `{{- $config := ($cm.data.config | fromYaml) }}
+{{- $teams := (dig "gitops" "teams" dict $config) }}
+{{- $nets := $config.networking }}`,
+			want: []string{"gitops", "networking"},
+		},
+		{
+			name: "the data map is not the parsed config",
+			rendered: `{{hub- $config := (index $cm "data" | default dict) hub}}
+{{hub- $configYaml := (index $config "config" | default "" | fromYaml | default dict) hub}}
+{{hub- $d := (index $configYaml "disconnected" | default dict) hub}}`,
+			want: []string{"disconnected"},
+		},
+		{
+			name: "reassignment stops a variable counting as a root",
+			rendered: `{{- $config := ($cm.data.config | fromYaml) }}
+{{- $config = (index $config "hosts") }}
+{{- $x := index $config "bmcIP" }}`,
+			want: []string{"hosts"},
+		},
+		{
+			name:     "config path read, API groups ignored",
+			rendered: `storage: {{ .Values.config.uwm.prometheus.storage }} # from dns.config.openshift.io/cluster`,
+			want:     []string{"uwm"},
+		},
+		{
+			name:     "no config access",
+			rendered: `{{hub index .ManagedClusterLabels "autoshift.io/lvm-channel" | default "stable" hub}}`,
+			want:     []string{},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ScanReads(tc.rendered)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ScanReads = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMissingKeys(t *testing.T) {
+	declared := map[string][]string{"acs": {"_example.yaml"}}
+	consumed := map[string][]string{
+		"acs":        {"stable/advanced-cluster-security"},
+		"typoKey":    {"stable/foo"},
+		"versionTag": {"stable/cluster-install"},
+	}
+	conv := &Conventions{MissingOK: []string{"versionTag"}}
+
+	if got := MissingKeys(declared, consumed, conv); !reflect.DeepEqual(got, []string{"typoKey"}) {
+		t.Errorf("MissingKeys = %v, want [typoKey]", got)
+	}
+}
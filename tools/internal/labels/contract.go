@@ -106,6 +106,8 @@ func BuildReport(consumed map[string]*Consumed, declared map[string]*Declared, a
 //	  - label-key-two
 //	orphaned_ok:
 //	  - other-label
+//	profile_prefixes:
+//	  - family-prefix-
 //
 // Returns an error if the file cannot be read or parsed.
 func LoadAllowlist(path string) (*Allowlist, error) {
@@ -115,16 +117,18 @@ func LoadAllowlist(path string) (*Allowlist, error) {
 	}
 
 	var raw struct {
-		MissingOK  []string `yaml:"missing_ok"`
-		OrphanedOK []string `yaml:"orphaned_ok"`
+		MissingOK       []string `yaml:"missing_ok"`
+		OrphanedOK      []string `yaml:"orphaned_ok"`
+		ProfilePrefixes []string `yaml:"profile_prefixes"`
 	}
 	if err := yaml.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("parse allowlist %s: %w", path, err)
 	}
 
 	allow := &Allowlist{
-		MissingOK:  make(map[string]bool, len(raw.MissingOK)),
-		OrphanedOK: make(map[string]bool, len(raw.OrphanedOK)),
+		MissingOK:       make(map[string]bool, len(raw.MissingOK)),
+		OrphanedOK:      make(map[string]bool, len(raw.OrphanedOK)),
+		ProfilePrefixes: raw.ProfilePrefixes,
 	}
 	for _, k := range raw.MissingOK {
 		allow.MissingOK[k] = true
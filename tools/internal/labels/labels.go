@@ -63,6 +63,9 @@ type Allowlist struct {
 	MissingOK map[string]bool
 	// OrphanedOK: declared-but-unconsumed keys that are intentionally exempt.
 	OrphanedOK map[string]bool
+	// ProfilePrefixes: label families whose suffix is user-chosen (a team
+	// name, say); a profile key starting with one is not reported unknown.
+	ProfilePrefixes []string
 }
 
 // Entry is one row in a contract report.
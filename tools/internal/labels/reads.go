@@ -0,0 +1,110 @@
+package labels
+
+import (
+	"sort"
+	"strings"
+)
+
+// readPatterns precede an autoshift.io/<key> label read in a policy template
+// or its placement.
+var readPatterns = []string{
+	`.ManagedClusterLabels "autoshift.io/`,
+	`hasPrefix "autoshift.io/`,
+	`key: 'autoshift.io/`,
+	`key: "autoshift.io/`,
+	`key: autoshift.io/`, // unquoted: kustomize/PolicyGenerator placement predicates
+	// Labels read off a ManagedCluster fetched with `lookup`, rather than from the
+	// hub-template .ManagedClusterLabels context. cluster-install does this to read
+	// the runtime-stamped autoshift.io/owning-namespace. Without this pattern such a
+	// label is consumed but reported neither missing nor orphaned.
+	`dig "metadata" "labels" "autoshift.io/`,
+}
+
+// ScanReads returns the label keys (without the autoshift.io/ prefix) that
+// rendered reads, sorted, in two passes:
+//
+//   - a declared key is read when autoshift.io/<key> appears anywhere, or
+//     autoshift.io/<prefix> does for a numbered key such as
+//     worker-nodes-zone-1;
+//   - any other autoshift.io/<key> after a readPatterns prefix is read too,
+//     unless it is the prefix of a declared key. These surface as missing in
+//     the contract.
+func ScanReads(rendered string, declared map[string]bool) []string {
+	found := make(map[string]bool)
+
+	for key := range declared {
+		if strings.Contains(rendered, "autoshift.io/"+key) {
+			found[key] = true
+			continue
+		}
+		prefix := stripNumberedSuffix(key)
+		if prefix != key && strings.Contains(rendered, "autoshift.io/"+prefix) {
+			found[key] = true
+		}
+	}
+
+	for _, pattern := range readPatterns {
+		remaining := rendered
+		for {
+			idx := strings.Index(remaining, pattern)
+			if idx < 0 {
+				break
+			}
+			after := remaining[idx+len(pattern):]
+			remaining = after
+			end := 0
+			for end < len(after) {
+				c := after[end]
+				if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' {
+					end++
+				} else {
+					break
+				}
+			}
+			if end < 2 {
+				continue
+			}
+			key := after[:end]
+			if strings.HasSuffix(key, "-") {
+				continue
+			}
+			isPrefix := false
+			for dk := range declared {
+				if strings.HasPrefix(dk, key+"-") {
+					isPrefix = true
+					break
+				}
+			}
+			if isPrefix {
+				continue
+			}
+			found[key] = true
+		}
+	}
+
+	out := make([]string, 0, len(found))
+	for k := range found {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// stripNumberedSuffix removes a trailing `-<digits>` segment from a key.
+// E.g. "worker-nodes-zone-1" → "worker-nodes-zone",
+//
+//	"metallb-bgp-1" → "metallb-bgp",
+//	"nmstate" → "nmstate" (unchanged).
+func stripNumberedSuffix(key string) string {
+	idx := strings.LastIndex(key, "-")
+	if idx < 0 {
+		return key
+	}
+	suffix := key[idx+1:]
+	for _, c := range suffix {
+		if c < '0' || c > '9' {
+			return key
+		}
+	}
+	return key[:idx]
+}
@@ -0,0 +1,287 @@
+// Package naming computes the name-length budgets ACM and Kubernetes impose on
+// what AutoShift deploys, from the resolved policy output rather than from the
+// values alone.
+//
+// autoshift/templates/_validate-naming.tpl checks the release name and the
+// clusterset keys at render time, but it never sees the Policy names that
+// PolicyGenerator and the policy charts produce. ACM replicates each policy
+// to a managed cluster as <namespace>.<name>, which must fit a 63-char label
+// value, so the namespace and the policy name share a 62-char budget.
+package naming
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/valueslint"
+)
+
+// Limits, in characters.
+const (
+	// CombinedMax bounds len(namespace) + len(policy name): the replicated
+	// policy <namespace>.<name> is a label value.
+	CombinedMax = 62
+	// NamespaceMax and PolicyNameMax are AutoShift's split of CombinedMax,
+	// enforced by _validate-naming.tpl (namespace) and by review (names).
+	NamespaceMax  = 20
+	PolicyNameMax = 40
+	// ClusterSetKeyMax is _validate-naming.tpl's limit on a clusterset key.
+	ClusterSetKeyMax = 20
+	// LabelValueMax bounds a clusterset name with its version suffix, which
+	// is stamped as the cluster.open-cluster-management.io/clusterset label.
+	LabelValueMax = 63
+)
+
+// DefaultReleases are the release names analyzed when none are given: the
+// one the tests render with and an 11-char name, the longest
+// _validate-naming.tpl accepts.
+var DefaultReleases = []string{"autoshift", "autoshift-2"}
+
+// DefaultTags are the version tags analyzed when none are given: unversioned,
+// a git branch, and OCI release versions.
+var DefaultTags = []string{"", "main", "0.0.1", "1.10.0-rc1"}
+
+// PolicyNamespace is the policy namespace a release deploys into.
+func PolicyNamespace(release string) string { return "policies-" + release }
+
+// PolicyName is one Policy found in a policy directory's resolved output.
+type PolicyName struct {
+	Policy string // "stable/cert-manager"
+	Name   string
+}
+
+// PolicyNames returns the names of the ACM Policy documents in resolvedYAML.
+// Documents that fail to decode are skipped; the pipeline reports them.
+func PolicyNames(policy, resolvedYAML string) []PolicyName {
+	var out []PolicyName
+	dec := yaml.NewDecoder(strings.NewReader(resolvedYAML))
+	for {
+		var doc struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		if doc.Kind == "Policy" && strings.HasPrefix(doc.APIVersion, "policy.open-cluster-management.io/") && doc.Metadata.Name != "" {
+			out = append(out, PolicyName{Policy: policy, Name: doc.Metadata.Name})
+		}
+	}
+	return out
+}
+
+// ClusterSetKeys returns every hubClusterSets and managedClusterSets key in
+// the values files under valuesRoot, sorted and deduplicated.
+func ClusterSetKeys(valuesRoot string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(valuesRoot, "*", "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var keys []string
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", f, err)
+		}
+		for _, bucket := range []string{"hubClusterSets", "managedClusterSets"} {
+			entries, _ := doc[bucket].(map[string]interface{})
+			for k := range entries {
+				if !seen[k] {
+					seen[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// PolicyRow is the budget of one policy name.
+type PolicyRow struct {
+	PolicyName
+	// NameHeadroom is PolicyNameMax minus the name's length.
+	NameHeadroom int
+	// Headroom is CombinedMax minus namespace and name, per release.
+	Headroom []int
+}
+
+// ClusterSetRow is the budget of one clusterset key.
+type ClusterSetRow struct {
+	Key string
+	// KeyHeadroom is ClusterSetKeyMax minus the key's length.
+	KeyHeadroom int
+	// Headroom is LabelValueMax minus the versioned name, per tag.
+	Headroom []int
+}
+
+// Report is the naming budget across a range of release names and tags.
+type Report struct {
+	Releases    []string
+	Tags        []string
+	Policies    []PolicyRow
+	ClusterSets []ClusterSetRow
+}
+
+// Analyze computes the headroom of every policy name for each release and of
+// every clusterset key for each tag. Rows are ordered tightest first.
+func Analyze(names []PolicyName, clusterSets, releases, tags []string) *Report {
+	r := &Report{Releases: releases, Tags: tags}
+	for _, n := range names {
+		row := PolicyRow{PolicyName: n, NameHeadroom: PolicyNameMax - len(n.Name)}
+		for _, rel := range releases {
+			row.Headroom = append(row.Headroom, CombinedMax-len(PolicyNamespace(rel))-len(n.Name))
+		}
+		r.Policies = append(r.Policies, row)
+	}
+	sort.SliceStable(r.Policies, func(i, j int) bool {
+		a, b := r.Policies[i], r.Policies[j]
+		if a.NameHeadroom != b.NameHeadroom {
+			return a.NameHeadroom < b.NameHeadroom
+		}
+		return a.Policy+a.Name < b.Policy+b.Name
+	})
+	for _, k := range clusterSets {
+		row := ClusterSetRow{Key: k, KeyHeadroom: ClusterSetKeyMax - len(k)}
+		for _, t := range tags {
+			row.Headroom = append(row.Headroom, LabelValueMax-len(k+valueslint.ClusterSetSuffix(t)))
+		}
+		r.ClusterSets = append(r.ClusterSets, row)
+	}
+	sort.SliceStable(r.ClusterSets, func(i, j int) bool {
+		return minInt(r.ClusterSets[i].Headroom) < minInt(r.ClusterSets[j].Headroom)
+	})
+	return r
+}
+
+// Violations lists every budget the analyzed names exceed.
+func (r *Report) Violations() []string {
+	var out []string
+	for _, rel := range r.Releases {
+		if ns := PolicyNamespace(rel); len(ns) > NamespaceMax {
+			out = append(out, fmt.Sprintf("release %q: namespace %s is %d chars (max %d)", rel, ns, len(ns), NamespaceMax))
+		}
+	}
+	for _, p := range r.Policies {
+		if p.NameHeadroom < 0 {
+			out = append(out, fmt.Sprintf("%s: policy %s is %d chars (max %d)", p.Policy, p.Name, len(p.Name), PolicyNameMax))
+		}
+		for i, h := range p.Headroom {
+			if h < 0 {
+				out = append(out, fmt.Sprintf("%s: %s.%s is %d chars over the %d-char ACM limit for release %q",
+					p.Policy, PolicyNamespace(r.Releases[i]), p.Name, -h, CombinedMax, r.Releases[i]))
+			}
+		}
+	}
+	for _, c := range r.ClusterSets {
+		if c.KeyHeadroom < 0 {
+			out = append(out, fmt.Sprintf("clusterset %s is %d chars (max %d)", c.Key, len(c.Key), ClusterSetKeyMax))
+		}
+		for i, h := range c.Headroom {
+			if h < 0 {
+				out = append(out, fmt.Sprintf("clusterset %s%s is %d chars over the %d-char label value limit for tag %q",
+					c.Key, valueslint.ClusterSetSuffix(r.Tags[i]), -h, LabelValueMax, r.Tags[i]))
+			}
+		}
+	}
+	return out
+}
+
+// MinPolicyHeadroom is the smallest combined headroom across all policies and
+// releases, or CombinedMax when there are none.
+func (r *Report) MinPolicyHeadroom() int {
+	m := CombinedMax
+	for _, p := range r.Policies {
+		if h := minInt(p.Headroom); h < m {
+			m = h
+		}
+	}
+	return m
+}
+
+// WriteMarkdown writes the report as Markdown tables.
+func WriteMarkdown(w io.Writer, r *Report) {
+	var b bytes.Buffer
+	b.WriteString("# Naming budget\n\n")
+	fmt.Fprintf(&b, "ACM replicates a policy as `<namespace>.<name>`: namespace + name <= %d. "+
+		"AutoShift splits that into a namespace <= %d and a policy name <= %d. "+
+		"Clusterset names with the version suffix are label values, <= %d.\n\n",
+		CombinedMax, NamespaceMax, PolicyNameMax, LabelValueMax)
+
+	b.WriteString("## Releases\n\n| Release | Namespace | Length | Headroom |\n|---|---|---|---|\n")
+	for _, rel := range r.Releases {
+		ns := PolicyNamespace(rel)
+		fmt.Fprintf(&b, "| `%s` | `%s` | %d | %d |\n", rel, ns, len(ns), NamespaceMax-len(ns))
+	}
+
+	b.WriteString("\n## Policies\n\nHeadroom is per release; tightest first.\n\n| Policy | Name | Length | Name headroom |")
+	for _, rel := range r.Releases {
+		fmt.Fprintf(&b, " `%s` |", rel)
+	}
+	b.WriteString("\n|---|---|---|---|" + strings.Repeat("---|", len(r.Releases)) + "\n")
+	for _, p := range r.Policies {
+		fmt.Fprintf(&b, "| `%s` | `%s` | %d | %d |", p.Policy, p.Name, len(p.Name), p.NameHeadroom)
+		for _, h := range p.Headroom {
+			fmt.Fprintf(&b, " %d |", h)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n## Clustersets\n\nHeadroom is per version tag; tightest first.\n\n| Clusterset | Key headroom |")
+	for _, t := range r.Tags {
+		if t == "" {
+			b.WriteString(" unversioned |")
+		} else {
+			fmt.Fprintf(&b, " `%s` |", t)
+		}
+	}
+	b.WriteString("\n|---|---|" + strings.Repeat("---|", len(r.Tags)) + "\n")
+	for _, c := range r.ClusterSets {
+		fmt.Fprintf(&b, "| `%s` | %d |", c.Key, c.KeyHeadroom)
+		for _, h := range c.Headroom {
+			fmt.Fprintf(&b, " %d |", h)
+		}
+		b.WriteString("\n")
+	}
+	w.Write(b.Bytes())
+}
+
+// SplitList parses a comma-separated override such as NAMING_RELEASES;
+// empty elements are kept, so ",main" means unversioned and main.
+func SplitList(s string) []string {
+	parts := strings.Split(s, ",")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	return parts
+}
+
+func minInt(xs []int) int {
+	m := 0
+	for i, x := range xs {
+		if i == 0 || x < m {
+			m = x
+		}
+	}
+	return m
+}
@@ -0,0 +1,82 @@
+package naming
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicyNames(t *testing.T) {
+	resolved := `apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+metadata:
+  name: policy-a
+---
+apiVersion: cluster.open-cluster-management.io/v1beta1
+kind: Placement
+metadata:
+  name: placement-a
+---
+kind: Policy
+apiVersion: policy.open-cluster-management.io/v1
+metadata:
+  name: policy-b
+`
+	got := PolicyNames("stable/a", resolved)
+	want := []PolicyName{{"stable/a", "policy-a"}, {"stable/a", "policy-b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PolicyNames = %v, want %v", got, want)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	long := "policy-" + strings.Repeat("x", 34) // 41 chars
+	r := Analyze(
+		[]PolicyName{{"stable/short", "policy-short"}, {"stable/long", long}},
+		[]string{"hub", strings.Repeat("c", 21)},
+		[]string{"autoshift", "much-too-long"},
+		[]string{"", strings.Repeat("v", 45)},
+	)
+
+	if r.Policies[0].Name != long {
+		t.Errorf("rows not ordered tightest first: %v", r.Policies)
+	}
+	// policies-autoshift is 18 chars, policies-much-too-long 22.
+	if got, want := r.Policies[0].Headroom, []int{62 - 18 - 41, 62 - 22 - 41}; !reflect.DeepEqual(got, want) {
+		t.Errorf("headroom = %v, want %v", got, want)
+	}
+	if got := r.MinPolicyHeadroom(); got != -1 {
+		t.Errorf("MinPolicyHeadroom = %d, want -1", got)
+	}
+
+	want := []string{
+		`release "much-too-long": namespace policies-much-too-long is 22 chars (max 20)`,
+		`stable/long: policy ` + long + ` is 41 chars (max 40)`,
+		`stable/long: policies-much-too-long.` + long + ` is 1 chars over the 62-char ACM limit for release "much-too-long"`,
+		`clusterset ` + strings.Repeat("c", 21) + ` is 21 chars (max 20)`,
+		`clusterset ` + strings.Repeat("c", 21) + `-` + strings.Repeat("v", 45) + ` is 4 chars over the 63-char label value limit for tag "` + strings.Repeat("v", 45) + `"`,
+	}
+	if got := r.Violations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Violations =\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+
+	var b bytes.Buffer
+	WriteMarkdown(&b, r)
+	for _, s := range []string{
+		"| `much-too-long` | `policies-much-too-long` | 22 | -2 |",
+		"| `stable/long` | `" + long + "` | 41 | -1 | 3 | -1 |",
+		"| Clusterset | Key headroom | unversioned | `" + strings.Repeat("v", 45) + "` |",
+		"| `hub` | 17 | 60 | 14 |",
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("markdown missing %q\n%s", s, b.String())
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	if got := SplitList(" , main,0.0.1"); !reflect.DeepEqual(got, []string{"", "main", "0.0.1"}) {
+		t.Errorf("SplitList = %q", got)
+	}
+}
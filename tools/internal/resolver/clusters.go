@@ -0,0 +1,234 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ClusterIdentity is what ACM knows about one managed cluster: the hub's
+// ManagedCluster and ManagedClusterInfo, the ClusterClaims on the cluster
+// itself that fromClusterClaim reads, and the EncryptionKeySecret its
+// config-policy-controller decrypts hub-encrypted values with.
+type ClusterIdentity struct {
+	ManagedCluster     unstructured.Unstructured
+	ManagedClusterInfo unstructured.Unstructured
+	ClusterClaims      []unstructured.Unstructured
+	EncryptionKey      unstructured.Unstructured
+}
+
+// The ClusterClaims every synthetic cluster carries, as the klusterlet names
+// them.
+const (
+	ClaimID       = "id.k8s.io"
+	ClaimName     = "name"
+	ClaimPlatform = "platform.open-cluster-management.io"
+	ClaimProduct  = "product.open-cluster-management.io"
+	ClaimRegion   = "region.open-cluster-management.io" // only when the profile has a region
+	ClaimVersion  = "version.openshift.io"
+)
+
+// installPlatforms maps clusterInstall.platform to the platform claim and the
+// ManagedCluster cloud label ACM reports for it.
+var installPlatforms = map[string]struct{ claim, cloud string }{
+	"aws":       {"AWS", "Amazon"},
+	"azure":     {"Azure", "Azure"},
+	"gcp":       {"GCP", "Google"},
+	"vmware":    {"VSphere", "VSphere"},
+	"vsphere":   {"VSphere", "VSphere"},
+	"baremetal": {"BareMetal", "BareMetal"},
+	"openstack": {"OpenStack", "OpenStack"},
+	"nutanix":   {"Nutanix", "Nutanix"},
+	"ibmcloud":  {"IBM", "IBM"},
+}
+
+// SyntheticClusters builds a ClusterIdentity for the primary context and each
+// extra profile. A cluster's platform, OpenShift version and region come from
+// its cluster-install config — configs.ClusterInstallConfig for the primary,
+// the ClusterInstallExtra variant whose rendered-config it reads for a
+// profile (see GenerateSyntheticConfigMaps) — then from its
+// autoshift.io/openshift-version label, then from the Infrastructure and
+// ClusterVersion in fallback (testdata). Every ManagedCluster carries the
+// profile's labels and is owned by namespace (autoshift.io/owning-namespace),
+// as cluster-install stamps it.
+func SyntheticClusters(ctx HubContext, extraCtxs []NamedContext, configs *ExampleConfigs, namespace string, fallback []unstructured.Unstructured) []ClusterIdentity {
+	installCfgs := map[string]map[string]interface{}{}
+	if configs != nil {
+		installCfgs[ctx.ManagedClusterName] = configs.ClusterInstallConfig
+		for variant, cfg := range configs.ClusterInstallExtra {
+			installCfgs[ctx.ManagedClusterName+"-"+variant] = cfg
+		}
+	}
+
+	out := []ClusterIdentity{SyntheticCluster(ctx, installCfgs[ctx.ManagedClusterName], namespace, fallback)}
+	seen := map[string]bool{ctx.ManagedClusterName: true}
+	for _, ec := range extraCtxs {
+		name := ec.Ctx.ManagedClusterName
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, SyntheticCluster(ec.Ctx, installCfgs[name], namespace, fallback))
+	}
+	return out
+}
+
+// SyntheticCluster builds the ClusterIdentity of ctx's cluster; see
+// SyntheticClusters.
+func SyntheticCluster(ctx HubContext, installCfg map[string]interface{}, namespace string, fallback []unstructured.Unstructured) ClusterIdentity {
+	name := ctx.ManagedClusterName
+	platform, version, region := clusterFacts(ctx, installCfg, fallback)
+	p, ok := installPlatforms[platform]
+	if !ok {
+		p.claim, p.cloud = "Other", "Other"
+	}
+	id := "fake-cluster-id-" + name
+
+	claims := map[string]string{
+		ClaimID:       id,
+		ClaimName:     name,
+		ClaimPlatform: p.claim,
+		ClaimProduct:  "OpenShift",
+		ClaimVersion:  version,
+	}
+	if region != "" {
+		claims[ClaimRegion] = region
+	}
+	claimNames := make([]string, 0, len(claims))
+	for c := range claims {
+		claimNames = append(claimNames, c)
+	}
+	sort.Strings(claimNames)
+
+	labels := map[string]interface{}{}
+	for k, v := range ctx.ManagedClusterLabels {
+		labels[k] = v
+	}
+	if _, ok := labels["autoshift.io/owning-namespace"]; !ok && namespace != "" {
+		labels["autoshift.io/owning-namespace"] = namespace
+	}
+	labels["name"] = name
+	labels["cloud"] = p.cloud
+	labels["vendor"] = "OpenShift"
+	labels["clusterID"] = id
+	if version != "" {
+		parts := strings.SplitN(version, ".", 3)
+		labels["openshiftVersion"] = version
+		labels["openshiftVersion-major"] = parts[0]
+		if len(parts) > 1 {
+			labels["openshiftVersion-major-minor"] = parts[0] + "." + parts[1]
+		}
+	}
+
+	var statusClaims []interface{}
+	var claimObjs []unstructured.Unstructured
+	for _, c := range claimNames {
+		statusClaims = append(statusClaims, map[string]interface{}{"name": c, "value": claims[c]})
+		claimObjs = append(claimObjs, unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cluster.open-cluster-management.io/v1alpha1",
+			"kind":       "ClusterClaim",
+			"metadata": map[string]interface{}{
+				"name":   c,
+				"labels": map[string]interface{}{"open-cluster-management.io/hub-managed": ""},
+			},
+			"spec": map[string]interface{}{"value": claims[c]},
+		}})
+	}
+
+	mc := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cluster.open-cluster-management.io/v1",
+		"kind":       "ManagedCluster",
+		"metadata":   map[string]interface{}{"name": name, "labels": labels},
+		"spec":       map[string]interface{}{"hubAcceptsClient": true},
+		"status": map[string]interface{}{
+			"clusterClaims": statusClaims,
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "HubAcceptedManagedCluster", "status": "True"},
+				map[string]interface{}{"type": "ManagedClusterJoined", "status": "True"},
+				map[string]interface{}{"type": "ManagedClusterConditionAvailable", "status": "True"},
+			},
+		},
+	}}
+
+	ocp := map[string]interface{}{"version": version, "desiredVersion": version}
+	if parts := strings.SplitN(version, ".", 3); len(parts) > 1 {
+		ocp["channel"] = fmt.Sprintf("stable-%s.%s", parts[0], parts[1])
+	}
+	info := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "internal.open-cluster-management.io/v1beta1",
+		"kind":       "ManagedClusterInfo",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": name,
+			"labels":    runtime.DeepCopyJSONValue(labels),
+		},
+		"status": map[string]interface{}{
+			"clusterID":        id,
+			"kubeVendor":       "OpenShift",
+			"cloudVendor":      p.cloud,
+			"distributionInfo": map[string]interface{}{"type": "OCP", "ocp": ocp},
+		},
+	}}
+
+	return ClusterIdentity{ManagedCluster: mc, ManagedClusterInfo: info, ClusterClaims: claimObjs, EncryptionKey: EncryptionKeyObject(name)}
+}
+
+// clusterFacts returns the install platform (clusterInstall.platform spelling),
+// OpenShift version and region of ctx's cluster.
+func clusterFacts(ctx HubContext, installCfg map[string]interface{}, fallback []unstructured.Unstructured) (platform, version, region string) {
+	ci, _ := installCfg["clusterInstall"].(map[string]interface{})
+	platform, _ = ci["platform"].(string)
+	version, _ = ci["openshiftVersion"].(string)
+	if platform != "" {
+		section := platform
+		if platform == "vmware" {
+			section = "vsphere"
+		}
+		if s, ok := installCfg[section].(map[string]interface{}); ok {
+			region, _ = s["region"].(string)
+		}
+	}
+	if version == "" {
+		version = ctx.ManagedClusterLabels["autoshift.io/openshift-version"]
+	}
+
+	for _, u := range fallback {
+		switch {
+		case platform == "" && u.GetKind() == "Infrastructure" && u.GetName() == "cluster":
+			t, _ := nestedString(u.Object, "status", "platformStatus", "type")
+			for k, p := range installPlatforms {
+				if strings.EqualFold(p.claim, t) && k != "vsphere" {
+					platform = k
+				}
+			}
+			if platform != "" && region == "" {
+				region, _ = nestedString(u.Object, "status", "platformStatus", strings.ToLower(t), "region")
+			}
+		case version == "" && u.GetKind() == "ClusterVersion" && u.GetName() == "version":
+			version, _ = nestedString(u.Object, "status", "desired", "version")
+		}
+	}
+	return platform, version, region
+}
+
+// HubObjects returns the ManagedClusters and ManagedClusterInfos of clusters.
+func HubObjects(clusters []ClusterIdentity) []unstructured.Unstructured {
+	var out []unstructured.Unstructured
+	for _, c := range clusters {
+		out = append(out, c.ManagedCluster, c.ManagedClusterInfo)
+	}
+	return out
+}
+
+// SpokeObjects returns the objects only each cluster itself holds — its
+// ClusterClaims and EncryptionKey — keyed by cluster name.
+func SpokeObjects(clusters []ClusterIdentity) map[string][]unstructured.Unstructured {
+	out := make(map[string][]unstructured.Unstructured, len(clusters))
+	for _, c := range clusters {
+		out[c.ManagedCluster.GetName()] = append(append([]unstructured.Unstructured{}, c.ClusterClaims...), c.EncryptionKey)
+	}
+	return out
+}
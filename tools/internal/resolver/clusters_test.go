@@ -0,0 +1,112 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSyntheticClusters(t *testing.T) {
+	configs := &ExampleConfigs{
+		ClusterInstallConfig: map[string]interface{}{
+			"clusterInstall": map[string]interface{}{"platform": "vmware", "openshiftVersion": "4.22.8"},
+		},
+		ClusterInstallExtra: map[string]map[string]interface{}{
+			"aws": {
+				"clusterInstall": map[string]interface{}{"platform": "aws", "openshiftVersion": "4.21.3"},
+				"aws":            map[string]interface{}{"region": "us-east-1"},
+			},
+		},
+	}
+	ctx := HubContext{ManagedClusterName: "lint-cluster", ManagedClusterLabels: map[string]string{"autoshift.io/self-managed": "true"}}
+	extra := []NamedContext{
+		{Name: "managed-aws", Ctx: HubContext{ManagedClusterName: "lint-cluster-aws", ManagedClusterLabels: map[string]string{"autoshift.io/self-managed": "false"}}},
+		{Name: "tier", Ctx: HubContext{ManagedClusterName: "other", ManagedClusterLabels: map[string]string{"autoshift.io/openshift-version": "4.20"}}},
+	}
+	fallback := []unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "config.openshift.io/v1", "kind": "Infrastructure", "metadata": map[string]interface{}{"name": "cluster"},
+			"status": map[string]interface{}{"platformStatus": map[string]interface{}{"type": "AWS", "aws": map[string]interface{}{"region": "us-east-2"}}},
+		}},
+	}
+
+	clusters := SyntheticClusters(ctx, extra, configs, "policies-autoshift", fallback)
+	if len(clusters) != 3 {
+		t.Fatalf("got %d clusters, want 3", len(clusters))
+	}
+	claims := SpokeObjects(clusters)
+	claim := func(cluster, name string) string {
+		for _, c := range claims[cluster] {
+			if c.GetName() == name {
+				v, _ := nestedString(c.Object, "spec", "value")
+				return v
+			}
+		}
+		return "<none>"
+	}
+	for _, tc := range []struct{ cluster, claim, want string }{
+		{"lint-cluster", ClaimPlatform, "VSphere"},
+		{"lint-cluster", ClaimVersion, "4.22.8"},
+		{"lint-cluster", ClaimRegion, "<none>"},
+		{"lint-cluster-aws", ClaimPlatform, "AWS"},
+		{"lint-cluster-aws", ClaimVersion, "4.21.3"},
+		{"lint-cluster-aws", ClaimRegion, "us-east-1"},
+		{"lint-cluster-aws", ClaimName, "lint-cluster-aws"},
+		{"other", ClaimPlatform, "AWS"},
+		{"other", ClaimRegion, "us-east-2"},
+		{"other", ClaimVersion, "4.20"},
+	} {
+		if got := claim(tc.cluster, tc.claim); got != tc.want {
+			t.Errorf("%s claim %s = %q, want %q", tc.cluster, tc.claim, got, tc.want)
+		}
+	}
+
+	mc := clusters[1].ManagedCluster
+	lbls := mc.GetLabels()
+	if lbls["autoshift.io/self-managed"] != "false" || lbls["autoshift.io/owning-namespace"] != "policies-autoshift" || lbls["cloud"] != "Amazon" || lbls["openshiftVersion-major-minor"] != "4.21" {
+		t.Errorf("ManagedCluster labels = %v", lbls)
+	}
+	if info := clusters[1].ManagedClusterInfo; info.GetNamespace() != "lint-cluster-aws" || info.GetLabels()["cloud"] != "Amazon" {
+		t.Errorf("ManagedClusterInfo = %v", info.Object)
+	}
+}
+
+// TestSyntheticClusters_PerProfileAnswers resolves the same templates for two
+// profiles: the hub sees every cluster, the spoke only its own claims.
+func TestSyntheticClusters_PerProfileAnswers(t *testing.T) {
+	configs := &ExampleConfigs{ClusterInstallExtra: map[string]map[string]interface{}{
+		"aws":       {"clusterInstall": map[string]interface{}{"platform": "aws"}},
+		"baremetal": {"clusterInstall": map[string]interface{}{"platform": "baremetal"}},
+	}}
+	ctx := HubContext{ManagedClusterName: "c"}
+	extra := ManagedProfiles(ctx, configs)
+	clusters := SyntheticClusters(ctx, extra, configs, "policies-autoshift", nil)
+
+	hub, err := NewResolver(HubObjects(clusters))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := SpokeObjects(clusters)
+	for _, ec := range extra {
+		res := hub.ResolvePolicy(configurationPolicy(`remediationAction: inform
+owner: '{{hub (lookup "cluster.open-cluster-management.io/v1" "ManagedCluster" "" .ManagedClusterName).metadata.labels.cloud hub}}'
+platform: '{{ fromClusterClaim "platform.open-cluster-management.io" }}'
+`), ec.Ctx)
+		if len(res.Errors) > 0 {
+			t.Fatalf("%s: hub: %v", ec.Name, res.Errors)
+		}
+		spoke, err := NewSpokeResolver(claims[ec.Ctx.ManagedClusterName])
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = spoke.ResolveSpokePolicies(res.Resolved)
+		if len(res.Errors) > 0 {
+			t.Fatalf("%s: spoke: %v", ec.Name, res.Errors)
+		}
+		want := map[string]string{"managed-aws": "owner: Amazon\n        platform: AWS", "managed-baremetal": "owner: BareMetal\n        platform: BareMetal"}[ec.Name]
+		if !strings.Contains(res.Resolved, want) {
+			t.Errorf("%s: resolved\n%s\nwant %q", ec.Name, res.Resolved, want)
+		}
+	}
+}
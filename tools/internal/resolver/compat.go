@@ -0,0 +1,370 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"github.com/stolostron/go-template-utils/v7/pkg/templates"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// ACMVersion is an ACM minor release, e.g. 2.14.
+type ACMVersion struct {
+	Major, Minor int
+}
+
+// ParseACMVersion parses "2.14", "v2.14", "2.14.3" or an operator channel
+// such as "release-2.14".
+func ParseACMVersion(s string) (ACMVersion, error) {
+	major, minor, err := parseMinor(strings.TrimPrefix(strings.TrimSpace(s), "release-"))
+	if err != nil {
+		return ACMVersion{}, fmt.Errorf("ACM version %q: %w", s, err)
+	}
+	return ACMVersion{major, minor}, nil
+}
+
+// parseMinor parses the major and minor release of "4.18", "v4.18" or
+// "4.18.3".
+func parseMinor(s string) (major, minor int, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(s, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("want major.minor")
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("want major.minor")
+	}
+	return major, minor, nil
+}
+
+func (v ACMVersion) String() string { return fmt.Sprintf("%d.%d", v.Major, v.Minor) }
+
+// Before reports whether v is an older release than o.
+func (v ACMVersion) Before(o ACMVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	return v.Minor < o.Minor
+}
+
+// acm is ACM 2.minor.
+func acm(minor int) ACMVersion { return ACMVersion{2, minor} }
+
+// acmFeature is when ACM added a template function or context field, and in
+// which templates it is set: hub ({{hub ... hub}}, resolved by the policy
+// propagator) and spoke ({{ ... }}, resolved by the config policy controller).
+type acmFeature struct {
+	since      ACMVersion
+	hub, spoke bool
+}
+
+// acmTemplateFuncs are ACM's own template functions, after the function map
+// of github.com/stolostron/go-template-utils and the "Governance" section of
+// each ACM release's notes,
+// https://docs.redhat.com/en/documentation/red_hat_advanced_cluster_management_for_kubernetes/2.<minor>/html/release_notes,
+// which the comments below name by release. A function neither this table
+// nor Sprig knows is left to the resolver to report.
+var acmTemplateFuncs = map[string]acmFeature{
+	// 2.3: templates in configuration policies, with their helper functions.
+	"fromSecret":       {acm(3), true, true},
+	"fromConfigMap":    {acm(3), true, true},
+	"lookup":           {acm(3), true, true},
+	"fromClusterClaim": {acm(3), false, true}, // a ClusterClaim is on the managed cluster only
+	"base64enc":        {acm(3), true, true},
+	"base64dec":        {acm(3), true, true},
+	"indent":           {acm(3), true, true},
+	"autoindent":       {acm(3), true, true},
+	"atoi":             {acm(3), true, true},
+	"toInt":            {acm(3), true, true},
+	"toBool":           {acm(3), true, true},
+	"fromJSON":         {acm(3), true, true},
+	"toJSON":           {acm(3), true, true},
+	// 2.5: protect encrypts a hub template's value for the managed cluster;
+	// the config policy controller decrypts it and has no protect of its own.
+	"protect": {acm(5), true, false},
+	// 2.8: copySecretData, copyConfigMapData and toLiteral.
+	"copySecretData":    {acm(8), true, true},
+	"copyConfigMapData": {acm(8), true, true},
+	"toLiteral":         {acm(8), true, true},
+	// 2.9: the node role functions, which read the managed cluster's Nodes.
+	"getNodesWithExactRoles": {acm(9), false, true},
+	"hasNodesWithExactRoles": {acm(9), false, true},
+	// 2.10: the YAML functions, and skipObject for object-templates-raw.
+	"fromYaml":   {acm(10), true, true},
+	"toYaml":     {acm(10), true, true},
+	"fromYAML":   {acm(10), true, true},
+	"toYAML":     {acm(10), true, true},
+	"skipObject": {acm(10), false, true},
+}
+
+// acmContextFields are the fields of the template context, after the ACM
+// release notes (see acmTemplateFuncs). The hub fields describe the managed
+// cluster and Policy the propagator resolves for; the spoke fields the
+// object a ConfigurationPolicy template evaluates for.
+var acmContextFields = map[string]acmFeature{
+	"ManagedClusterName":   {acm(3), true, false},  // 2.3: hub templates
+	"ManagedClusterLabels": {acm(8), true, false},  // 2.8
+	"PolicyMetadata":       {acm(10), true, false}, // 2.10
+	"ObjectNamespace":      {acm(10), false, true}, // 2.10: per namespace of a namespaceSelector
+	"ObjectName":           {acm(13), false, true}, // 2.13: per object of an objectSelector
+	"Object":               {acm(13), false, true}, // 2.13: the object itself
+}
+
+// sprigBasic are the Sprig functions every ACM release the table covers
+// allows. sprigAllSince is the release that allows the rest, all but env and
+// expandenv (see the ACM 2.12 release notes, as acmTemplateFuncs).
+var sprigBasic = []string{
+	"cat", "contains", "default", "empty", "fromJson", "hasPrefix", "hasSuffix", "join", "list",
+	"lower", "mustFromJson", "quote", "replace", "semver", "semverCompare", "split", "splitn",
+	"ternary", "trim", "until", "untilStep", "upper",
+}
+
+var sprigAllSince = acm(12)
+
+// sprigFuncs are the Sprig functions go-template-utils allows.
+var sprigFuncs = templates.AvailableSprigFunctions()
+
+// acmPolicyField is a policy API kind, field or field value and the ACM
+// release that added it. A path is dotted, with [] for every item of a list;
+// an empty path is the kind itself, an empty value any value.
+type acmPolicyField struct {
+	apiVersion, kind string
+	path, value      string
+	since            ACMVersion
+}
+
+// acmPolicyFields are the policy API kinds and fields, after the ACM release
+// notes (see acmTemplateFuncs) and the CRDs of
+// github.com/open-cluster-management-io/governance-policy-propagator and
+// config-policy-controller. PolicyGenerator options are checked through the
+// fields they render (customMessage, hubTemplateOptions, ...).
+var acmPolicyFields = []acmPolicyField{
+	// 2.7: policy dependencies.
+	{"policy.open-cluster-management.io/v1", "Policy", "spec.dependencies", "", acm(7)},
+	{"policy.open-cluster-management.io/v1", "Policy", "spec.policy-templates[].extraDependencies", "", acm(7)},
+	{"policy.open-cluster-management.io/v1", "Policy", "spec.policy-templates[].ignorePending", "", acm(7)},
+	{"policy.open-cluster-management.io/v1", "Policy", "spec.copyPolicyMetadata", "", acm(8)},
+	// 2.13: hub templates that read as a service account rather than the
+	// propagator, past the policy namespace.
+	{"policy.open-cluster-management.io/v1", "Policy", "spec.hubTemplateOptions.serviceAccountName", "", acm(13)},
+	{"policy.open-cluster-management.io/v1", "ConfigurationPolicy", "spec.pruneObjectBehavior", "", acm(6)},
+	{"policy.open-cluster-management.io/v1", "ConfigurationPolicy", "spec.object-templates-raw", "", acm(7)},
+	{"policy.open-cluster-management.io/v1", "ConfigurationPolicy", "spec.object-templates[].recordDiff", "", acm(8)},
+	// 2.10: watch evaluation, the default from then on.
+	{"policy.open-cluster-management.io/v1", "ConfigurationPolicy", "spec.evaluationInterval.compliant", "watch", acm(10)},
+	{"policy.open-cluster-management.io/v1", "ConfigurationPolicy", "spec.evaluationInterval.noncompliant", "watch", acm(10)},
+	{"policy.open-cluster-management.io/v1", "ConfigurationPolicy", "spec.object-templates[].recreateOption", "", acm(10)},
+	{"policy.open-cluster-management.io/v1", "ConfigurationPolicy", "spec.customMessage", "", acm(12)},
+	{"policy.open-cluster-management.io/v1", "ConfigurationPolicy", "spec.object-templates[].objectSelector", "", acm(13)},
+	// 2.10: OperatorPolicy, as a technology preview; 2.11 adds upgrade
+	// approval, compliance configuration and mustnothave removal.
+	{"policy.open-cluster-management.io/v1beta1", "OperatorPolicy", "", "", acm(10)},
+	{"policy.open-cluster-management.io/v1beta1", "OperatorPolicy", "spec.removalBehavior", "", acm(10)},
+	{"policy.open-cluster-management.io/v1beta1", "OperatorPolicy", "spec.versions", "", acm(10)},
+	{"policy.open-cluster-management.io/v1beta1", "OperatorPolicy", "spec.upgradeApproval", "", acm(11)},
+	{"policy.open-cluster-management.io/v1beta1", "OperatorPolicy", "spec.complianceConfig", "", acm(11)},
+	{"policy.open-cluster-management.io/v1beta1", "OperatorPolicy", "spec.complianceType", "mustnothave", acm(11)},
+}
+
+// CheckACMCompatibility reports what the Policies in the rendered,
+// unresolved rawYAML use that an ACM target hub and its spokes do not
+// support: a template function or context field newer than target or not set
+// in the hub or spoke templates that use it, and a policy kind, field or field
+// value newer than target.
+func CheckACMCompatibility(rawYAML string, target ACMVersion) []string {
+	var errs []string
+	for i, doc := range splitYAMLDocuments(rawYAML) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil || obj["kind"] != "Policy" {
+			continue
+		}
+		id := docIdentity(doc, i)
+		seen := map[string]bool{}
+		report := func(msg string) {
+			if !seen[msg] {
+				seen[msg] = true
+				errs = append(errs, id+": "+msg)
+			}
+		}
+		walkStrings(obj, "", func(_, s string) {
+			for _, msg := range templateIncompatibilities(s, target) {
+				report(msg)
+			}
+		})
+		for _, msg := range fieldIncompatibilities(obj, target) {
+			report(msg)
+		}
+		spec, _ := obj["spec"].(map[string]interface{})
+		policyTemplates, _ := spec["policy-templates"].([]interface{})
+		for _, pt := range policyTemplates {
+			pt, _ := pt.(map[string]interface{})
+			def, _ := pt["objectDefinition"].(map[string]interface{})
+			if def == nil {
+				continue
+			}
+			kind, _ := def["kind"].(string)
+			name, _ := nestedString(def, "metadata", "name")
+			for _, msg := range fieldIncompatibilities(def, target) {
+				report(kind + " " + name + ": " + msg)
+			}
+		}
+	}
+	return errs
+}
+
+// hubTemplateRangeRe matches a whole hub template.
+var hubTemplateRangeRe = regexp.MustCompile(`(?s)\{\{hub.*?hub\}\}`)
+
+// templateIncompatibilities checks the hub templates of s, then its spoke
+// templates. A template that does not parse is left to the resolver.
+func templateIncompatibilities(s string, target ACMVersion) []string {
+	if !strings.Contains(s, "{{") {
+		return nil
+	}
+	var msgs []string
+	check := func(src, left, right, side string, hub bool) {
+		t := parse.New("template")
+		t.Mode = parse.SkipFuncCheck
+		trees := map[string]*parse.Tree{}
+		if _, err := t.Parse(src, left, right, trees); err != nil {
+			return
+		}
+		for _, tree := range trees {
+			walkPipes(tree.Root, true, func(pipe *parse.PipeNode, _ bool) {
+				for _, ref := range pipeRefs(pipe) {
+					if msg := refIncompatibility(ref, side, hub, target); msg != "" {
+						msgs = append(msgs, msg)
+					}
+				}
+			})
+		}
+	}
+	if strings.Contains(s, "{{hub") {
+		check(s, "{{hub", "hub}}", "hub", true)
+	}
+	check(hubTemplateRangeRe.ReplaceAllString(s, ""), "{{", "}}", "spoke", false)
+	sort.Strings(msgs)
+	return msgs
+}
+
+// refIncompatibility checks one pipeRefs reference of a hub or spoke
+// template.
+func refIncompatibility(ref, side string, hub bool, target ACMVersion) string {
+	what := ref
+	f, ok := acmTemplateFuncs[ref]
+	if field, isField := refField(ref); isField {
+		f, ok = acmContextFields[field]
+	} else if !ok && !contains(sprigBasic, ref) && contains(sprigFuncs, ref) {
+		f, ok = acmFeature{sprigAllSince, true, true}, true
+		what = "Sprig function " + ref
+	}
+	switch {
+	case !ok:
+		return ""
+	case hub && !f.hub || !hub && !f.spoke:
+		return fmt.Sprintf("%s is not available in %s templates", what, side)
+	case target.Before(f.since):
+		return fmt.Sprintf("%s in %s templates needs ACM %s", what, side, f.since)
+	}
+	return ""
+}
+
+// fieldIncompatibilities checks obj against the acmPolicyFields of its kind.
+func fieldIncompatibilities(obj map[string]interface{}, target ACMVersion) []string {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	var msgs []string
+	for _, f := range acmPolicyFields {
+		if f.apiVersion != apiVersion || f.kind != kind || !target.Before(f.since) {
+			continue
+		}
+		if f.path == "" {
+			msgs = append(msgs, fmt.Sprintf("%s (%s) needs ACM %s", kind, apiVersion, f.since))
+			continue
+		}
+		for _, v := range fieldValues(obj, strings.Split(f.path, ".")) {
+			if f.value == "" {
+				msgs = append(msgs, fmt.Sprintf("%s needs ACM %s", f.path, f.since))
+				break
+			}
+			if s, _ := v.(string); s == f.value {
+				msgs = append(msgs, fmt.Sprintf("%s: %s needs ACM %s", f.path, f.value, f.since))
+				break
+			}
+		}
+	}
+	return msgs
+}
+
+// fieldValues returns the values at path in v, where a segment ending in []
+// descends into every item of a list.
+func fieldValues(v interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{v}
+	}
+	m, _ := v.(map[string]interface{})
+	key, each := strings.CutSuffix(path[0], "[]")
+	next, ok := m[key]
+	if !ok {
+		return nil
+	}
+	if !each {
+		return fieldValues(next, path[1:])
+	}
+	var out []interface{}
+	items, _ := next.([]interface{})
+	for _, item := range items {
+		out = append(out, fieldValues(item, path[1:])...)
+	}
+	return out
+}
+
+// ACMTargets returns the ACM releases of the hub profiles among ctxs — the
+// autoshift.io/acm-channel label — oldest first.
+func ACMTargets(ctxs ...HubContext) []ACMVersion {
+	var out []ACMVersion
+	for _, c := range ctxs {
+		v, err := ParseACMVersion(c.ManagedClusterLabels["autoshift.io/acm-channel"])
+		if err != nil {
+			continue
+		}
+		known := false
+		for _, o := range out {
+			known = known || o == v
+		}
+		if !known {
+			out = append(out, v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
+
+// CheckACMPolicies renders every policy under policiesDir for dep, as
+// Preflight does, and checks it against target (see CheckACMCompatibility).
+// A chart that does not render is reported too. Each line starts with the
+// policy.
+func CheckACMPolicies(policiesDir string, dep Deployment, target ACMVersion) ([]string, error) {
+	charts, err := discoverCharts(policiesDir)
+	if err != nil {
+		return nil, fmt.Errorf("discover charts: %w", err)
+	}
+	sort.Slice(charts, func(i, j int) bool { return charts[i].policy < charts[j].policy })
+	var out []string
+	for _, chart := range charts {
+		rawYAML, err := renderForHub(chart, dep)
+		if err != nil {
+			out = append(out, fmt.Sprintf("%s: does not render: %v", chart.policy, err))
+			continue
+		}
+		for _, e := range CheckACMCompatibility(rawYAML, target) {
+			out = append(out, chart.policy+": "+e)
+		}
+	}
+	return out, nil
+}
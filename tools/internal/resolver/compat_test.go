@@ -0,0 +1,82 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckACMCompatibility(t *testing.T) {
+	raw := `apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+metadata:
+  name: policy-test
+spec:
+  hubTemplateOptions:
+    serviceAccountName: reader
+  policy-templates:
+  - objectDefinition:
+      apiVersion: policy.open-cluster-management.io/v1
+      kind: ConfigurationPolicy
+      metadata:
+        name: test
+      spec:
+        customMessage:
+          noncompliant: '{{hub .ManagedClusterName hub}} is not ready'
+        evaluationInterval:
+          compliant: watch
+          noncompliant: 30s
+        object-templates-raw: |
+          {{- $cfg := "{{hub fromConfigMap "" "x" "y" | protect hub}}" | fromYaml }}
+          {{- range (dict "a" .ManagedClusterName) }}
+          - complianceType: musthave
+            objectDefinition: {{ skipObject }}
+          {{- end }}
+  - objectDefinition:
+      apiVersion: policy.open-cluster-management.io/v1beta1
+      kind: OperatorPolicy
+      metadata:
+        name: test-operator
+      spec:
+        complianceType: musthave
+        upgradeApproval: Automatic
+`
+	got := CheckACMCompatibility(raw, acm(11))
+	want := []string{
+		"Policy/policy-test (document 1): .ManagedClusterName is not available in spoke templates",
+		"Policy/policy-test (document 1): Sprig function dict in spoke templates needs ACM 2.12",
+		"Policy/policy-test (document 1): spec.hubTemplateOptions.serviceAccountName needs ACM 2.13",
+		"Policy/policy-test (document 1): ConfigurationPolicy test: spec.customMessage needs ACM 2.12",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ACM 2.11:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	got = CheckACMCompatibility(raw, acm(9))
+	for _, line := range []string{
+		"Policy/policy-test (document 1): fromYaml in spoke templates needs ACM 2.10",
+		"Policy/policy-test (document 1): skipObject in spoke templates needs ACM 2.10",
+		"Policy/policy-test (document 1): ConfigurationPolicy test: spec.evaluationInterval.compliant: watch needs ACM 2.10",
+		"Policy/policy-test (document 1): OperatorPolicy test-operator: OperatorPolicy (policy.open-cluster-management.io/v1beta1) needs ACM 2.10",
+		"Policy/policy-test (document 1): OperatorPolicy test-operator: spec.upgradeApproval needs ACM 2.11",
+	} {
+		if !contains(got, line) {
+			t.Errorf("ACM 2.9 lacks %q:\n%s", line, strings.Join(got, "\n"))
+		}
+	}
+	if got := CheckACMCompatibility(raw, acm(17)); len(got) != 1 {
+		t.Errorf("ACM 2.17 = %q, want only the spoke .ManagedClusterName", got)
+	}
+}
+
+func TestACMTargets(t *testing.T) {
+	ctx := func(channel string) HubContext {
+		return HubContext{ManagedClusterLabels: map[string]string{"autoshift.io/acm-channel": channel}}
+	}
+	got := ACMTargets(ctx("release-2.17"), ctx("release-2.9"), HubContext{}, ctx("release-2.17"), ctx("bogus"))
+	if len(got) != 2 || got[0] != acm(9) || got[1] != acm(17) {
+		t.Errorf("ACMTargets = %v, want [2.9 2.17]", got)
+	}
+	if v, err := ParseACMVersion("v2.14.3"); err != nil || v != acm(14) {
+		t.Errorf("ParseACMVersion(v2.14.3) = %v, %v", v, err)
+	}
+}
@@ -0,0 +1,138 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// OCPVersion is an OpenShift minor release, e.g. 4.18.
+type OCPVersion struct {
+	Major, Minor int
+}
+
+// channelPrefixRe matches the channel name of an update channel.
+var channelPrefixRe = regexp.MustCompile(`^[a-z]+-`)
+
+// ParseOCPVersion parses "4.18", "4.18.3", a pre-release such as
+// "4.18.0-ec.2" or an update channel such as "stable-4.18".
+func ParseOCPVersion(s string) (OCPVersion, error) {
+	s = strings.TrimSpace(s)
+	major, minor, err := parseMinor(channelPrefixRe.ReplaceAllString(s, ""))
+	if err != nil {
+		return OCPVersion{}, fmt.Errorf("OpenShift version %q: %w", s, err)
+	}
+	return OCPVersion{major, minor}, nil
+}
+
+// clusterOCPVersions returns the OpenShift release of each cluster, by
+// ManagedCluster name, as its openshiftVersion label reports it. A cluster
+// without the label has no entry; one whose label does not parse is an error.
+func clusterOCPVersions(clusters []ClusterIdentity) (map[string]OCPVersion, error) {
+	versions := map[string]OCPVersion{}
+	for _, c := range clusters {
+		label := c.ManagedCluster.GetLabels()["openshiftVersion"]
+		if label == "" {
+			continue
+		}
+		v, err := ParseOCPVersion(label)
+		if err != nil {
+			return nil, fmt.Errorf("ManagedCluster %s: %w", c.ManagedCluster.GetName(), err)
+		}
+		versions[c.ManagedCluster.GetName()] = v
+	}
+	return versions, nil
+}
+
+func (v OCPVersion) String() string { return fmt.Sprintf("%d.%d", v.Major, v.Minor) }
+
+// Before reports whether v is an older release than o.
+func (v OCPVersion) Before(o OCPVersion) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	return v.Minor < o.Minor
+}
+
+// ocp is OpenShift 4.minor, which ships Kubernetes 1.(minor+13).
+func ocp(minor int) OCPVersion { return OCPVersion{4, minor} }
+
+// apiDeprecation is when OpenShift deprecated an API and, if it has, removed
+// it, with what replaces it.
+type apiDeprecation struct {
+	apiVersion, kind    string
+	deprecated, removed OCPVersion // removed is zero while the API is served
+	replacement         string
+}
+
+// apiDeprecations are the APIs an enforced object may still name, after the
+// Kubernetes deprecated API migration guide and the OpenShift release notes.
+var apiDeprecations = []apiDeprecation{
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", ocp(3), ocp(9), "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", ocp(3), ocp(9), "admissionregistration.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", ocp(3), ocp(9), "apiextensions.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", "APIService", ocp(6), ocp(9), "apiregistration.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", "CertificateSigningRequest", ocp(6), ocp(9), "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "Lease", ocp(6), ocp(9), "coordination.k8s.io/v1"},
+	{"extensions/v1beta1", "Ingress", ocp(1), ocp(9), "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", ocp(6), ocp(9), "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", ocp(6), ocp(9), "networking.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", ocp(4), ocp(9), "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", ocp(4), ocp(9), "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", ocp(4), ocp(9), "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", ocp(4), ocp(9), "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", ocp(1), ocp(9), "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIDriver", ocp(6), ocp(9), "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSINode", ocp(6), ocp(9), "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "StorageClass", ocp(6), ocp(9), "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "VolumeAttachment", ocp(6), ocp(9), "storage.k8s.io/v1"},
+	{"batch/v1beta1", "CronJob", ocp(8), ocp(12), "batch/v1"},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", ocp(8), ocp(12), "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "Event", ocp(6), ocp(12), "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", ocp(9), ocp(12), "autoscaling/v2"},
+	{"policy/v1beta1", "PodDisruptionBudget", ocp(8), ocp(12), "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", ocp(8), ocp(12), "Pod Security Admission and SecurityContextConstraints"},
+	{"node.k8s.io/v1beta1", "RuntimeClass", ocp(7), ocp(12), "node.k8s.io/v1"},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", ocp(10), ocp(13), "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", ocp(10), ocp(13), "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", ocp(10), ocp(13), "flowcontrol.apiserver.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", ocp(11), ocp(14), "storage.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", ocp(13), ocp(16), "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", ocp(13), ocp(16), "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "FlowSchema", ocp(16), ocp(19), "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "PriorityLevelConfiguration", ocp(16), ocp(19), "flowcontrol.apiserver.k8s.io/v1"},
+	{"v1", "ComponentStatus", ocp(6), OCPVersion{}, "the cluster operators' status"},
+	{"v1", "Endpoints", ocp(20), OCPVersion{}, "discovery.k8s.io/v1 EndpointSlice"},
+	{"apps.openshift.io/v1", "DeploymentConfig", ocp(14), OCPVersion{}, "apps/v1 Deployment"},
+}
+
+// CheckAPIVersions reports the objects the Policies in resolved create (see
+// PolicyObjects) whose API OpenShift v no longer serves, as removed, and
+// those whose API it has deprecated, as deprecated.
+func CheckAPIVersions(resolved string, v OCPVersion) (removed, deprecated []string) {
+	for _, po := range PolicyObjects(resolved) {
+		apiVersion, _ := po.Object["apiVersion"].(string)
+		kind, _ := po.Object["kind"].(string)
+		for _, d := range apiDeprecations {
+			if d.apiVersion != apiVersion || d.kind != kind || v.Before(d.deprecated) {
+				continue
+			}
+			where := po.Policy + ": "
+			if po.Template != "" {
+				where += po.Template + ": "
+			}
+			name, _ := nestedString(po.Object, "metadata", "name")
+			obj := fmt.Sprintf("%s %s/%s", apiVersion, kind, name)
+			if d.removed != (OCPVersion{}) && !v.Before(d.removed) {
+				removed = append(removed, fmt.Sprintf("%s%s: removed in OpenShift %s — use %s", where, obj, d.removed, d.replacement))
+			} else {
+				msg := fmt.Sprintf("%s%s: deprecated since OpenShift %s — use %s", where, obj, d.deprecated, d.replacement)
+				if d.removed != (OCPVersion{}) {
+					msg += fmt.Sprintf(" before %s removes it", d.removed)
+				}
+				deprecated = append(deprecated, msg)
+			}
+		}
+	}
+	return removed, deprecated
+}
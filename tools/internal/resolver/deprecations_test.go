@@ -0,0 +1,98 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCheckAPIVersions(t *testing.T) {
+	resolved := `apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+metadata:
+  name: policy-test
+spec:
+  policy-templates:
+  - objectDefinition:
+      apiVersion: policy.open-cluster-management.io/v1
+      kind: ConfigurationPolicy
+      metadata:
+        name: test
+      spec:
+        object-templates:
+        - complianceType: musthave
+          objectDefinition:
+            apiVersion: batch/v1beta1
+            kind: CronJob
+            metadata:
+              name: prune
+        - complianceType: mustnothave
+          objectDefinition:
+            apiVersion: policy/v1beta1
+            kind: PodSecurityPolicy
+            metadata:
+              name: old
+        - complianceType: musthave
+          objectDefinition:
+            apiVersion: v1
+            kind: Endpoints
+            metadata:
+              name: external
+        - complianceType: musthave
+          objectDefinition:
+            apiVersion: batch/v1
+            kind: CronJob
+            metadata:
+              name: current
+`
+	removed, deprecated := CheckAPIVersions(resolved, ocp(20))
+	wantRemoved := []string{
+		"Policy/policy-test (document 1): ConfigurationPolicy test: batch/v1beta1 CronJob/prune: removed in OpenShift 4.12 — use batch/v1",
+	}
+	wantDeprecated := []string{
+		"Policy/policy-test (document 1): ConfigurationPolicy test: v1 Endpoints/external: deprecated since OpenShift 4.20 — use discovery.k8s.io/v1 EndpointSlice",
+	}
+	if strings.Join(removed, "\n") != strings.Join(wantRemoved, "\n") {
+		t.Errorf("removed on 4.20:\n%s\nwant:\n%s", strings.Join(removed, "\n"), strings.Join(wantRemoved, "\n"))
+	}
+	if strings.Join(deprecated, "\n") != strings.Join(wantDeprecated, "\n") {
+		t.Errorf("deprecated on 4.20:\n%s\nwant:\n%s", strings.Join(deprecated, "\n"), strings.Join(wantDeprecated, "\n"))
+	}
+
+	removed, deprecated = CheckAPIVersions(resolved, ocp(11))
+	if len(removed) != 0 || len(deprecated) != 1 || !strings.Contains(deprecated[0], "before 4.12 removes it") {
+		t.Errorf("4.11: removed %q, deprecated %q, want only the CronJob deprecated", removed, deprecated)
+	}
+}
+
+func TestParseOCPVersion(t *testing.T) {
+	for _, s := range []string{"4.18", "4.18.3", "4.18.0-ec.2", "4.18.0-rc.1", "stable-4.18", " fast-4.18 ", "candidate-4.18"} {
+		if v, err := ParseOCPVersion(s); err != nil || v != ocp(18) {
+			t.Errorf("ParseOCPVersion(%q) = %v, %v, want 4.18", s, v, err)
+		}
+	}
+	for _, s := range []string{"", "stable", "4"} {
+		if _, err := ParseOCPVersion(s); err == nil {
+			t.Errorf("ParseOCPVersion(%q) succeeded", s)
+		}
+	}
+}
+
+func TestClusterOCPVersions(t *testing.T) {
+	cluster := func(name, version string) ClusterIdentity {
+		mc := unstructured.Unstructured{}
+		mc.SetName(name)
+		if version != "" {
+			mc.SetLabels(map[string]string{"openshiftVersion": version})
+		}
+		return ClusterIdentity{ManagedCluster: mc}
+	}
+	got, err := clusterOCPVersions([]ClusterIdentity{cluster("a", "4.22.0-ec.2"), cluster("b", "")})
+	if err != nil || len(got) != 1 || got["a"] != ocp(22) {
+		t.Errorf("clusterOCPVersions = %v, %v, want a: 4.22 only", got, err)
+	}
+	if _, err := clusterOCPVersions([]ClusterIdentity{cluster("c", "latest")}); err == nil || !strings.Contains(err.Error(), "ManagedCluster c") {
+		t.Errorf("unparsable version: err = %v", err)
+	}
+}
@@ -8,11 +8,12 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
 	"testing"
 
+	"github.com/auto-shift/autoshiftv2/tools/internal/configkeys"
 	"github.com/auto-shift/autoshiftv2/tools/internal/labels"
+	"github.com/auto-shift/autoshiftv2/tools/internal/naming"
 	sigsyaml "sigs.k8s.io/yaml"
 )
 
@@ -36,29 +37,10 @@ func lookupHint(errMsg string) string {
 		stub += fmt.Sprintf("\n\t           name: %s", name)
 	}
 	stub += "\n\t         spec: {} # add whichever fields the template reads from this object"
+	stub += "\n\t       or run `go run ./cmd/testdata-stubs` from tools/ to scaffold stubs for every failed lookup"
 	return stub
 }
 
-// repoRoot walks up from the package directory to find the repository root
-// (identified by the presence of a `policies/` directory).
-func repoRoot(t *testing.T) string {
-	t.Helper()
-	dir, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("getwd: %v", err)
-	}
-	for {
-		if _, err := os.Stat(filepath.Join(dir, "policies")); err == nil {
-			return dir
-		}
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			t.Skip("could not find repo root (no policies/ directory in any parent)")
-		}
-		dir = parent
-	}
-}
-
 // TestPipeline_EndToEnd runs the full lint-labels pipeline against the real
 // policies/ and autoshift/values/ directories. It mirrors what autoshift-ci
 // does in CI.
@@ -105,48 +87,9 @@ func TestPipeline_EndToEnd(t *testing.T) {
 	t.Logf("hub config keys: %d, cluster-install config keys: %d, bare labels: %d",
 		len(configs.HubConfig), len(configs.ClusterInstallConfig), len(configs.BareLabels))
 
-	// Managed (spoke) cluster profiles: same rich label set as the hub, but
-	// self-managed is 'false' and the node-provider labels are pinned to each
-	// install platform. The pipeline resolves every chart against all of these in
-	// addition to the primary hub context, so:
-	//   - hub-only and managed-only policies are each exercised against a cluster
-	//     of the matching clusterset type (self-managed true vs false), and
-	//   - each install platform's cluster is run through the full policy set.
-	//
-	// One managed profile per install platform, driven entirely by
-	// configs.ClusterInstallExtra (one entry per _example-cluster-install-*.yaml,
-	// keyed by clusterInstall.platform) — so a new example file automatically
-	// adds a new profile. Each profile's ManagedClusterName points at that
-	// platform's rendered-config ("<primary>-<platform>.rendered-config", named
-	// by GenerateSyntheticConfigMaps), so policies that read per-cluster config
-	// via `fromConfigMap (print .ManagedClusterName ".rendered-config")` resolve
-	// against that install's config.
-	managedProfile := func(provider, clusterName string) HubContext {
-		lbls := make(map[string]string, len(syntheticLabels)+4)
-		for k, v := range syntheticLabels {
-			lbls[k] = v
-		}
-		lbls["autoshift.io/self-managed"] = "false"
-		lbls["autoshift.io/worker-nodes-provider"] = provider
-		lbls["autoshift.io/infra-nodes-provider"] = provider
-		lbls["autoshift.io/storage-nodes-provider"] = provider
-		return HubContext{
-			ManagedClusterName:   clusterName,
-			ManagedClusterLabels: lbls,
-		}
-	}
-	installPlatforms := make([]string, 0, len(configs.ClusterInstallExtra))
-	for p := range configs.ClusterInstallExtra {
-		installPlatforms = append(installPlatforms, p)
-	}
-	sort.Strings(installPlatforms)
-	extraCtxs := make([]NamedContext, 0, len(installPlatforms))
-	for _, p := range installPlatforms {
-		extraCtxs = append(extraCtxs, NamedContext{
-			Name: "managed-" + p,
-			Ctx:  managedProfile(p, ctx.ManagedClusterName+"-"+p),
-		})
-	}
+	// Managed (spoke) cluster profiles, one per install platform, resolved in
+	// addition to the primary hub context (see ManagedProfiles).
+	extraCtxs := ManagedProfiles(ctx, configs)
 
 	// 4. Generate synthetic ConfigMaps and load testdata.
 	syntheticCMs, err := GenerateSyntheticConfigMaps(configs, ctx.ManagedClusterName, "policies-autoshift")
@@ -190,6 +133,10 @@ func TestPipeline_EndToEnd(t *testing.T) {
 	// The first matching substring wins.
 	resolutionHint := func(errMsg string) string {
 		switch {
+		case strings.Contains(errMsg, "is hub-only context"):
+			return "hint: the managed cluster resolves spoke templates without any hub context; compute the value in a {{hub ... hub}} template (in a Helm template, {{ \"{{hub\" }} ... {{ \"hub}}\" }}) and use its result on the spoke"
+		case strings.Contains(errMsg, "namespaceSelector selects no") || strings.Contains(errMsg, "objectSelector matches no"):
+			return "hint: add the Namespace or object the selector should match to tools/testdata/"
 		case strings.Contains(errMsg, "fromSecret") || strings.Contains(errMsg, "Secret"):
 			return "hint: add a stub Secret to tools/testdata/ (see tools/testdata/acs-secrets.yaml for an example)"
 		case strings.Contains(errMsg, "fromConfigMap") || strings.Contains(errMsg, "ConfigMap"):
@@ -243,6 +190,41 @@ func TestPipeline_EndToEnd(t *testing.T) {
 			}
 			yamlErrCharts++
 		}
+		// A testdata credential in clear text in a hub-resolved Policy — the
+		// hub template read a Secret without fromSecret, copySecretData or
+		// protect, so the value is readable wherever the Policy is.
+		for _, w := range res.SecretLeaks {
+			t.Errorf("FAIL  %s: secret leak: %s", res.Policy, w)
+		}
+		// A ${...} outside the CMP's placeholder set renders literally on the
+		// hub — a typo, or a placeholder the CMP does not substitute.
+		for _, w := range res.Placeholders {
+			t.Errorf("FAIL  %s: placeholder: %s", res.Policy, w)
+		}
+		// An ACM template Helm evaluated at chart render time never reaches the
+		// hub or the spoke as written.
+		for _, w := range res.HelmEscapes {
+			t.Errorf("FAIL  %s: unescaped template: %s", res.Policy, w)
+		}
+		// A function, field or kind the ACM release of a hub profile (its
+		// autoshift.io/acm-channel) does not have.
+		for _, w := range res.ACMCompat {
+			t.Errorf("FAIL  %s: %s", res.Policy, w)
+		}
+		// An API the hub's OpenShift release no longer serves fails the apply on
+		// the cluster; a deprecated one is removed by a later release.
+		for _, w := range res.RemovedAPIs {
+			t.Errorf("FAIL  %s: %s", res.Policy, w)
+		}
+		for _, w := range res.DeprecatedAPIs {
+			t.Logf("WARN  %s: %s", res.Policy, w)
+		}
+		// A name, namespace, label or annotation key the API server rejects —
+		// often one built from a cluster or host name — only fails when the
+		// spoke enforces it.
+		for _, w := range res.Identifiers {
+			t.Errorf("FAIL  %s: invalid identifier: %s", res.Policy, w)
+		}
 		// Managed-clusterset profiles (self-managed: 'false', one per install
 		// platform) — same hard-fail treatment as the hub context, so a
 		// hub-template branch that only runs on managed/spoke clusters (or only
@@ -266,6 +248,18 @@ func TestPipeline_EndToEnd(t *testing.T) {
 				}
 				yamlErrCharts++
 			}
+			for _, w := range cr.SecretLeaks {
+				t.Errorf("FAIL  %s [%s]: secret leak: %s", res.Policy, ec.Name, w)
+			}
+			for _, w := range cr.RemovedAPIs {
+				t.Errorf("FAIL  %s [%s]: %s", res.Policy, ec.Name, w)
+			}
+			for _, w := range cr.DeprecatedAPIs {
+				t.Logf("WARN  %s [%s]: %s", res.Policy, ec.Name, w)
+			}
+			for _, w := range cr.Identifiers {
+				t.Errorf("FAIL  %s [%s]: invalid identifier: %s", res.Policy, ec.Name, w)
+			}
 		}
 	}
 
@@ -472,6 +466,161 @@ func TestPipeline_EndToEnd(t *testing.T) {
 
 	t.Logf("label contract: %d OK, %d missing, %d orphaned",
 		len(report.OK), len(report.Missing), len(report.Orphaned))
+
+	// 9. Reverse config-key check — every top-level rendered-config key a
+	// policy reads must be declared in some _example*.yaml, the config mirror of
+	// the label contract's Missing class above.
+	conv, err := configkeys.LoadConventions(filepath.Join(root, ".github", "config-key-conventions.yaml"))
+	if err != nil {
+		t.Fatalf("loading config-key conventions: %v", err)
+	}
+	declaredConfig, err := configkeys.ExtractDeclared(valuesDir)
+	if err != nil {
+		t.Fatalf("extracting declared config keys: %v", err)
+	}
+	configConsumed := ConfigReadsToConsumed(results)
+	missingConfig := configkeys.MissingKeys(declaredConfig, configConsumed, conv)
+	for _, key := range missingConfig {
+		t.Errorf("config key %q is read by %s but declared in no _example*.yaml file.\n"+
+			"  fix: add it under a config: block in autoshift/values/clustersets/_example.yaml (clusterset\n"+
+			"       config) or a clusters/_example*.yaml (per-cluster config), or record it under\n"+
+			"       missing_ok: in .github/config-key-conventions.yaml if it is intentionally undeclared",
+			key, strings.Join(configConsumed[key], ", "))
+	}
+	t.Logf("config keys: %d read by policies, %d missing from the examples", len(configConsumed), len(missingConfig))
+
+	// 10. Config key ownership — a policy reads its own key, the shared keys and
+	// the cross reads recorded for it; any other read quietly couples its release
+	// to another policy's config.
+	policyDirs, err := configkeys.PolicyDirs(policiesDir)
+	if err != nil {
+		t.Fatalf("listing policy directories: %v", err)
+	}
+	readsByPolicy := make(map[string][]string, len(results))
+	for _, res := range results {
+		readsByPolicy[res.Policy] = res.ConfigKeys
+	}
+	for _, cr := range configkeys.FindCrossReads(readsByPolicy, policyDirs, conv) {
+		owner := "no policy (not shared either)"
+		if cr.Owner != "" {
+			owner = "policy " + cr.Owner
+		}
+		t.Errorf("config key ownership: %s reads config.%s, owned by %s.\n"+
+			"  fix: read only the policy's own key and the shared: keys, or record the read under\n"+
+			"       cross_reads.%s in .github/config-key-conventions.yaml with the reason",
+			cr.Policy, cr.Key, owner, filepath.Base(cr.Policy))
+	}
+
+	// 11. Naming budget — the Policy names the charts actually produce, against
+	// ACM's namespace + name limit for a range of release names, and every
+	// clusterset key against the label value limit for a range of version tags.
+	// NAMING_RELEASES / NAMING_TAGS (comma-separated) override the ranges.
+	releases, tags := naming.DefaultReleases, naming.DefaultTags
+	if v := os.Getenv("NAMING_RELEASES"); v != "" {
+		releases = naming.SplitList(v)
+	}
+	if v := os.Getenv("NAMING_TAGS"); v != "" {
+		tags = naming.SplitList(v)
+	}
+	var policyNames []naming.PolicyName
+	for _, res := range results {
+		policyNames = append(policyNames, naming.PolicyNames(res.Policy, res.ResolvedYAML)...)
+	}
+	clusterSetKeys, err := naming.ClusterSetKeys(valuesDir)
+	if err != nil {
+		t.Fatalf("collecting clusterset keys: %v", err)
+	}
+	budget := naming.Analyze(policyNames, clusterSetKeys, releases, tags)
+	if reportPath := os.Getenv("NAMING_REPORT_OUTPUT"); reportPath != "" {
+		f, err := os.Create(reportPath)
+		if err != nil {
+			t.Errorf("could not create naming report %s: %v", reportPath, err)
+		} else {
+			naming.WriteMarkdown(f, budget)
+			f.Close()
+			t.Logf("naming budget report written to %s", reportPath)
+		}
+	}
+	for _, v := range budget.Violations() {
+		t.Errorf("naming budget: %s", v)
+	}
+	t.Logf("naming budget: %d policies, tightest combined headroom %d chars", len(policyNames), budget.MinPolicyHeadroom())
+
+	// Testdata fixtures no lookup returned in this run, across every profile —
+	// candidates for deletion. TestLintFixtures_Testdata checks the files
+	// themselves.
+	fixtures, _, err := LintFixtures(testdataDir)
+	if err != nil {
+		t.Fatalf("reading testdata fixtures: %v", err)
+	}
+	unread := UnreadFixtures(fixtures, append(r.UsedResources(), spokeR.UsedResources()...), MergeLookups(results))
+	for _, u := range unread {
+		t.Logf("unread fixture: %s", u)
+	}
+	t.Logf("testdata: %d fixtures, %d unread", len(fixtures), len(unread))
+}
+
+// TestPipeline_DryRunDeployment renders every policy for a dryRun release
+// with a custom name, versioned clustersets and polling intervals. Every
+// policy must render in that release's namespace only, and every resolved
+// Policy and policy template must inform.
+func TestPipeline_DryRunDeployment(t *testing.T) {
+	root := repoRoot(t)
+	policiesDir := filepath.Join(root, "policies")
+	valuesDir := filepath.Join(root, "autoshift", "values")
+	testdataDir := filepath.Join(root, "tools", "testdata")
+
+	declared, err := labels.ExtractDeclaredFromTree(valuesDir, false)
+	if err != nil {
+		t.Fatalf("ExtractDeclaredFromTree: %v", err)
+	}
+	configs, err := ExtractExampleConfigs(valuesDir)
+	if err != nil {
+		t.Fatalf("ExtractExampleConfigs: %v", err)
+	}
+	ctx := HubContext{ManagedClusterName: "lint-cluster", ManagedClusterLabels: BuildSyntheticLabels(declared)}
+	dep := DeploymentValues{
+		Release:              "ops",
+		DryRun:               true,
+		CompliantInterval:    "10m",
+		NoncompliantInterval: "30s",
+		ClusterSetSuffix:     "-v2",
+	}.Deployment()
+
+	testResources, err := LoadTestResources(testdataDir)
+	if err != nil {
+		t.Fatalf("LoadTestResources: %v", err)
+	}
+	r, err := NewResolver(testResources)
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	spokeR, err := NewSpokeResolver(testResources)
+	if err != nil {
+		t.Fatalf("NewSpokeResolver: %v", err)
+	}
+	profiles := ManagedProfiles(ctx, configs)
+	_, results, err := RunDeployment(dep, policiesDir, ctx, profiles, r, spokeR, declared, configs, testdataDir)
+	if err != nil {
+		t.Fatalf("RunDeployment: %v", err)
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			t.Errorf("FAIL  %s: %v", res.Policy, res.Err)
+			continue
+		}
+		for _, w := range res.Remediation {
+			t.Errorf("FAIL  %s: %s", res.Policy, w)
+		}
+		for _, ec := range profiles {
+			for _, w := range res.ExtraResults[ec.Name].Remediation {
+				t.Errorf("FAIL  %s [%s]: %s", res.Policy, ec.Name, w)
+			}
+		}
+		for _, leak := range ForeignNamespaces(dep.Namespace(), res.ResolvedYAML) {
+			t.Errorf("FAIL  %s: %s", res.Policy, leak)
+		}
+	}
 }
 
 // TestAutoshiftChart_ClusterInstallExamples renders the top-level autoshift/
@@ -0,0 +1,338 @@
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// acmFuncs are the template functions ACM adds to its hub and spoke
+// templates. A Helm policy that calls one in an action Helm evaluates forgot
+// to escape it: Helm fails on the ones it does not know, and lookup — which
+// Helm has too — quietly returns nothing under `helm template`.
+var acmFuncs = map[string]bool{
+	"lookup": true, "fromSecret": true, "fromConfigMap": true, "fromClusterClaim": true,
+	"copySecretData": true, "copyConfigMapData": true, "protect": true, "skipObject": true,
+	"getNodesWithExactRoles": true, "hasNodesWithExactRoles": true, "toLiteral": true,
+}
+
+// acmFields are the fields of ACM's template context. Helm has no such
+// fields and renders them empty. Object is a common enough key of a Helm
+// dict that it only counts off the template's own context: as $.Object, or
+// as .Object outside range, with and define.
+var acmFields = map[string]bool{
+	"ManagedClusterName": true, "ManagedClusterLabels": true, "PolicyMetadata": true,
+	"ObjectName": true, "ObjectNamespace": true, "Object": true,
+}
+
+// CheckHelmEscapes compares the templates of the Helm policy chartDir with
+// their rendered output rawYAML. It reports, as file:line, each action Helm
+// evaluates that was meant for ACM — an unescaped hub template, an ACM
+// function such as lookup or fromConfigMap, an ACM context field — and each
+// escaped spoke lookup or fromConfigMap a rendered template file holds
+// outside any if, range or with that its output lacks; and, per rendered
+// template, each hub template Helm mangled: one whose {{hub and hub}} do not
+// pair up, or whose body Helm evaluated to nothing. ACM templates in a Helm
+// chart are escaped, as {{ "{{" }} ... {{ "}}" }}.
+func CheckHelmEscapes(chartDir, rawYAML string) ([]string, error) {
+	var errs []string
+	outputs := renderedSources(rawYAML)
+	err := filepath.WalkDir(filepath.Join(chartDir, "templates"), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if ext != ".yaml" && ext != ".tpl" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(chartDir, path)
+		rel = filepath.ToSlash(rel)
+		errs = append(errs, unescapedActions(rel, string(data))...)
+		for source, out := range outputs {
+			if strings.HasSuffix(source, "/"+rel) {
+				errs = append(errs, droppedSpokeCalls(rel, string(data), out)...)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(errs, mangledHubTemplates(rawYAML)...), nil
+}
+
+// unescapedActions parses the Helm template src, named name, and reports its
+// actions that read ACM functions or fields. A template Helm cannot parse is
+// left to helm template to report.
+func unescapedActions(name, src string) []string {
+	t := parse.New(name)
+	t.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	if _, err := t.Parse(src, "", "", trees); err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(trees))
+	for n := range trees {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var errs []string
+	for _, n := range names {
+		tree := trees[n]
+		walkPipes(tree.Root, n == name, func(pipe *parse.PipeNode, top bool) {
+			what := acmReference(pipe, top)
+			if what == "" {
+				return
+			}
+			loc, _ := tree.ErrorContext(pipe)
+			if i := strings.LastIndex(loc, ":"); i >= 0 {
+				loc = loc[:i] // drop the column
+			}
+			if what == "hub" {
+				errs = append(errs, fmt.Sprintf(`%s: unescaped hub template: Helm evaluates {{hub ... hub}} itself — write {{ "{{hub" }} ... {{ "hub}}" }}`, loc))
+				return
+			}
+			errs = append(errs, fmt.Sprintf(`%s: Helm evaluates %s at chart render time — escape the template as {{ "{{" }} ... {{ "}}" }} so ACM does`, loc, what))
+		})
+	}
+	return errs
+}
+
+// walkPipes calls visit with the pipeline of every action, if, range, with
+// and template node under n, and whether dot is still the template's own
+// context there — top is whether it is at n.
+func walkPipes(n parse.Node, top bool, visit func(pipe *parse.PipeNode, top bool)) {
+	branch := func(pipe *parse.PipeNode, list, elseList *parse.ListNode, rebinds bool) {
+		visit(pipe, top)
+		if list != nil {
+			walkPipes(list, top && !rebinds, visit)
+		}
+		if elseList != nil {
+			walkPipes(elseList, top, visit)
+		}
+	}
+	switch n := n.(type) {
+	case *parse.ListNode:
+		for _, c := range n.Nodes {
+			walkPipes(c, top, visit)
+		}
+	case *parse.ActionNode:
+		visit(n.Pipe, top)
+	case *parse.IfNode:
+		branch(n.Pipe, n.List, n.ElseList, false)
+	case *parse.RangeNode:
+		branch(n.Pipe, n.List, n.ElseList, true)
+	case *parse.WithNode:
+		branch(n.Pipe, n.List, n.ElseList, true)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			visit(n.Pipe, top)
+		}
+	}
+}
+
+// acmReference returns the first ACM function (or "hub") or context field
+// pipe reads, or "" when it reads none. top is whether dot is the
+// template's own context.
+func acmReference(pipe *parse.PipeNode, top bool) string {
+	for _, ref := range pipeRefs(pipe) {
+		field, isField := refField(ref)
+		if isField && field == "Object" && !top && !strings.HasPrefix(ref, "$") {
+			continue
+		}
+		if ref == "hub" || acmFuncs[ref] || isField && acmFields[field] {
+			return ref
+		}
+	}
+	return ""
+}
+
+// refField returns the field a pipeRefs reference reads, if it is one.
+func refField(ref string) (string, bool) {
+	return strings.CutPrefix(strings.TrimPrefix(ref, "$"), ".")
+}
+
+// pipeRefs returns the functions pipe calls, by name, and the context fields
+// it reads, as .Field or $.Field, in order.
+func pipeRefs(pipe *parse.PipeNode) []string {
+	if pipe == nil {
+		return nil
+	}
+	var refs []string
+	var ref func(n parse.Node)
+	ref = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.IdentifierNode:
+			refs = append(refs, n.Ident)
+		case *parse.FieldNode:
+			refs = append(refs, "."+n.Ident[0])
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				refs = append(refs, "$."+n.Ident[1])
+			}
+		case *parse.ChainNode:
+			ref(n.Node)
+		case *parse.PipeNode:
+			refs = append(refs, pipeRefs(n)...)
+		}
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			ref(arg)
+		}
+	}
+	return refs
+}
+
+// spokeCallRe matches a call of a spoke template function that reads an
+// object.
+var spokeCallRe = regexp.MustCompile(`\b(?:lookup|fromConfigMap)\s`)
+
+// acmDelimRe matches an ACM template delimiter with its trim marker.
+var acmDelimRe = regexp.MustCompile(`\{\{(?:hub)?-?|-?(?:hub)?\}\}`)
+
+// droppedSpokeCalls parses the Helm template src, named name, and reports
+// the escaped spoke lookup and fromConfigMap calls Helm renders
+// unconditionally — in text outside any if, range or with — that out, the
+// template's rendered output, does not contain. Each call is compared up to
+// the first Helm action or the end of its line.
+func droppedSpokeCalls(name, src, out string) []string {
+	t := parse.New(name)
+	t.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	if _, err := t.Parse(src, "", "", trees); err != nil || trees[name] == nil {
+		return nil
+	}
+	var errs []string
+	spoke := false // inside an escaped {{ ... }}
+	scan := func(text string, pos parse.Pos, conditional bool) {
+		last := 0
+		for _, loc := range append(acmDelimRe.FindAllStringIndex(text, -1), []int{len(text), len(text)}) {
+			if seg := text[last:loc[0]]; spoke && !conditional {
+				for _, m := range spokeCallRe.FindAllStringIndex(seg, -1) {
+					call := seg[m[0]:]
+					if i := strings.IndexByte(call, '\n'); i >= 0 {
+						call = call[:i]
+					}
+					call = strings.TrimSpace(call)
+					if !strings.Contains(out, call) {
+						line := 1 + strings.Count(src[:int(pos)], "\n") + strings.Count(text[:last+m[0]], "\n")
+						errs = append(errs, fmt.Sprintf("%s:%d: spoke template %s is not in the rendered output — Helm dropped or rewrote it", name, line, call))
+					}
+				}
+			}
+			if loc[0] < len(text) {
+				delim := text[loc[0]:loc[1]]
+				spoke = strings.HasPrefix(delim, "{{") && !strings.HasPrefix(delim, "{{hub")
+			}
+			last = loc[1]
+		}
+	}
+	var walk func(n parse.Node, conditional bool)
+	walk = func(n parse.Node, conditional bool) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c, conditional)
+			}
+		case *parse.TextNode:
+			scan(string(n.Text), n.Pos, conditional)
+		case *parse.ActionNode:
+			if lit, ok := stringLiteral(n.Pipe); ok {
+				scan(lit, n.Pos, conditional)
+			}
+		case *parse.IfNode:
+			walk(n.List, true)
+			walk(n.ElseList, true)
+		case *parse.RangeNode:
+			walk(n.List, true)
+			walk(n.ElseList, true)
+		case *parse.WithNode:
+			walk(n.List, true)
+			walk(n.ElseList, true)
+		}
+	}
+	walk(trees[name].Root, false)
+	return errs
+}
+
+// stringLiteral returns the string an action that only prints a string
+// literal prints — how a Helm template escapes ACM delimiters.
+func stringLiteral(pipe *parse.PipeNode) (string, bool) {
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return "", false
+	}
+	str, ok := pipe.Cmds[0].Args[0].(*parse.StringNode)
+	if !ok {
+		return "", false
+	}
+	return str.Text, true
+}
+
+// renderedSources splits the Helm output rawYAML by the template each
+// document came from, as Helm's "# Source:" comment names it.
+func renderedSources(rawYAML string) map[string]string {
+	out := map[string]string{}
+	for _, doc := range splitYAMLDocuments(rawYAML) {
+		for _, line := range strings.Split(doc, "\n") {
+			if s, ok := strings.CutPrefix(line, "# Source: "); ok {
+				source := strings.TrimSpace(s)
+				out[source] += doc + "\n"
+				break
+			}
+		}
+	}
+	return out
+}
+
+// hubDelimRe matches a hub template delimiter with its trim marker.
+var hubDelimRe = regexp.MustCompile(`\{\{hub-?|-?hub\}\}`)
+
+// emptyHubRe matches a hub template with nothing between its delimiters.
+var emptyHubRe = regexp.MustCompile(`\{\{hub-?\s*-?hub\}\}`)
+
+// mangledHubTemplates reports, for each document of the Helm output rawYAML,
+// the hub templates whose delimiters do not pair up or whose body is empty,
+// attributed to the template Helm's "# Source:" comment names.
+func mangledHubTemplates(rawYAML string) []string {
+	var errs []string
+	for i, doc := range splitYAMLDocuments(rawYAML) {
+		source := docIdentity(doc, i)
+		for _, line := range strings.Split(doc, "\n") {
+			if s, ok := strings.CutPrefix(line, "# Source: "); ok {
+				source = strings.TrimSpace(s)
+				break
+			}
+		}
+		open := false
+		for _, delim := range hubDelimRe.FindAllString(doc, -1) {
+			opens := strings.HasPrefix(delim, "{{")
+			switch {
+			case opens && open:
+				errs = append(errs, source+": a {{hub template is not closed with hub}} before the next one opens")
+			case !opens && !open:
+				errs = append(errs, source+": hub}} closes no {{hub template")
+			}
+			open = opens
+		}
+		if open {
+			errs = append(errs, source+": a {{hub template is not closed with hub}}")
+		}
+		for _, m := range emptyHubRe.FindAllString(doc, -1) {
+			errs = append(errs, fmt.Sprintf("%s: empty hub template %s — Helm evaluated its body", source, m))
+		}
+	}
+	return errs
+}
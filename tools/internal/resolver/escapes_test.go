@@ -0,0 +1,71 @@
+package resolver
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckHelmEscapes(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFile(t, filepath.Join(dir, "templates"), "policy.yaml", `data:
+  ok: '{{ "{{hub" }} fromConfigMap "" "x" "y" {{ "hub}}" }}'
+  spoke: '{{ "{{" }} (lookup "v1" "Secret" "{{ .Values.ns }}" "x").data.k }}'
+  evaluated: '{{ (lookup "v1" "Secret" "ns" "x").data.k }}'
+{{- if .Values.enabled }}
+  cluster: '{{ .ManagedClusterName }}'
+{{- end }}
+  hub: '{{hub fromConfigMap "" "x" "y" hub}}'
+  dropped: '{{ "{{" }} fromConfigMap "app" "settings" "url" {{ "}}" }}'
+{{- range .Values.items }}
+  item: '{{ .Object }}'
+  conditional: '{{ "{{" }} fromConfigMap "app" "other" "k" {{ "}}" }}'
+{{- end }}
+  object: '{{ .Object.kind }}'
+`)
+	rendered := `---
+# Source: test/templates/policy.yaml
+data:
+  ok: '{{hub fromConfigMap "" "x" "y" hub}}'
+  spoke: '{{ (lookup "v1" "Secret" "ns" "x").data.k }}'
+  empty: '{{hub  hub}}'
+  unclosed: '{{hub .ManagedClusterName }}'
+`
+	got, err := CheckHelmEscapes(dir, rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`templates/policy.yaml:4: Helm evaluates lookup at chart render time — escape the template as {{ "{{" }} ... {{ "}}" }} so ACM does`,
+		`templates/policy.yaml:6: Helm evaluates .ManagedClusterName at chart render time — escape the template as {{ "{{" }} ... {{ "}}" }} so ACM does`,
+		`templates/policy.yaml:8: unescaped hub template: Helm evaluates {{hub ... hub}} itself — write {{ "{{hub" }} ... {{ "hub}}" }}`,
+		`templates/policy.yaml:14: Helm evaluates .Object at chart render time — escape the template as {{ "{{" }} ... {{ "}}" }} so ACM does`,
+		`templates/policy.yaml:9: spoke template fromConfigMap "app" "settings" "url" is not in the rendered output — Helm dropped or rewrote it`,
+		`test/templates/policy.yaml: a {{hub template is not closed with hub}}`,
+		`test/templates/policy.yaml: empty hub template {{hub  hub}} — Helm evaluated its body`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CheckHelmEscapes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestPolicyHelmEscapes checks the templates of every Helm policy in the
+// repo, without helm.
+func TestPolicyHelmEscapes(t *testing.T) {
+	charts, err := discoverCharts(filepath.Join(repoRoot(t), "policies"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chart := range charts {
+		if chart.kind != "helm" {
+			continue
+		}
+		errs, err := CheckHelmEscapes(chart.dir, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range errs {
+			t.Errorf("%s: %s", chart.policy, e)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package resolver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Fixture is one object in a testdata file, as LoadTestResources loads it.
+type Fixture struct {
+	File   string // base name, e.g. cluster-infrastructure.yaml
+	Doc    int    // 1-based document index in File
+	Object map[string]interface{}
+}
+
+// ID is the identity a lookup matches a fixture on.
+func (f Fixture) ID() string {
+	return fixtureID(f.Object)
+}
+
+func (f Fixture) String() string {
+	return fmt.Sprintf("%s document %d (%s)", f.File, f.Doc, f.ID())
+}
+
+// fixtureID is "<apiVersion> <kind> <namespace>/<name>", or without the
+// namespace for a cluster-scoped object.
+func fixtureID(obj map[string]interface{}) string {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	ns, _ := nestedString(obj, "metadata", "namespace")
+	name, _ := nestedString(obj, "metadata", "name")
+	if ns != "" {
+		name = ns + "/" + name
+	}
+	return apiVersion + " " + kind + " " + name
+}
+
+// LintFixtures reads the .yaml files in dir the way LoadTestResources does and
+// reports what it would silently skip or get wrong: a document that does not
+// parse or is not an object, an object without apiVersion, kind or
+// metadata.name, two objects with one identity (only one of them can answer a
+// lookup), and a Secret whose data is not base64. Comment-only documents are
+// not findings. It returns the fixtures that did load.
+func LintFixtures(dir string) ([]Fixture, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fixtures []Fixture
+	var findings []string
+	first := map[string]Fixture{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, doc := range splitYAMLDocuments(string(data)) {
+			at := fmt.Sprintf("%s document %d", entry.Name(), i+1)
+			if !hasContent(doc) {
+				continue
+			}
+			var raw interface{}
+			if err := sigsyaml.Unmarshal([]byte(doc), &raw); err != nil {
+				findings = append(findings, fmt.Sprintf("%s: does not parse: %v", at, err))
+				continue
+			}
+			obj, ok := raw.(map[string]interface{})
+			if !ok || len(obj) == 0 {
+				findings = append(findings, fmt.Sprintf("%s: is not an object", at))
+				continue
+			}
+			f := Fixture{File: entry.Name(), Doc: i + 1, Object: obj}
+			fixtures = append(fixtures, f)
+
+			var missing []string
+			for _, field := range []string{"apiVersion", "kind"} {
+				if s, _ := obj[field].(string); s == "" {
+					missing = append(missing, field)
+				}
+			}
+			if name, _ := nestedString(obj, "metadata", "name"); name == "" {
+				missing = append(missing, "metadata.name")
+			}
+			if len(missing) > 0 {
+				findings = append(findings, fmt.Sprintf("%s: missing %s", at, strings.Join(missing, ", ")))
+				continue
+			}
+
+			if prev, ok := first[f.ID()]; ok {
+				findings = append(findings, fmt.Sprintf("%s: %s is already defined in %s document %d", at, f.ID(), prev.File, prev.Doc))
+			} else {
+				first[f.ID()] = f
+			}
+
+			if obj["kind"] == "Secret" && obj["apiVersion"] == "v1" {
+				data, _ := obj["data"].(map[string]interface{})
+				for _, key := range sortedKeys(data) {
+					s, ok := data[key].(string)
+					if _, err := base64.StdEncoding.DecodeString(s); !ok || err != nil {
+						findings = append(findings, fmt.Sprintf("%s: Secret data.%s is not base64 — encode it, or move it to stringData", at, key))
+					}
+				}
+			}
+		}
+	}
+	return fixtures, findings, nil
+}
+
+// hasContent reports whether doc has anything besides comments and blank
+// lines.
+func hasContent(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// UsedResources returns every object a lookup, fromSecret or fromConfigMap
+// has returned since the resolver was created.
+func (r *Resolver) UsedResources() []unstructured.Unstructured {
+	var out []unstructured.Unstructured
+	for _, used := range r.inner.GetUsedResources() {
+		out = append(out, used.Resource)
+	}
+	return out
+}
+
+// UnreadFixtures reports the fixtures no lookup returned, given the objects
+// the run's resolvers returned (Resolver.UsedResources) and the lookups that
+// came back empty (MergeLookups). Such a fixture is dead and can be deleted —
+// unless it is the only object of a kind that lookups found nothing for: the
+// fake API server only knows a kind some fixture has, and without it those
+// lookups would fail instead of coming back empty. That one is reported as
+// registering its kind.
+func UnreadFixtures(fixtures []Fixture, read []unstructured.Unstructured, missed []Lookup) []string {
+	readIDs := map[string]bool{}
+	for _, obj := range read {
+		readIDs[fixtureID(obj.Object)] = true
+	}
+	kindOf := func(obj map[string]interface{}) string {
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		return apiVersion + " " + kind
+	}
+	kindRead := map[string]bool{}
+	for _, f := range fixtures {
+		if readIDs[f.ID()] {
+			kindRead[kindOf(f.Object)] = true
+		}
+	}
+	kindMissed := map[string]bool{}
+	for _, l := range missed {
+		kindMissed[l.APIVersion+" "+l.Kind] = true
+	}
+
+	var out []string
+	registered := map[string]bool{}
+	for _, f := range fixtures {
+		if readIDs[f.ID()] {
+			continue
+		}
+		kind := kindOf(f.Object)
+		if kindMissed[kind] && !kindRead[kind] && !registered[kind] {
+			registered[kind] = true
+			out = append(out, fmt.Sprintf("%s: no policy reads it, but it registers %s for the lookups that find nothing — keep one object of that kind", f, kind))
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s: no policy reads it", f))
+	}
+	sort.Strings(out)
+	return out
+}
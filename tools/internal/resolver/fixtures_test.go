@@ -0,0 +1,109 @@
+package resolver
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestLintFixtures(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFile(t, dir, "a.yaml", `# only a comment
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: ns
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  namespace: ns
+---
+- not
+- an object
+---
+kind: [unclosed
+`)
+	mustWriteFile(t, dir, "b.yaml", `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: ns
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: s
+  namespace: ns
+data:
+  good: Q0hBTkdFTUU=
+  bad: not base64!
+`)
+	mustWriteFile(t, dir, "notes.txt", "kind: [ignored")
+
+	fixtures, findings, err := LintFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 4 {
+		t.Errorf("loaded %d fixtures, want 4", len(fixtures))
+	}
+	want := []string{
+		"a.yaml document 3: missing metadata.name",
+		"a.yaml document 4: is not an object",
+		"a.yaml document 5: does not parse",
+		"b.yaml document 1: v1 ConfigMap ns/cm is already defined in a.yaml document 2",
+		"b.yaml document 2: Secret data.bad is not base64",
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("findings:\n%s", strings.Join(findings, "\n"))
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(findings[i], w) {
+			t.Errorf("finding %d = %q, want prefix %q", i, findings[i], w)
+		}
+	}
+}
+
+// TestLintFixtures_Testdata keeps tools/testdata clean: LoadTestResources
+// would silently drop or shadow whatever this reports.
+func TestLintFixtures_Testdata(t *testing.T) {
+	_, findings, err := LintFixtures(filepath.Join(repoRoot(t), "tools", "testdata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range findings {
+		t.Errorf("tools/testdata/%s", f)
+	}
+}
+
+func TestUnreadFixtures(t *testing.T) {
+	obj := func(apiVersion, kind, name string) map[string]interface{} {
+		return map[string]interface{}{"apiVersion": apiVersion, "kind": kind, "metadata": map[string]interface{}{"name": name}}
+	}
+	fixtures := []Fixture{
+		{File: "nodes.yaml", Doc: 1, Object: obj("v1", "Node", "worker-1")},
+		{File: "nodes.yaml", Doc: 2, Object: obj("v1", "Node", "worker-2")},
+		{File: "dns.yaml", Doc: 1, Object: obj("config.openshift.io/v1", "DNS", "cluster")},
+		{File: "dns.yaml", Doc: 2, Object: obj("config.openshift.io/v1", "DNS", "other")},
+		{File: "old.yaml", Doc: 1, Object: obj("example.com/v1", "Widget", "w")},
+	}
+	read := []unstructured.Unstructured{{Object: obj("v1", "Node", "worker-1")}}
+	missed := []Lookup{{APIVersion: "config.openshift.io/v1", Kind: "DNS", Name: "missing"}}
+
+	got := UnreadFixtures(fixtures, read, missed)
+	want := []string{
+		"dns.yaml document 1 (config.openshift.io/v1 DNS cluster): no policy reads it, but it registers config.openshift.io/v1 DNS for the lookups that find nothing — keep one object of that kind",
+		"dns.yaml document 2 (config.openshift.io/v1 DNS other): no policy reads it",
+		"nodes.yaml document 2 (v1 Node worker-2): no policy reads it",
+		"old.yaml document 1 (example.com/v1 Widget w): no policy reads it",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnreadFixtures:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
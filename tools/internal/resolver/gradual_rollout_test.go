@@ -0,0 +1,48 @@
+//go:build integration
+
+package resolver
+
+import (
+	"os"
+	"testing"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/naming"
+)
+
+// TestPipeline_GradualRollout deploys the releases of docs/gradual-rollout.md
+// side by side on one hub — as-0-0-1 and as-0-0-2 with versionedClusterSets,
+// each from hub.yaml and managed.yaml — and renders every policy for both.
+//
+// The guide's promise is that the two never interfere: each release gets its
+// own policy namespace and its own suffixed clustersets, so a cluster is only
+// ever configured by the release whose clusterset it sits in. Nothing else
+// tests that promise against the real policies. A Placement that names an
+// unsuffixed clusterset, a ManagedClusterSetBinding that ignores the suffix, or
+// a cluster-scoped object both releases render would all break it (see
+// CheckRollout).
+//
+// ROLLOUT_TAGS (comma-separated) overrides the version tags.
+func TestPipeline_GradualRollout(t *testing.T) {
+	tags := DefaultRolloutTags
+	if v := os.Getenv("ROLLOUT_TAGS"); v != "" {
+		tags = naming.SplitList(v)
+	}
+	if len(tags) < 2 {
+		t.Fatalf("a rollout needs two version tags, got %q", tags)
+	}
+
+	env := newTierEnv(t)
+	var runs []TierRun
+	for _, tag := range tags {
+		run, ok := env.run(t, RolloutTier(tag))
+		if !ok {
+			continue
+		}
+		runs = append(runs, run)
+		requireLanding(t, run)
+	}
+
+	for _, v := range CheckRollout(runs) {
+		t.Errorf("%s", v)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/auto-shift/autoshiftv2/tools/internal/labels"
@@ -32,6 +33,21 @@ func KeysToConsumed(keysByPolicy map[string]map[string]bool) map[string]*labels.
 	return result
 }
 
+// ConfigReadsToConsumed inverts the per-chart ConfigKeys into the
+// key → policies map that configkeys.MissingKeys expects.
+func ConfigReadsToConsumed(results []ChartResult) map[string][]string {
+	consumed := map[string][]string{}
+	for _, res := range results {
+		for _, key := range res.ConfigKeys {
+			consumed[key] = append(consumed[key], res.Policy)
+		}
+	}
+	for key := range consumed {
+		sort.Strings(consumed[key])
+	}
+	return consumed
+}
+
 // BuildSyntheticLabels constructs a ManagedClusterLabels map suitable for hub
 // template resolution. Each declared key gets the autoshift.io/ prefix and its
 // first example-file value (or empty string if no example value exists).
@@ -53,13 +69,116 @@ func BuildSyntheticLabels(declared map[string]*labels.Declared) map[string]strin
 	return result
 }
 
+// ManagedProfiles returns the managed (spoke) cluster profiles the pipeline
+// resolves every chart against besides the primary hub context ctx: the same
+// rich label set, but self-managed is 'false' and the node-provider labels are
+// pinned to an install platform. So:
+//   - hub-only and managed-only policies are each exercised against a cluster
+//     of the matching clusterset type (self-managed true vs false), and
+//   - each install platform's cluster is run through the full policy set.
+//
+// One profile per install platform, driven entirely by
+// configs.ClusterInstallExtra (one entry per _example-cluster-install-*.yaml,
+// keyed by clusterInstall.platform) — so a new example file automatically adds
+// a new profile. Each profile's ManagedClusterName points at that platform's
+// rendered-config ("<primary>-<platform>.rendered-config", named by
+// GenerateSyntheticConfigMaps), so policies that read per-cluster config via
+// `fromConfigMap (print .ManagedClusterName ".rendered-config")` resolve
+// against that install's config.
+func ManagedProfiles(ctx HubContext, configs *ExampleConfigs) []NamedContext {
+	installPlatforms := make([]string, 0, len(configs.ClusterInstallExtra))
+	for p := range configs.ClusterInstallExtra {
+		installPlatforms = append(installPlatforms, p)
+	}
+	sort.Strings(installPlatforms)
+
+	profiles := make([]NamedContext, 0, len(installPlatforms))
+	for _, p := range installPlatforms {
+		lbls := make(map[string]string, len(ctx.ManagedClusterLabels)+4)
+		for k, v := range ctx.ManagedClusterLabels {
+			lbls[k] = v
+		}
+		lbls["autoshift.io/self-managed"] = "false"
+		lbls["autoshift.io/worker-nodes-provider"] = p
+		lbls["autoshift.io/infra-nodes-provider"] = p
+		lbls["autoshift.io/storage-nodes-provider"] = p
+		profiles = append(profiles, NamedContext{
+			Name: "managed-" + p,
+			Ctx: HubContext{
+				ManagedClusterName:   ctx.ManagedClusterName + "-" + p,
+				ManagedClusterLabels: lbls,
+			},
+		})
+	}
+	return profiles
+}
+
+// Lint is the pipeline setup the lint commands share: the labels and config
+// the _example*.yaml files declare, the synthetic lint-cluster with one
+// profile per install platform, and hub and spoke resolvers seeded with
+// tools/testdata.
+type Lint struct {
+	PoliciesDir string
+	TestdataDir string
+	Declared    map[string]*labels.Declared
+	Configs     *ExampleConfigs
+	Ctx         HubContext
+	Profiles    []NamedContext
+	Hub, Spoke  *Resolver
+}
+
+// NewLint reads the examples and testdata of the repository at repo. The
+// objects in overlays seed the resolvers along with testdata.
+func NewLint(repo string, overlays ...string) (*Lint, error) {
+	valuesDir := filepath.Join(repo, "autoshift", "values")
+	l := &Lint{
+		PoliciesDir: filepath.Join(repo, "policies"),
+		TestdataDir: filepath.Join(repo, "tools", "testdata"),
+	}
+	var err error
+	if l.Declared, err = labels.ExtractDeclaredFromTree(valuesDir, false); err != nil {
+		return nil, err
+	}
+	if l.Configs, err = ExtractExampleConfigs(valuesDir); err != nil {
+		return nil, err
+	}
+	l.Ctx = HubContext{
+		ManagedClusterName:   "lint-cluster",
+		ManagedClusterLabels: BuildSyntheticLabels(l.Declared),
+	}
+	l.Profiles = ManagedProfiles(l.Ctx, l.Configs)
+
+	var seed []unstructured.Unstructured
+	for _, dir := range append([]string{l.TestdataDir}, overlays...) {
+		res, err := LoadTestResources(dir)
+		if err != nil {
+			return nil, err
+		}
+		seed = append(seed, res...)
+	}
+	if l.Hub, err = NewResolver(seed); err != nil {
+		return nil, err
+	}
+	if l.Spoke, err = NewSpokeResolver(seed); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Run runs the pipeline for dep over every policy and profile.
+func (l *Lint) Run(dep Deployment) ([]ChartResult, error) {
+	_, results, err := RunDeployment(dep, l.PoliciesDir, l.Ctx, l.Profiles, l.Hub, l.Spoke, l.Declared, l.Configs, l.TestdataDir)
+	return results, err
+}
+
 // LoadTestResources reads all .yaml files in testdataDir and returns them as a
 // flat slice of unstructured Kubernetes objects. These objects are injected into
 // the fake resolver clients so that hub/spoke templates that call lookup,
 // fromConfigMap, or fromSecret can return realistic data without a real cluster.
 //
 // Returns nil (no error) when testdataDir is empty or does not exist, so callers
-// do not need to guard against a missing testdata directory.
+// do not need to guard against a missing testdata directory. Malformed and
+// empty documents are skipped; LintFixtures reports them.
 func LoadTestResources(testdataDir string) ([]unstructured.Unstructured, error) {
 	if testdataDir == "" {
 		return nil, nil
@@ -0,0 +1,387 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// HubRequirement is an object the policies read on the hub that an operator
+// has to create before deploying AutoShift: a credential, a config bundle, a
+// CA. Lookup carries its identity, the keys read (Fields) and the policies
+// that read it.
+type HubRequirement struct {
+	Lookup
+	Profiles []string // cluster profiles whose resolution read it
+	Sides    []string // "hub" for a hub template, "spoke" for a spoke template of a policy the hub enforces on itself
+}
+
+// platformGroups are the API groups of objects OpenShift, OLM and ACM keep on
+// every hub. Reading one is no prerequisite.
+var platformGroups = map[string]bool{
+	"cluster.open-cluster-management.io":  true,
+	"internal.open-cluster-management.io": true,
+	"config.openshift.io":                 true,
+	"operator.openshift.io":               true,
+	"operators.coreos.com":                true,
+}
+
+// hubTemplateRe matches one hub template.
+var hubTemplateRe = regexp.MustCompile(`(?s)\{\{hub.*?hub\}\}`)
+
+// readsHubObjects reports whether rawYAML's templates can read an object.
+func readsHubObjects(rawYAML string) bool {
+	return strings.Contains(rawYAML, "lookup") || objectFuncRe.MatchString(rawYAML)
+}
+
+// TraceHubReads resolves rawYAML for c again, with fresh resolvers, and
+// returns the objects its templates read that the hub must hold: every
+// object a hub template read from hubResources, and, when hubCluster is not
+// nil (c is the hub itself), every Secret and ConfigMap a spoke template
+// read from hubCluster. Only objects the resources answer count — a lookup
+// that comes back empty in testdata is one the policy expects to find
+// nothing. Platform objects (platformGroups, Namespaces, Nodes) and the
+// encryption key are left out; so is, by the caller, whatever AutoShift
+// creates itself (DropCreated).
+func TraceHubReads(rawYAML string, c HubContext, hubResources, hubCluster []unstructured.Unstructured) ([]HubRequirement, error) {
+	var out []HubRequirement
+	hub, err := NewResolver(hubResources)
+	if err != nil {
+		return nil, fmt.Errorf("hub resolver: %w", err)
+	}
+	hubOut := hub.ResolvePolicy(rawYAML, c).Resolved
+	hubTemplates := strings.Join(hubTemplateRe.FindAllString(rawYAML, -1), "\n")
+	for _, u := range hub.UsedResources() {
+		if !platformObject(u) {
+			out = append(out, requirement(u, hubTemplates, "hub"))
+		}
+	}
+
+	if hubCluster == nil {
+		return out, nil
+	}
+	spoke, err := NewSpokeResolver(hubCluster)
+	if err != nil {
+		return nil, fmt.Errorf("spoke resolver: %w", err)
+	}
+	spoke.ResolveSpokePolicies(stripStringDefaults(hubOut))
+	for _, u := range spoke.UsedResources() {
+		if u.GetAPIVersion() == "v1" && (u.GetKind() == "Secret" || u.GetKind() == "ConfigMap") && !platformObject(u) {
+			out = append(out, requirement(u, rawYAML, "spoke"))
+		}
+	}
+	return out, nil
+}
+
+func platformObject(u unstructured.Unstructured) bool {
+	group, _ := splitAPIVersion(u.GetAPIVersion())
+	if platformGroups[group] {
+		return true
+	}
+	switch u.GetKind() {
+	case "Namespace", "Node":
+		return group == ""
+	case "Secret":
+		return group == "" && u.GetName() == EncryptionKeySecret
+	}
+	return false
+}
+
+func requirement(u unstructured.Unstructured, tmpl, side string) HubRequirement {
+	return HubRequirement{
+		Lookup: Lookup{
+			APIVersion: u.GetAPIVersion(),
+			Kind:       u.GetKind(),
+			Namespace:  u.GetNamespace(),
+			Name:       u.GetName(),
+			Fields:     readKeys(tmpl, u),
+		},
+		Sides: []string{side},
+	}
+}
+
+// objectFuncRe matches the template functions that read a Secret or
+// ConfigMap by namespace and name.
+var objectFuncRe = regexp.MustCompile(`\b(fromSecret|copySecretData|fromConfigMap|copyConfigMapData)\s`)
+
+// readKeys returns the field paths tmpl reads from u, best effort: what
+// LookupFields finds for lookups of its kind, plus data.<key> for every
+// fromSecret / fromConfigMap call with a literal key whose literal namespace
+// and name, if any, are u's. copySecretData and copyConfigMapData read every
+// key, so they name none.
+func readKeys(tmpl string, u unstructured.Unstructured) []string {
+	fields := map[string]bool{}
+	for _, f := range LookupFields(tmpl, u.GetAPIVersion(), u.GetKind()) {
+		fields[f] = true
+	}
+	kind := u.GetKind()
+	if u.GetAPIVersion() != "v1" || (kind != "Secret" && kind != "ConfigMap") {
+		return sortedSet(fields)
+	}
+	for _, loc := range objectFuncRe.FindAllStringSubmatchIndex(tmpl, -1) {
+		fn := tmpl[loc[2]:loc[3]]
+		if strings.HasPrefix(fn, "copy") || strings.Contains(fn, "Secret") != (kind == "Secret") {
+			continue
+		}
+		args := templateArgs(tmpl[loc[1]:], 3)
+		if len(args) == 3 && argMatches(args[0], u.GetNamespace()) && argMatches(args[1], u.GetName()) && strings.HasPrefix(args[2], `"`) {
+			fields[joinFieldPath([]string{"data", strings.Trim(args[2], `"`)})] = true
+		}
+	}
+	return sortedSet(fields)
+}
+
+// argMatches reports whether a template argument can be value: a literal
+// equal to it, or any expression.
+func argMatches(arg, value string) bool {
+	return !strings.HasPrefix(arg, `"`) || strings.Trim(arg, `"`) == value
+}
+
+// templateArgs splits the first n arguments off s: quoted strings,
+// parenthesized expressions and bare words. It stops at the end of the
+// pipeline or action.
+func templateArgs(s string, n int) []string {
+	var args []string
+	i := 0
+	for len(args) < n {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		start := i
+		switch s[i] {
+		case '"':
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+		case '(':
+			depth := 0
+			for i < len(s) {
+				if s[i] == '(' {
+					depth++
+				} else if s[i] == ')' {
+					depth--
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+				i++
+			}
+		case '|', ')', '}', '\n':
+			return args
+		default:
+			for i < len(s) && !strings.ContainsRune(" \t\n|)}", rune(s[i])) {
+				i++
+			}
+		}
+		if i > len(s) {
+			i = len(s)
+		}
+		args = append(args, s[start:i])
+	}
+	return args
+}
+
+// CreatedObjects returns the identities (see fixtureID) of the objects
+// rawYAML deploys itself: its documents other than Policies, and the objects
+// the Policies of resolved create on a cluster (any object template but a
+// mustnothave one).
+func CreatedObjects(rawYAML, resolved string) map[string]bool {
+	created := map[string]bool{}
+	for _, doc := range splitYAMLDocuments(rawYAML) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err == nil && obj != nil && obj["kind"] != "Policy" {
+			created[fixtureID(obj)] = true
+		}
+	}
+	for _, po := range PolicyObjects(resolved) {
+		created[fixtureID(po.Object)] = true
+	}
+	return created
+}
+
+// PolicyObject is an object a Policy creates on a cluster: a policy template
+// other than a ConfigurationPolicy, or the objectDefinition of a
+// ConfigurationPolicy's object template.
+type PolicyObject struct {
+	Policy   string // the Policy, see docIdentity
+	Template string // "ConfigurationPolicy <name>" for an object template, else ""
+	Object   map[string]interface{}
+}
+
+// PolicyObjects returns the objects the Policies in resolved create: every
+// policy template and object template but a mustnothave one, including those
+// of object-templates-raw.
+func PolicyObjects(resolved string) []PolicyObject {
+	var out []PolicyObject
+	for i, doc := range splitYAMLDocuments(resolved) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil || obj["kind"] != "Policy" {
+			continue
+		}
+		id := docIdentity(doc, i)
+		spec, _ := obj["spec"].(map[string]interface{})
+		policyTemplates, _ := spec["policy-templates"].([]interface{})
+		for _, pt := range policyTemplates {
+			pt, _ := pt.(map[string]interface{})
+			def, _ := pt["objectDefinition"].(map[string]interface{})
+			if def == nil {
+				continue
+			}
+			if def["kind"] != "ConfigurationPolicy" {
+				out = append(out, PolicyObject{Policy: id, Object: def})
+				continue
+			}
+			name, _ := nestedString(def, "metadata", "name")
+			cpSpec, _ := def["spec"].(map[string]interface{})
+			entries, _ := cpSpec["object-templates"].([]interface{})
+			if raw, ok := cpSpec["object-templates-raw"].(string); ok {
+				var rawEntries []interface{}
+				if err := sigsyaml.Unmarshal([]byte(raw), &rawEntries); err == nil {
+					entries = append(entries, rawEntries...)
+				}
+			}
+			for _, e := range entries {
+				e, _ := e.(map[string]interface{})
+				if strings.EqualFold(fmt.Sprint(e["complianceType"]), "mustnothave") {
+					continue
+				}
+				if od, _ := e["objectDefinition"].(map[string]interface{}); od != nil {
+					out = append(out, PolicyObject{Policy: id, Template: "ConfigurationPolicy " + name, Object: od})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// DropCreated removes from reqs the objects in created.
+func DropCreated(reqs []HubRequirement, created map[string]bool) []HubRequirement {
+	var out []HubRequirement
+	for _, req := range reqs {
+		if !created[req.fixtureID()] {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+func (req HubRequirement) fixtureID() string {
+	name := req.Name
+	if req.Namespace != "" {
+		name = req.Namespace + "/" + name
+	}
+	return req.APIVersion + " " + req.Kind + " " + name
+}
+
+// MergeHubRequirements combines the hub requirements of every chart and
+// profile in results, one entry per object, sorted by kind and identity.
+func MergeHubRequirements(results []ChartResult) []HubRequirement {
+	byID := map[string]*HubRequirement{}
+	add := func(reqs []HubRequirement) {
+		for _, req := range reqs {
+			prev, ok := byID[req.id()]
+			if !ok {
+				req := req
+				byID[req.id()] = &req
+				continue
+			}
+			prev.Fields = mergeSorted(prev.Fields, req.Fields)
+			prev.Policies = mergeSorted(prev.Policies, req.Policies)
+			prev.Profiles = mergeSorted(prev.Profiles, req.Profiles)
+			prev.Sides = mergeSorted(prev.Sides, req.Sides)
+		}
+	}
+	for _, res := range results {
+		add(res.HubRequirements)
+		for _, cr := range res.ExtraResults {
+			add(cr.HubRequirements)
+		}
+	}
+	out := make([]HubRequirement, 0, len(byID))
+	for _, req := range byID {
+		out = append(out, *req)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].id() < out[j].id()
+	})
+	return out
+}
+
+// HubChecklist renders reqs as a Markdown checklist, grouped by kind.
+func HubChecklist(reqs []HubRequirement) string {
+	var b strings.Builder
+	b.WriteString("# Hub prerequisites\n\n")
+	b.WriteString("Objects the policies read on the hub. Create them before deploying AutoShift.\n")
+	kind := ""
+	for _, req := range reqs {
+		if req.Kind != kind {
+			kind = req.Kind
+			fmt.Fprintf(&b, "\n## %s (%s)\n\n", req.Kind, req.APIVersion)
+		}
+		id := req.Name
+		if req.Namespace != "" {
+			id = req.Namespace + "/" + id
+		}
+		fmt.Fprintf(&b, "- [ ] `%s`", id)
+		if len(req.Fields) > 0 {
+			fmt.Fprintf(&b, " — keys: `%s`", strings.Join(req.Fields, "`, `"))
+		}
+		fmt.Fprintf(&b, "\n  - read by %s (%s templates), profiles: %s\n",
+			strings.Join(req.Policies, ", "), strings.Join(req.Sides, " and "), strings.Join(req.Profiles, ", "))
+	}
+	if len(reqs) == 0 {
+		b.WriteString("\nNone.\n")
+	}
+	return b.String()
+}
+
+// HubManifest renders the reqs profile reads as a skeleton manifest: one
+// object per requirement, with StubPlaceholder (base64 in a Secret's data) at
+// every key the policies read.
+func HubManifest(reqs []HubRequirement, profile string) string {
+	var docs []string
+	for _, req := range reqs {
+		found := false
+		for _, p := range req.Profiles {
+			found = found || p == profile
+		}
+		if !found {
+			continue
+		}
+		data, _ := sigsyaml.Marshal(stubObject(req.Lookup)) // maps of strings always marshal
+		header := fmt.Sprintf("# Read by %s.\n", strings.Join(req.Policies, ", "))
+		if len(req.Fields) > 0 {
+			header += fmt.Sprintf("# Replace each %s with the real value.\n", StubPlaceholder)
+		}
+		docs = append(docs, header+string(data))
+	}
+	if len(docs) == 0 {
+		return ""
+	}
+	return "---\n" + strings.Join(docs, "---\n")
+}
+
+// labelRequirements sets the policy and profile of reqs.
+func labelRequirements(reqs []HubRequirement, policy, profile string) []HubRequirement {
+	for i := range reqs {
+		reqs[i].Policies = []string{policy}
+		reqs[i].Profiles = []string{profile}
+	}
+	return reqs
+}
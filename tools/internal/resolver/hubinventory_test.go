@@ -0,0 +1,134 @@
+package resolver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestTraceHubReads(t *testing.T) {
+	object := func(kind, ns, name string, data map[string]string) unstructured.Unstructured {
+		d := map[string]interface{}{}
+		for k, v := range data {
+			if kind == "Secret" {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			d[k] = v
+		}
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1", "kind": kind,
+			"metadata": map[string]interface{}{"name": name, "namespace": ns},
+			"data":     d,
+		}}
+	}
+	hubRes := []unstructured.Unstructured{
+		object("Secret", "vault", "token", map[string]string{"token": "t", "other": "o"}),
+		object("ConfigMap", "aap", "settings", map[string]string{"url": "https://aap"}),
+		object("Secret", "app", "copied", map[string]string{"token": "t"}),
+		{Object: map[string]interface{}{
+			"apiVersion": "cluster.open-cluster-management.io/v1", "kind": "ManagedCluster",
+			"metadata": map[string]interface{}{"name": "hub"},
+		}},
+	}
+	hubCluster := append([]unstructured.Unstructured{
+		object("Secret", "openshift-config", "api-tls", map[string]string{"tls.crt": "c"}),
+		EncryptionKeyObject("hub"),
+	}, hubRes...)
+
+	raw := configurationPolicy(`object-templates:
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: Secret
+    metadata:
+      name: copied
+      namespace: app
+    data:
+      token: '{{hub fromSecret "vault" "token" "token" hub}}'
+      cert: '{{ fromSecret "openshift-config" "api-tls" "tls.crt" }}'
+      again: '{{ fromSecret "app" "copied" "token" }}'
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: aap
+      namespace: app
+    data:
+      url: '{{hub (lookup "v1" "ConfigMap" "aap" "settings").data.url hub}}'
+      owner: '{{hub (lookup "cluster.open-cluster-management.io/v1" "ManagedCluster" "" "hub").metadata.name hub}}'
+`)
+	ctx := HubContext{ManagedClusterName: "hub"}
+	fmtReqs := func(reqs []HubRequirement) string {
+		var lines []string
+		for _, req := range reqs {
+			lines = append(lines, fmt.Sprintf("%s %v %v", req.Lookup, req.Fields, req.Sides))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	reqs, err := TraceHubReads(raw, ctx, hubRes, nil)
+	if err != nil {
+		t.Fatalf("TraceHubReads: %v", err)
+	}
+	got := fmtReqs(reqs)
+	want := strings.Join([]string{
+		`lookup "v1" "Secret" "vault" "token" [data.token] [hub]`,
+		`lookup "v1" "ConfigMap" "aap" "settings" [data.url] [hub]`,
+	}, "\n")
+	if got != want {
+		t.Errorf("managed cluster reads:\n%s\nwant:\n%s", got, want)
+	}
+
+	// On the hub itself the spoke templates read it too; the Secret the policy
+	// creates is no prerequisite.
+	reqs, err = TraceHubReads(raw, ctx, hubRes, hubCluster)
+	if err != nil {
+		t.Fatalf("TraceHubReads on the hub: %v", err)
+	}
+	reqs = DropCreated(labelRequirements(reqs, "stable/test", PrimaryProfile), CreatedObjects(raw, raw))
+	res := []ChartResult{{ContextResult: ContextResult{HubRequirements: reqs}}}
+	got = fmtReqs(MergeHubRequirements(res))
+	want = strings.Join([]string{
+		`lookup "v1" "ConfigMap" "aap" "settings" [data.url] [hub]`,
+		`lookup "v1" "Secret" "openshift-config" "api-tls" [data["tls.crt"]] [spoke]`,
+		`lookup "v1" "Secret" "vault" "token" [data.token] [hub]`,
+	}, "\n")
+	if got != want {
+		t.Errorf("hub reads:\n%s\nwant:\n%s", got, want)
+	}
+
+	merged := MergeHubRequirements(res)
+	checklist := HubChecklist(merged)
+	for _, line := range []string{
+		"## Secret (v1)",
+		"- [ ] `vault/token` — keys: `data.token`",
+		"  - read by stable/test (hub templates), profiles: primary",
+	} {
+		if !strings.Contains(checklist, line+"\n") {
+			t.Errorf("checklist lacks %q:\n%s", line, checklist)
+		}
+	}
+	manifest := HubManifest(merged, PrimaryProfile)
+	if !strings.Contains(manifest, "  token: "+base64.StdEncoding.EncodeToString([]byte(StubPlaceholder))) {
+		t.Errorf("manifest lacks the Secret placeholder:\n%s", manifest)
+	}
+	if HubManifest(merged, "managed-aws") != "" {
+		t.Errorf("manifest for a profile that reads nothing should be empty")
+	}
+}
+
+func TestTemplateArgs(t *testing.T) {
+	for in, want := range map[string][]string{
+		` "ns" "name" "key" }}`:                               {`"ns"`, `"name"`, `"key"`},
+		` .PolicyMetadata.namespace (print "a" (x)) "config"`: {`.PolicyMetadata.namespace`, `(print "a" (x))`, `"config"`},
+		` $ns $name | base64dec }}`:                           {`$ns`, `$name`},
+	} {
+		if got := templateArgs(in, 3); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("templateArgs(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
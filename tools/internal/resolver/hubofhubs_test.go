@@ -0,0 +1,161 @@
+//go:build integration
+
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/labels"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestPipeline_HubOfHubs runs the pipeline once per tier of the topology in
+// docs/hub-of-hubs.md: a hub-of-hubs "autoshift" instance managing hub1 and
+// hub2, each of which runs its own instance for its spokes.
+//
+// TestPipeline_EndToEnd renders every policy for one "autoshift" release in
+// policies-autoshift, against a hub that manages itself. Nothing there can
+// notice a namespace hardcoded to policies-autoshift, or a placement that only
+// makes sense on a single hub. Here each tier renders the autoshift chart with
+// its own release name and values files, and every policy is rendered with
+// what that render hands it — the ApplicationSet's plugin env and
+// valuesObject, the cluster-labels and cluster-config-maps Applications'
+// valuesObject — then resolved in the tier's policy namespace. The resolved
+// Placements are evaluated against the clusters the tier's ACM manages, and
+// the test fails when a policy lands on a cluster its tier cannot or must not
+// target (see CheckTiers).
+//
+// Resolution and YAML quality are TestPipeline_EndToEnd's job; a tier only
+// fails here when a policy does not render at all.
+func TestPipeline_HubOfHubs(t *testing.T) {
+	env := newTierEnv(t)
+
+	var runs []TierRun
+	for _, tier := range HubOfHubsTiers() {
+		run, ok := env.run(t, tier)
+		if !ok {
+			continue
+		}
+		runs = append(runs, run)
+		requireLanding(t, run)
+	}
+
+	for _, v := range CheckTiers(runs) {
+		t.Errorf("%s", v)
+	}
+}
+
+// tierEnv is what every tier run shares: the repo inputs and the example
+// configs the synthetic ConfigMaps are built from.
+type tierEnv struct {
+	chartDir, policiesDir, valuesDir, testdataDir string
+	work                                          string
+	declared                                      map[string]*labels.Declared
+	configs                                       *ExampleConfigs
+	testResources                                 []unstructured.Unstructured
+	ctx                                           HubContext
+}
+
+func newTierEnv(t *testing.T) *tierEnv {
+	t.Helper()
+	root := repoRoot(t)
+	e := &tierEnv{
+		chartDir:    filepath.Join(root, "autoshift"),
+		policiesDir: filepath.Join(root, "policies"),
+		valuesDir:   filepath.Join(root, "autoshift", "values"),
+		testdataDir: filepath.Join(root, "tools", "testdata"),
+		work:        t.TempDir(),
+	}
+	for _, dir := range []string{e.chartDir, e.policiesDir, e.valuesDir} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Skipf("required directory missing, skipping: %s", dir)
+		}
+	}
+
+	var err error
+	if e.declared, err = labels.ExtractDeclaredFromTree(e.valuesDir, false); err != nil {
+		t.Fatalf("ExtractDeclaredFromTree: %v", err)
+	}
+	if e.configs, err = ExtractExampleConfigs(e.valuesDir); err != nil {
+		t.Fatalf("ExtractExampleConfigs: %v", err)
+	}
+	if e.testResources, err = LoadTestResources(e.testdataDir); err != nil {
+		t.Fatalf("could not load testdata: %v", err)
+	}
+	e.ctx = HubContext{
+		ManagedClusterName:   "lint-cluster",
+		ManagedClusterLabels: BuildSyntheticLabels(e.declared),
+	}
+	return e
+}
+
+// run renders tier's autoshift chart and every policy for it, resolved in the
+// tier's policy namespace. A policy that does not render, or that names another
+// release's policy namespace, fails the test; ok is false when the chart
+// itself does not render.
+func (e *tierEnv) run(t *testing.T, tier Tier) (run TierRun, ok bool) {
+	t.Helper()
+	tr, err := RenderTier(e.chartDir, e.valuesDir, e.work, tier)
+	if err != nil {
+		t.Errorf("%v", err)
+		return TierRun{}, false
+	}
+	ns := tr.Deployment.Namespace()
+
+	syntheticCMs, err := GenerateSyntheticConfigMaps(e.configs, e.ctx.ManagedClusterName, ns)
+	if err != nil {
+		t.Fatalf("tier %s: GenerateSyntheticConfigMaps: %v", tier.Name, err)
+	}
+	seed := append(syntheticCMs, e.testResources...)
+	r, err := NewResolver(seed)
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	spokeR, err := NewSpokeResolver(seed)
+	if err != nil {
+		t.Fatalf("NewSpokeResolver: %v", err)
+	}
+
+	_, results, err := RunDeployment(tr.Deployment, e.policiesDir, e.ctx, nil, r, spokeR, e.declared, e.configs, e.testdataDir)
+	if err != nil {
+		t.Fatalf("tier %s: RunDeployment: %v", tier.Name, err)
+	}
+
+	var resolved strings.Builder
+	for _, res := range results {
+		if res.Err != nil {
+			t.Errorf("tier %s: %s: %v", tier.Name, res.Policy, res.Err)
+			continue
+		}
+		for _, leak := range ForeignNamespaces(ns, res.ResolvedYAML) {
+			t.Errorf("tier %s: %s: %s", tier.Name, res.Policy, leak)
+		}
+		resolved.WriteString(res.ResolvedYAML)
+		resolved.WriteString("\n---\n")
+	}
+
+	run = NewTierRun(tr, resolved.String())
+	t.Logf("tier %s (%s): %d clusters, %d placements, %d policy placements",
+		tier.Name, ns, len(tr.Fleet), len(run.Placements.Placements), len(run.Placements.Decide(run.Clusters())))
+	return run, true
+}
+
+// requireLanding fails the test for each cluster of run's fleet no policy
+// lands on. A tier whose policies land nowhere proves nothing about where they
+// land.
+func requireLanding(t *testing.T, run TierRun) {
+	t.Helper()
+	landed := map[string]bool{}
+	for _, d := range run.Placements.Decide(run.Clusters()) {
+		landed[d.Cluster.Name] = true
+	}
+	for _, c := range run.Fleet {
+		if !landed[c.Name] {
+			t.Errorf("tier %s: no policy lands on %s (clusterset %s) — check the ManagedClusterSetBindings policy-foundation renders in %s",
+				run.Tier.Name, c.Name, c.ClusterSet, run.Deployment.Namespace())
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/validate/content"
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	"k8s.io/apimachinery/pkg/util/validation"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// nameRules are the kinds whose metadata.name the API server validates by
+// other than the DNS-1123 subdomain rule most kinds use.
+var nameRules = map[string]func(string) []string{
+	"Namespace":                content.IsDNS1123Label,
+	"Project":                  content.IsDNS1123Label,
+	"Service":                  validation.IsDNS1035Label,
+	"ManagedClusterSet":        content.IsDNS1123Label, // stamped as a label value
+	"ManagedClusterSetBinding": content.IsDNS1123Label, // named after its clusterset
+	"Role":                     path.IsValidPathSegmentName,
+	"ClusterRole":              path.IsValidPathSegmentName,
+	"RoleBinding":              path.IsValidPathSegmentName,
+	"ClusterRoleBinding":       path.IsValidPathSegmentName,
+	"User":                     path.IsValidPathSegmentName,
+	"Group":                    path.IsValidPathSegmentName,
+}
+
+// CheckIdentifiers reports the identifiers in resolved the API server would
+// reject: the metadata.name, metadata.namespace, label keys and values and
+// annotation keys of every document, of every ConfigurationPolicy and of
+// every object a Policy creates (see PolicyObjects). Names built from labels
+// and cluster names only fail when the spoke enforces them, so each is
+// reported against its Policy. An identifier still holding a template or
+// <no value> is left to the YAML check.
+func CheckIdentifiers(resolved string) []string {
+	created := map[string][]PolicyObject{}
+	for _, po := range PolicyObjects(resolved) {
+		created[po.Policy] = append(created[po.Policy], po)
+	}
+	var errs []string
+	for i, doc := range splitYAMLDocuments(resolved) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil || obj == nil {
+			continue
+		}
+		id := docIdentity(doc, i)
+		errs = append(errs, identifierErrors(id+": ", obj)...)
+		if obj["kind"] != "Policy" {
+			continue
+		}
+		spec, _ := obj["spec"].(map[string]interface{})
+		policyTemplates, _ := spec["policy-templates"].([]interface{})
+		for _, pt := range policyTemplates {
+			ptm, _ := pt.(map[string]interface{})
+			def, _ := ptm["objectDefinition"].(map[string]interface{})
+			if def != nil && def["kind"] == "ConfigurationPolicy" {
+				errs = append(errs, identifierErrors(id+": ", def)...)
+			}
+		}
+		for _, po := range created[id] {
+			where := po.Policy + ": "
+			if po.Template != "" {
+				where += po.Template + ": "
+			}
+			errs = append(errs, identifierErrors(where, po.Object)...)
+		}
+	}
+	return errs
+}
+
+// identifierErrors validates the metadata of obj, prefixing each finding with
+// where and the object's kind and name.
+func identifierErrors(where string, obj map[string]interface{}) []string {
+	kind, _ := obj["kind"].(string)
+	meta, _ := obj["metadata"].(map[string]interface{})
+	name, _ := meta["name"].(string)
+	ref := kind
+	if name != "" {
+		ref += "/" + name
+	}
+	var errs []string
+	check := func(field, value string, msgs []string) {
+		if strings.Contains(value, "{{") || strings.Contains(value, "<no value>") {
+			return
+		}
+		for _, m := range msgs {
+			errs = append(errs, fmt.Sprintf("%s%s: %s %q: %s", where, ref, field, value, m))
+		}
+	}
+
+	if name != "" {
+		rule := nameRules[kind]
+		if rule == nil {
+			rule = content.IsDNS1123Subdomain
+		}
+		check("metadata.name", name, rule(name))
+	}
+	if ns, _ := meta["namespace"].(string); ns != "" {
+		check("metadata.namespace", ns, content.IsDNS1123Label(ns))
+	}
+	labels, _ := meta["labels"].(map[string]interface{})
+	for _, k := range sortedKeys(labels) {
+		check("label key", k, content.IsLabelKey(k))
+		v, ok := labels[k].(string)
+		if !ok {
+			if labels[k] != nil {
+				check("label "+k, fmt.Sprint(labels[k]), []string{"must be a string"})
+			}
+			continue
+		}
+		check("label "+k, v, content.IsLabelValue(v))
+	}
+	annotations, _ := meta["annotations"].(map[string]interface{})
+	for _, k := range sortedKeys(annotations) {
+		// The API server validates annotation keys case-insensitively.
+		check("annotation key", k, content.IsLabelKey(strings.ToLower(k)))
+	}
+	return errs
+}
@@ -0,0 +1,81 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckIdentifiers(t *testing.T) {
+	resolved := `apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+metadata:
+  name: policy-test
+  namespace: policies-autoshift
+  annotations:
+    policy.open-cluster-management.io/standards: NIST SP 800-53
+spec:
+  policy-templates:
+  - objectDefinition:
+      apiVersion: policy.open-cluster-management.io/v1
+      kind: ConfigurationPolicy
+      metadata:
+        name: test_config
+      spec:
+        object-templates:
+        - complianceType: musthave
+          objectDefinition:
+            apiVersion: v1
+            kind: Secret
+            metadata:
+              name: Hub-aws-creds
+              namespace: Hub
+              labels:
+                app.kubernetes.io/instance: nmstate-host-worker-0.example.com-with-a-name-too-long-for-a-label
+                bad/key/: x
+              annotations:
+                Example.COM/Owner: ops
+        - complianceType: musthave
+          objectDefinition:
+            apiVersion: rbac.authorization.k8s.io/v1
+            kind: ClusterRole
+            metadata:
+              name: system:openshift:scc:privileged
+        - complianceType: musthave
+          objectDefinition:
+            apiVersion: v1
+            kind: Namespace
+            metadata:
+              name: worker.example.com
+        - complianceType: mustnothave
+          objectDefinition:
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+              name: Old_Name
+---
+apiVersion: cluster.open-cluster-management.io/v1beta1
+kind: Placement
+metadata:
+  name: placement-test
+  labels:
+    enabled: true
+`
+	got := CheckIdentifiers(resolved)
+	want := []string{
+		`Policy/policy-test (document 1): ConfigurationPolicy/test_config: metadata.name "test_config": a lowercase RFC 1123 subdomain`,
+		`Policy/policy-test (document 1): ConfigurationPolicy test_config: Secret/Hub-aws-creds: metadata.name "Hub-aws-creds": a lowercase RFC 1123 subdomain`,
+		`Policy/policy-test (document 1): ConfigurationPolicy test_config: Secret/Hub-aws-creds: metadata.namespace "Hub": a lowercase RFC 1123 label`,
+		`Policy/policy-test (document 1): ConfigurationPolicy test_config: Secret/Hub-aws-creds: label app.kubernetes.io/instance "nmstate-host-worker-0.example.com-with-a-name-too-long-for-a-label": must be no more than 63 bytes`,
+		`Policy/policy-test (document 1): ConfigurationPolicy test_config: Secret/Hub-aws-creds: label key "bad/key/": a valid label key`,
+		`Policy/policy-test (document 1): ConfigurationPolicy test_config: Namespace/worker.example.com: metadata.name "worker.example.com": must not contain dots`,
+		`Placement/placement-test (document 2): Placement/placement-test: label enabled "true": must be a string`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("CheckIdentifiers = %d findings, want %d:\n%s", len(got), len(want), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("finding %d:\n%s\nwant prefix:\n%s", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package resolver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// HostMap rewrites the hostnames and IP addresses of a real environment into
+// the ones testdata uses. It is loaded from a YAML map of real value to
+// replacement:
+//
+//	prod-east.corp.example.net: test-cluster.test.example.com
+//	10.42.7.12: 10.0.0.10
+//
+// Keys are matched as literal strings, so each IP address is mapped on its
+// own: a CIDR key only rewrites that CIDR where it is written out.
+type HostMap struct {
+	from, to []string // longest first, so a host is rewritten before its domain
+}
+
+// LoadHostMap reads a HostMap from path.
+func LoadHostMap(path string) (HostMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return HostMap{}, err
+	}
+	var m map[string]string
+	if err := sigsyaml.Unmarshal(data, &m); err != nil {
+		return HostMap{}, fmt.Errorf("%s: want a map of real hostname or IP to replacement: %w", path, err)
+	}
+	return NewHostMap(m), nil
+}
+
+// NewHostMap returns the HostMap that rewrites each key of m to its value.
+func NewHostMap(m map[string]string) HostMap {
+	var h HostMap
+	for from := range m {
+		if from != "" {
+			h.from = append(h.from, from)
+		}
+	}
+	sort.Slice(h.from, func(i, j int) bool {
+		if len(h.from[i]) != len(h.from[j]) {
+			return len(h.from[i]) > len(h.from[j])
+		}
+		return h.from[i] < h.from[j]
+	})
+	for _, from := range h.from {
+		h.to = append(h.to, m[from])
+	}
+	return h
+}
+
+// Rewrite replaces every mapped hostname and IP in s. A match must not be
+// part of a longer name or number: 10.0.0.1 leaves 10.0.0.12 alone, and
+// corp.example.net leaves mycorp.example.net alone but rewrites the domain of
+// api.corp.example.net.
+func (h HostMap) Rewrite(s string) string {
+	for i, from := range h.from {
+		var b strings.Builder
+		rest := s
+		for {
+			j := strings.Index(rest, from)
+			if j < 0 {
+				break
+			}
+			end := j + len(from)
+			if (j > 0 && isHostChar(rest[j-1])) || (end < len(rest) && isHostChar(rest[end])) {
+				b.WriteString(rest[:j+1])
+				rest = rest[j+1:]
+				continue
+			}
+			b.WriteString(rest[:j])
+			b.WriteString(h.to[i])
+			rest = rest[end:]
+		}
+		b.WriteString(rest)
+		s = b.String()
+	}
+	return s
+}
+
+func isHostChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-'
+}
+
+// replacement reports whether ip is an address h rewrites to: a replacement
+// value, or an address written out whole in one (a CIDR). 10.0.0.1 is not a
+// replacement because 10.0.0.10 is.
+func (h HostMap) replacement(ip string) bool {
+	for _, to := range h.to {
+		for _, produced := range ipv4Re.FindAllString(to, -1) {
+			if produced == ip {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// producedHost reports whether host is a replacement h rewrites to, or a name
+// under one.
+func (h HostMap) producedHost(host string) bool {
+	for _, to := range h.to {
+		if host == to || strings.HasSuffix(host, "."+to) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportedSecretValue returns the placeholder an imported Secret gets for key
+// in its data (base64-encoded) or stringData, so no credential leaves the
+// cluster.
+func ImportedSecretValue(key string) string {
+	return StubPlaceholder + "-" + key
+}
+
+// importedMetadata lists the metadata fields a cluster sets on every object;
+// none of them means anything to a lookup.
+var importedMetadata = []string{
+	"managedFields", "uid", "resourceVersion", "generation", "creationTimestamp",
+	"selfLink", "ownerReferences", "deletionTimestamp", "deletionGracePeriodSeconds",
+}
+
+// importedAnnotations lists annotations an import drops: the last applied
+// configuration can hold a Secret's data in clear text.
+var importedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// importedStatus lists status fields that change on every read or identify
+// the machine, by path below status. Conditions keep their type, status,
+// reason and message, and lose their timestamps and observedGeneration.
+var importedStatus = [][]string{
+	{"observedGeneration"},
+	{"images"},
+	{"volumesInUse"},
+	{"volumesAttached"},
+	{"daemonEndpoints"},
+	{"nodeInfo", "machineID"},
+	{"nodeInfo", "systemUUID"},
+	{"nodeInfo", "bootID"},
+}
+
+var conditionNoise = []string{"lastHeartbeatTime", "lastProbeTime", "lastTransitionTime", "lastUpdateTime", "observedGeneration"}
+
+// SanitizeObject makes obj, as `oc get -o yaml` prints it, into a testdata
+// fixture: the metadata the cluster fills in, the last applied configuration
+// and the status noise are dropped, a Secret's values become ImportedSecretValue, and
+// every string — keys included — goes through hosts.
+func SanitizeObject(obj map[string]interface{}, hosts HostMap) map[string]interface{} {
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		for _, f := range importedMetadata {
+			delete(metadata, f)
+		}
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			for _, a := range importedAnnotations {
+				delete(annotations, a)
+			}
+			if len(annotations) == 0 {
+				delete(metadata, "annotations")
+			}
+		}
+	}
+	if status, ok := obj["status"].(map[string]interface{}); ok {
+		for _, path := range importedStatus {
+			parent := status
+			for _, k := range path[:len(path)-1] {
+				parent, _ = parent[k].(map[string]interface{})
+			}
+			delete(parent, path[len(path)-1]) // no-op on a nil map
+		}
+		stripConditionNoise(status)
+		if len(status) == 0 {
+			delete(obj, "status")
+		}
+	}
+	if obj["kind"] == "Secret" && obj["apiVersion"] == "v1" {
+		for _, field := range []string{"data", "stringData"} {
+			values, _ := obj[field].(map[string]interface{})
+			for key := range values {
+				v := ImportedSecretValue(key)
+				if field == "data" {
+					v = base64.StdEncoding.EncodeToString([]byte(v))
+				}
+				values[key] = v
+			}
+		}
+	}
+	return rewriteHosts(obj, hosts).(map[string]interface{})
+}
+
+// stripConditionNoise drops the timestamps of every conditions list below v.
+func stripConditionNoise(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if conds, ok := child.([]interface{}); ok && k == "conditions" {
+				for _, c := range conds {
+					if c, ok := c.(map[string]interface{}); ok {
+						for _, f := range conditionNoise {
+							delete(c, f)
+						}
+					}
+				}
+			}
+			stripConditionNoise(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			stripConditionNoise(child)
+		}
+	}
+}
+
+func rewriteHosts(v interface{}, hosts HostMap) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			out[hosts.Rewrite(k)] = rewriteHosts(child, hosts)
+		}
+		return out
+	case []interface{}:
+		for i, child := range v {
+			v[i] = rewriteHosts(child, hosts)
+		}
+		return v
+	case string:
+		return hosts.Rewrite(v)
+	default:
+		return v
+	}
+}
+
+var ipv4Re = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+// UnmappedIPs returns the IPv4 addresses left in a sanitized obj that hosts
+// did not produce — most likely real ones the mapping file misses. Loopback
+// and unspecified addresses are not reported.
+func UnmappedIPs(obj map[string]interface{}, hosts HostMap) []string {
+	data, err := sigsyaml.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	found := map[string]bool{}
+	for _, ip := range ipv4Re.FindAllString(string(data), -1) {
+		if ip == "0.0.0.0" || strings.HasPrefix(ip, "127.") || hosts.replacement(ip) {
+			continue
+		}
+		found[ip] = true
+	}
+	return sortedSet(found)
+}
+
+// hostRe matches a dotted hostname whose last label is a top-level domain.
+var hostRe = regexp.MustCompile(`\b[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*\.[A-Za-z]{2,63}\b`)
+
+// nodeHostAddresses are the Node address types that hold a hostname.
+var nodeHostAddresses = map[string]bool{"Hostname": true, "InternalDNS": true, "ExternalDNS": true}
+
+// clusterHosts returns the hostnames that name obj's cluster or its
+// machines: a Node's name, hostname label and DNS addresses, and every
+// hostname in the spec and status of the cluster's Infrastructure, DNS and
+// Ingress config (API server URLs, base and apps domains, route hosts).
+func clusterHosts(obj map[string]interface{}) []string {
+	var values []string
+	apiVersion, _ := obj["apiVersion"].(string)
+	switch kind, _ := obj["kind"].(string); {
+	case apiVersion == "v1" && kind == "Node":
+		name, _ := nestedString(obj, "metadata", "name")
+		hostLabel, _ := nestedString(obj, "metadata", "labels", "kubernetes.io/hostname")
+		values = append(values, name, hostLabel)
+		addresses, _, _ := unstructured.NestedSlice(obj, "status", "addresses")
+		for _, a := range addresses {
+			a, _ := a.(map[string]interface{})
+			if t, _ := a["type"].(string); nodeHostAddresses[t] {
+				addr, _ := a["address"].(string)
+				values = append(values, addr)
+			}
+		}
+	case apiVersion == "config.openshift.io/v1" && (kind == "Infrastructure" || kind == "DNS" || kind == "Ingress"):
+		walkStrings(map[string]interface{}{"spec": obj["spec"], "status": obj["status"]}, "", func(_, s string) {
+			values = append(values, hostRe.FindAllString(s, -1)...)
+		})
+	}
+	var hosts []string
+	for _, v := range values {
+		if v != "" && !ipv4Re.MatchString(v) {
+			hosts = append(hosts, strings.ToLower(v))
+		}
+	}
+	return hosts
+}
+
+// ClusterDomains returns the base and apps domains a sanitized obj declares:
+// the baseDomain of a DNS config and the domain and appsDomain of an Ingress
+// config. Every hostname under one names the real cluster.
+func ClusterDomains(obj map[string]interface{}) []string {
+	if obj["apiVersion"] != "config.openshift.io/v1" {
+		return nil
+	}
+	var fields [][]string
+	switch obj["kind"] {
+	case "DNS":
+		fields = [][]string{{"spec", "baseDomain"}}
+	case "Ingress":
+		fields = [][]string{{"spec", "domain"}, {"spec", "appsDomain"}}
+	}
+	var domains []string
+	for _, f := range fields {
+		if d, _ := nestedString(obj, f...); d != "" {
+			domains = append(domains, strings.ToLower(d))
+		}
+	}
+	return domains
+}
+
+// UnmappedHosts returns the hostnames left in a sanitized obj that hosts did
+// not produce: those naming the cluster or its machines (see clusterHosts),
+// and any hostname anywhere in obj under one of domains (see ClusterDomains).
+// Like UnmappedIPs, these are most likely real names the mapping file misses.
+func UnmappedHosts(obj map[string]interface{}, domains []string, hosts HostMap) []string {
+	found := map[string]bool{}
+	for _, h := range clusterHosts(obj) {
+		if !hosts.producedHost(h) {
+			found[h] = true
+		}
+	}
+	var real []string
+	for _, d := range domains {
+		if !hosts.producedHost(d) {
+			real = append(real, d)
+		}
+	}
+	if len(real) > 0 {
+		walkStrings(obj, "", func(_, s string) {
+			for _, h := range hostRe.FindAllString(s, -1) {
+				h = strings.ToLower(h)
+				for _, d := range real {
+					if (h == d || strings.HasSuffix(h, "."+d)) && !hosts.producedHost(h) {
+						found[h] = true
+					}
+				}
+			}
+		})
+	}
+	return sortedSet(found)
+}
+
+// ReadClusterExport reads the objects in an `oc get -o yaml` (or -o json)
+// dump: one object, a List of them, or several documents of either.
+func ReadClusterExport(path string) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var objs []map[string]interface{}
+	for i, doc := range splitYAMLDocuments(string(data)) {
+		if !hasContent(doc) {
+			continue
+		}
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("%s document %d: %w", path, i+1, err)
+		}
+		if kind, _ := obj["kind"].(string); strings.HasSuffix(kind, "List") && obj["items"] != nil {
+			items, _ := obj["items"].([]interface{})
+			for _, item := range items {
+				if item, ok := item.(map[string]interface{}); ok {
+					objs = append(objs, item)
+				}
+			}
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// ImportFiles groups sanitized objects into testdata files, one per kind, named
+// like stub files but with an imported- prefix. Objects are ordered by
+// identity, so importing the same dump twice gives the same files.
+func ImportFiles(objs []map[string]interface{}) []StubFile {
+	sorted := append([]map[string]interface{}(nil), objs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return stubID(sorted[i]) < stubID(sorted[j]) })
+
+	byFile := map[string]*StubFile{}
+	seen := map[string]bool{}
+	for _, obj := range sorted {
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		id := stubID(obj)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		name := resourceFileName("imported-", apiVersion, kind)
+		f := byFile[name]
+		if f == nil {
+			f = &StubFile{Name: name}
+			byFile[name] = f
+		}
+		data, err := sigsyaml.Marshal(obj)
+		if err != nil {
+			continue // unmarshalled from YAML, so it marshals back
+		}
+		f.IDs = append(f.IDs, id)
+		f.Docs = append(f.Docs, string(data))
+	}
+
+	names := make([]string, 0, len(byFile))
+	for name := range byFile {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]StubFile, 0, len(names))
+	for _, name := range names {
+		out = append(out, *byFile[name])
+	}
+	return out
+}
+
+// ClusterExportPaths expands paths into the .yaml, .yml and .json files they
+// name or directly contain.
+func ClusterExportPaths(paths []string) ([]string, error) {
+	var out []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			out = append(out, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					out = append(out, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,199 @@
+package resolver
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+func TestHostMap_Rewrite(t *testing.T) {
+	hosts := NewHostMap(map[string]string{
+		"corp.example.net":      "test.example.com",
+		"east.corp.example.net": "test-cluster.test.example.com",
+		"10.42.7.1":             "10.0.0.10",
+	})
+	for in, want := range map[string]string{
+		"api.east.corp.example.net:6443": "api.test-cluster.test.example.com:6443",
+		"registry.corp.example.net":      "registry.test.example.com",
+		"mycorp.example.net":             "mycorp.example.net",
+		"10.42.7.1":                      "10.0.0.10",
+		"10.42.7.12 and 110.42.7.1":      "10.42.7.12 and 110.42.7.1",
+		"https://10.42.7.1/healthz":      "https://10.0.0.10/healthz",
+	} {
+		if got := hosts.Rewrite(in); got != want {
+			t.Errorf("Rewrite(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSanitizeObject(t *testing.T) {
+	var objs []map[string]interface{}
+	dir := t.TempDir()
+	path := mustWriteFile(t, dir, "dump.yaml", `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: worker-0.east.corp.example.net
+    uid: 0a1b
+    resourceVersion: "123"
+    creationTimestamp: "2024-01-01T00:00:00Z"
+    managedFields: [{manager: kubelet}]
+    labels:
+      kubernetes.io/hostname: worker-0.east.corp.example.net
+  status:
+    addresses:
+    - address: 10.42.7.1
+      type: InternalIP
+    conditions:
+    - type: Ready
+      status: "True"
+      lastHeartbeatTime: "2024-01-01T00:00:00Z"
+    images: [{names: [quay.io/x]}]
+    nodeInfo:
+      machineID: abc
+      kubeletVersion: v1.30.0
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+  namespace: ns
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: '{"data":{"password":"aHVudGVyMg=="}}'
+data:
+  password: aHVudGVyMg==
+`)
+	raw, err := ReadClusterExport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := NewHostMap(map[string]string{"east.corp.example.net": "test-cluster.test.example.com", "10.42.7.1": "10.0.0.10"})
+	for _, obj := range raw {
+		objs = append(objs, SanitizeObject(obj, hosts))
+	}
+	if len(objs) != 2 {
+		t.Fatalf("read %d objects, want 2", len(objs))
+	}
+
+	got, _ := sigsyaml.Marshal(objs[0])
+	want := `apiVersion: v1
+kind: Node
+metadata:
+  labels:
+    kubernetes.io/hostname: worker-0.test-cluster.test.example.com
+  name: worker-0.test-cluster.test.example.com
+status:
+  addresses:
+  - address: 10.0.0.10
+    type: InternalIP
+  conditions:
+  - status: "True"
+    type: Ready
+  nodeInfo:
+    kubeletVersion: v1.30.0
+`
+	if string(got) != want {
+		t.Errorf("sanitized Node:\n%s\nwant:\n%s", got, want)
+	}
+	if ips := UnmappedIPs(objs[0], hosts); len(ips) != 0 {
+		t.Errorf("UnmappedIPs = %v", ips)
+	}
+
+	secret := objs[1]
+	if _, ok := secret["metadata"].(map[string]interface{})["annotations"]; ok {
+		t.Error("last-applied-configuration kept")
+	}
+	data := secret["data"].(map[string]interface{})
+	if v, _ := base64.StdEncoding.DecodeString(data["password"].(string)); string(v) != ImportedSecretValue("password") {
+		t.Errorf("Secret data.password = %q", v)
+	}
+}
+
+func TestUnmappedIPs(t *testing.T) {
+	hosts := NewHostMap(map[string]string{"10.42.7.1": "10.0.0.10", "10.42.7.3": "192.168.100.15", "10.42.8.0/24": "10.1.0.0/24"})
+	obj := SanitizeObject(map[string]interface{}{
+		"apiVersion": "v1", "kind": "Endpoints", "metadata": map[string]interface{}{"name": "e"},
+		"subsets": []interface{}{"10.42.7.1", "10.42.7.2", "10.42.7.3", "10.42.8.0/24", "127.0.0.1", "0.0.0.0"},
+		// Real addresses that are prefixes of a replacement value.
+		"notes": []interface{}{"10.0.0.1", "192.168.100.1"},
+	}, hosts)
+	if got := UnmappedIPs(obj, hosts); !reflect.DeepEqual(got, []string{"10.0.0.1", "10.42.7.2", "192.168.100.1"}) {
+		t.Errorf("UnmappedIPs = %v", got)
+	}
+}
+
+func TestImportFiles(t *testing.T) {
+	cv := map[string]interface{}{"apiVersion": "config.openshift.io/v1", "kind": "ClusterVersion", "metadata": map[string]interface{}{"name": "version"}}
+	nodeB := map[string]interface{}{"apiVersion": "v1", "kind": "Node", "metadata": map[string]interface{}{"name": "b"}}
+	nodeA := map[string]interface{}{"apiVersion": "v1", "kind": "Node", "metadata": map[string]interface{}{"name": "a"}}
+	files := ImportFiles([]map[string]interface{}{nodeB, cv, nodeA, nodeA})
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if want := []string{"imported-clusterversions.config.openshift.io.yaml", "imported-nodes.yaml"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	if nodes := files[1]; len(nodes.Docs) != 2 || !strings.Contains(nodes.Docs[0], "name: a") {
+		t.Errorf("nodes file = %v", nodes.Docs)
+	}
+}
+
+func TestUnmappedHosts(t *testing.T) {
+	hosts := NewHostMap(map[string]string{
+		"corp.example.net": "test.example.com",
+		"worker-0":         "test-worker-0",
+	})
+	dns := SanitizeObject(map[string]interface{}{
+		"apiVersion": "config.openshift.io/v1", "kind": "DNS", "metadata": map[string]interface{}{"name": "cluster"},
+		"spec": map[string]interface{}{"baseDomain": "prod.internal"},
+	}, hosts)
+	infra := SanitizeObject(map[string]interface{}{
+		"apiVersion": "config.openshift.io/v1", "kind": "Infrastructure", "metadata": map[string]interface{}{"name": "cluster"},
+		"status": map[string]interface{}{
+			"apiServerURL":         "https://api.east.corp.example.net:6443",
+			"apiServerInternalURI": "https://api-int.east.prod.internal:6443",
+		},
+	}, hosts)
+	node := SanitizeObject(map[string]interface{}{
+		"apiVersion": "v1", "kind": "Node",
+		"metadata": map[string]interface{}{"name": "worker-1", "labels": map[string]interface{}{"kubernetes.io/hostname": "worker-0"}},
+		"status": map[string]interface{}{"addresses": []interface{}{
+			map[string]interface{}{"type": "Hostname", "address": "worker-1.east.prod.internal"},
+			map[string]interface{}{"type": "InternalIP", "address": "10.0.0.10"},
+		}},
+	}, hosts)
+	route := SanitizeObject(map[string]interface{}{
+		"apiVersion": "route.openshift.io/v1", "kind": "Route", "metadata": map[string]interface{}{"name": "console"},
+		"spec": map[string]interface{}{"host": "console.apps.prod.internal"},
+	}, hosts)
+
+	domains := ClusterDomains(dns)
+	for _, c := range []struct {
+		obj  map[string]interface{}
+		want []string
+	}{
+		{dns, []string{"prod.internal"}},
+		{infra, []string{"api-int.east.prod.internal"}},
+		{node, []string{"worker-1", "worker-1.east.prod.internal"}},
+		{route, []string{"console.apps.prod.internal"}},
+	} {
+		if got := UnmappedHosts(c.obj, domains, hosts); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("UnmappedHosts(%s) = %v, want %v", c.obj["kind"], got, c.want)
+		}
+	}
+
+	mapped := NewHostMap(map[string]string{"prod.internal": "test.example.com", "corp.example.net": "test.example.com", "worker-0": "test-worker-0", "worker-1": "test-worker-1"})
+	for _, obj := range []map[string]interface{}{dns, infra, node, route} {
+		obj = SanitizeObject(obj, mapped)
+		if got := UnmappedHosts(obj, ClusterDomains(SanitizeObject(dns, mapped)), mapped); len(got) != 0 {
+			t.Errorf("mapped %s: UnmappedHosts = %v", obj["kind"], got)
+		}
+	}
+}
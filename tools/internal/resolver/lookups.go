@@ -0,0 +1,474 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Lookup is a lookup a template made that came back empty: a get that found
+// nothing, a list that matched nothing, or a lookup of a kind no testdata
+// object registers.
+type Lookup struct {
+	APIVersion string
+	Kind       string
+	Namespace  string   // "" for cluster-scoped objects, all-namespace lists and non-literal arguments
+	Name       string   // "" for lists and non-literal arguments
+	Selector   string   // label selector of a list
+	Fields     []string // field paths the template reads from the result (or from each listed item), see joinFieldPath
+	Policies   []string // policies whose templates made the lookup
+}
+
+func (l Lookup) String() string {
+	s := fmt.Sprintf("lookup %q %q %q %q", l.APIVersion, l.Kind, l.Namespace, l.Name)
+	if l.Selector != "" {
+		s += fmt.Sprintf(" %q", l.Selector)
+	}
+	return s
+}
+
+func (l Lookup) id() string {
+	return strings.Join([]string{l.APIVersion, l.Kind, l.Namespace, l.Name, l.Selector}, "\x00")
+}
+
+// missReactor wraps the fake dynamic client's object reaction and records
+// every get and list it answers with nothing. A lookup only reaches the
+// dynamic client after missing the resolver's local resources, so together
+// with the local-resource match this sees exactly the lookups that returned
+// nothing.
+func (r *Resolver) missReactor(tracker k8stesting.ObjectTracker, listKinds map[schema.GroupVersionResource]string) k8stesting.ReactionFunc {
+	react := k8stesting.ObjectReaction(tracker)
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		handled, obj, err := react(action)
+		gvr := action.GetResource()
+		miss := Lookup{
+			APIVersion: gvr.GroupVersion().String(),
+			Kind:       strings.TrimSuffix(listKinds[gvr], "List"),
+			Namespace:  action.GetNamespace(),
+		}
+		switch a := action.(type) {
+		case k8stesting.GetAction:
+			if !apierrors.IsNotFound(err) {
+				return handled, obj, err
+			}
+			miss.Name = a.GetName()
+		case k8stesting.ListAction:
+			sel := a.GetListRestrictions().Labels
+			if err != nil || listMatches(obj, sel) {
+				return handled, obj, err
+			}
+			if sel != nil && !sel.Empty() {
+				miss.Selector = sel.String()
+			}
+		default:
+			return handled, obj, err
+		}
+		r.recordMiss(miss)
+		return handled, obj, err
+	}
+}
+
+// listMatches reports whether list holds an item sel selects.
+func listMatches(list runtime.Object, sel labels.Selector) bool {
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return false
+	}
+	for _, item := range items {
+		acc, err := meta.Accessor(item)
+		if err != nil {
+			continue
+		}
+		if sel == nil || sel.Matches(labels.Set(acc.GetLabels())) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) recordMiss(l Lookup) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.missed == nil {
+		r.missed = map[string]bool{}
+	}
+	if !r.missed[l.id()] {
+		r.missed[l.id()] = true
+		r.misses = append(r.misses, l)
+	}
+}
+
+// takeMisses returns the lookups that came back empty since the last call.
+func (r *Resolver) takeMisses() []Lookup {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.misses
+	r.misses, r.missed = nil, nil
+	return out
+}
+
+// missingAPIRe matches the lookup a template failed on because no resource of
+// its kind is registered: `at <lookup "v1" "Foo" "ns" $name>: error calling
+// lookup: one or more API resources are not installed`.
+var missingAPIRe = regexp.MustCompile(`at <lookup ([^>]*)>: error calling lookup: one or more API resources are not installed`)
+
+// lookupArgRe splits lookup arguments into quoted literals and expressions.
+var lookupArgRe = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|\S+`)
+
+// missingAPILookups returns the lookups in resolution errors that failed
+// because their kind is not registered. Non-literal arguments are left empty.
+func missingAPILookups(errs []string) []Lookup {
+	var out []Lookup
+	for _, e := range errs {
+		for _, m := range missingAPIRe.FindAllStringSubmatch(e, -1) {
+			args := lookupArgRe.FindAllString(m[1], -1)
+			lit := func(i int) string {
+				if i >= len(args) || !strings.HasPrefix(args[i], `"`) {
+					return ""
+				}
+				return strings.Trim(args[i], `"`)
+			}
+			l := Lookup{APIVersion: lit(0), Kind: lit(1), Namespace: lit(2), Name: lit(3), Selector: lit(4)}
+			if l.APIVersion != "" && l.Kind != "" {
+				out = append(out, l)
+			}
+		}
+	}
+	return out
+}
+
+// chartLookups merges the lookups one chart's resolution came back empty on —
+// rs's recorded misses and the missing-kind lookups in errs — and fills in the
+// fields rawYAML reads from each.
+func chartLookups(policy, rawYAML string, errs []string, rs ...*Resolver) []Lookup {
+	var all []Lookup
+	for _, r := range rs {
+		all = append(all, r.takeMisses()...)
+	}
+	all = append(all, missingAPILookups(errs)...)
+
+	seen := map[string]bool{}
+	var out []Lookup
+	for _, l := range all {
+		if seen[l.id()] {
+			continue
+		}
+		seen[l.id()] = true
+		l.Fields = LookupFields(rawYAML, l.APIVersion, l.Kind)
+		l.Policies = []string{policy}
+		out = append(out, l)
+	}
+	return out
+}
+
+// MergeLookups combines the failed lookups of every chart, one entry per
+// lookup with the policies that made it, sorted by kind and identity.
+func MergeLookups(results []ChartResult) []Lookup {
+	byID := map[string]*Lookup{}
+	var ids []string
+	for _, res := range results {
+		for _, l := range res.FailedLookups {
+			prev, ok := byID[l.id()]
+			if !ok {
+				l := l
+				byID[l.id()] = &l
+				ids = append(ids, l.id())
+				continue
+			}
+			prev.Fields = mergeSorted(prev.Fields, l.Fields)
+			prev.Policies = mergeSorted(prev.Policies, l.Policies)
+		}
+	}
+	out := make([]Lookup, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, *byID[id])
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].APIVersion != out[j].APIVersion {
+			return out[i].APIVersion < out[j].APIVersion
+		}
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].id() < out[j].id()
+	})
+	return out
+}
+
+func mergeSorted(a, b []string) []string {
+	set := map[string]bool{}
+	for _, s := range append(append([]string{}, a...), b...) {
+		set[s] = true
+	}
+	return sortedSet(set)
+}
+
+func sortedSet(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// LookupFields returns the field paths (see joinFieldPath) templates in tmpl read from the result
+// of `lookup "<apiVersion>" "<kind>" ...`, best effort:
+//
+//	(lookup ...).spec.domain                    spec.domain
+//	index (lookup ...) "status" "desired"       status.desired
+//	$v := (lookup ...)  then  $v.spec.x,
+//	  index $v "spec" "x", dig "spec" "x" "" $v  spec.x
+//	range $i := (lookup ...).items / $v.items   the item's fields, as above
+//
+// A variable assigned from an index of the lookup carries the index path as a
+// prefix. Fields reached through any further variable are not followed.
+func LookupFields(tmpl, apiVersion, kind string) []string {
+	fields := map[string]bool{}
+	call := regexp.MustCompile(`lookup\s+"` + regexp.QuoteMeta(apiVersion) + `"\s+"` + regexp.QuoteMeta(kind) + `"`)
+	for _, loc := range call.FindAllStringIndex(tmpl, -1) {
+		before := tmpl[:loc[0]]
+		end, chain := lookupExprEnd(tmpl, loc[0])
+		after := tmpl[end:]
+
+		prefix := ""
+		if indexPrefixRe.MatchString(before) {
+			prefix = quotedKeys(after)
+		}
+		if chain != "" && chain != "items" && !strings.HasPrefix(chain, "items.") {
+			fields[chain] = true
+		} else if prefix != "" {
+			fields[prefix] = true
+		}
+
+		// range (lookup ...).items: . is each listed object.
+		if rangeOpenRe.MatchString(before) && chain == "items" {
+			collectDotFields(rangeBody(after), fields)
+			continue
+		}
+
+		// Follow the variable the result lands in.
+		m := assignRe.FindStringSubmatch(before)
+		if m == nil {
+			continue
+		}
+		v, scope := m[2], documentScope(after)
+		switch {
+		case m[1] != "":
+			// range $x := (lookup ...).items: $x, and ., is each listed object.
+			collectVarFields(scope, v, "", fields)
+			collectDotFields(rangeBody(after), fields)
+		case chain == "items":
+			// $xs := (lookup ...).items, then range [$x :=] $xs.
+			collectRangeFields(scope, `\$`+v+`\b[^.]`, fields)
+		default:
+			p := prefix
+			if chain != "" {
+				p = chain
+			}
+			collectVarFields(scope, v, p, fields)
+			// $v := (lookup ...), then range [$x :=] $v.items.
+			collectRangeFields(scope, `\$`+v+`\.items\b`, fields)
+		}
+	}
+	delete(fields, "items")
+	return sortedSet(fields)
+}
+
+var (
+	// assignRe matches `$v := (` (or `range $i, $v := (`) right before a lookup,
+	// optionally through `index (`.
+	assignRe = regexp.MustCompile(`(range\s+(?:\$\w+\s*,\s*)?)?\$(\w+)\s*:?=\s*\(?\s*(?:index\s+\(\s*)?$`)
+	// rangeOpenRe matches `range (` right before a lookup.
+	rangeOpenRe = regexp.MustCompile(`range\s+\(\s*$`)
+	// indexPrefixRe matches `index (` right before a lookup.
+	indexPrefixRe = regexp.MustCompile(`index\s+\(\s*$`)
+	fieldChainRe  = regexp.MustCompile(`^\.([A-Za-z_][\w]*(?:\.[A-Za-z_][\w]*)*)`)
+)
+
+// lookupExprEnd returns the offset just past the lookup call starting at
+// start — past its closing parenthesis when it is parenthesised — and the
+// field chain that follows it.
+func lookupExprEnd(tmpl string, start int) (int, string) {
+	open := strings.LastIndex(strings.TrimRight(tmpl[:start], " \t"), "(")
+	if open < 0 || strings.TrimSpace(tmpl[open+1:start]) != "" {
+		// Unparenthesised: the call runs to the end of the action.
+		if i := strings.Index(tmpl[start:], "}}"); i >= 0 {
+			return start + i, ""
+		}
+		return len(tmpl), ""
+	}
+	depth := 0
+	for i := open; i < len(tmpl); i++ {
+		switch tmpl[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				if m := fieldChainRe.FindStringSubmatch(tmpl[i+1:]); m != nil {
+					return i + 1 + len(m[0]), m[1]
+				}
+				return i + 1, ""
+			}
+		}
+	}
+	return len(tmpl), ""
+}
+
+// quotedKeys returns the leading quoted arguments of s, up to the first
+// non-literal, joined as a field path.
+func quotedKeys(s string) string {
+	var keys []string
+	for _, tok := range lookupArgRe.FindAllString(s, -1) {
+		if !strings.HasPrefix(tok, `"`) || !strings.HasSuffix(tok, `"`) || len(tok) < 2 {
+			break
+		}
+		keys = append(keys, strings.Trim(tok, `"`))
+	}
+	return joinFieldPath(keys)
+}
+
+// joinFieldPath joins keys with dots, bracketing a key that contains one:
+// metadata.labels["autoshift.io/odf"].
+func joinFieldPath(keys []string) string {
+	var b strings.Builder
+	for i, k := range keys {
+		switch {
+		case strings.Contains(k, "."):
+			fmt.Fprintf(&b, "[%q]", k)
+		case i > 0:
+			b.WriteString("." + k)
+		default:
+			b.WriteString(k)
+		}
+	}
+	return b.String()
+}
+
+// splitFieldPath is the inverse of joinFieldPath.
+func splitFieldPath(path string) []string {
+	var keys []string
+	for path != "" {
+		switch {
+		case strings.HasPrefix(path, `["`):
+			end := strings.Index(path, `"]`)
+			if end < 0 {
+				return append(keys, path)
+			}
+			keys = append(keys, path[2:end])
+			path = path[end+2:]
+		case strings.HasPrefix(path, "."):
+			path = path[1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			keys = append(keys, path[:end])
+			path = path[end:]
+		}
+	}
+	return keys
+}
+
+// documentScope bounds how far a variable is followed: to the end of the YAML
+// document the lookup is in.
+func documentScope(after string) string {
+	if i := strings.Index(after, "\n---"); i >= 0 {
+		return after[:i]
+	}
+	return after
+}
+
+// collectRangeFields adds the fields read from each element of every range in
+// scope over list (a regexp): through the range variable when there is one,
+// and through . in the range body.
+func collectRangeFields(scope, list string, fields map[string]bool) {
+	re := regexp.MustCompile(`range\s+(?:(?:\$\w+\s*,\s*)?\$(\w+)\s*:=\s*)?` + list)
+	for _, loc := range re.FindAllStringSubmatchIndex(scope, -1) {
+		if loc[2] >= 0 {
+			collectVarFields(scope, scope[loc[2]:loc[3]], "", fields)
+		}
+		collectDotFields(rangeBody(scope[loc[1]:]), fields)
+	}
+}
+
+// rangeBody returns the body of the range whose action after continues: from
+// the end of that action to its matching end.
+func rangeBody(after string) string {
+	open := strings.Index(after, "}}")
+	if open < 0 {
+		return ""
+	}
+	body := after[open+2:]
+	depth := 1
+	for _, loc := range blockActionRe.FindAllStringSubmatchIndex(body, -1) {
+		if body[loc[2]:loc[3]] == "end" {
+			depth--
+			if depth == 0 {
+				return body[:loc[0]]
+			}
+			continue
+		}
+		depth++
+	}
+	return body
+}
+
+var (
+	// blockActionRe matches the actions that open and close a block.
+	blockActionRe = regexp.MustCompile(`\{\{-?\s*(if|range|with|define|block|end)\b`)
+	// dotFieldRe matches a field chain on . — lowercase, so the template's own
+	// context (.Values, .ManagedClusterName) is not mistaken for one.
+	dotFieldRe = regexp.MustCompile(`(?:^|[\s(])\.([a-z]\w*(?:\.[A-Za-z_]\w*)*)`)
+)
+
+// collectDotFields adds the field chains body reads from ., plus the keys it
+// tests with hasKey on them.
+func collectDotFields(body string, fields map[string]bool) {
+	for _, m := range dotFieldRe.FindAllStringSubmatch(body, -1) {
+		fields[m[1]] = true
+	}
+	for _, m := range regexp.MustCompile(`hasKey\s+\.([a-z]\w*(?:\.[A-Za-z_]\w*)*)\s+"([^"]+)"`).FindAllStringSubmatch(body, -1) {
+		fields[joinFieldPath(append(splitFieldPath(m[1]), m[2]))] = true
+	}
+}
+
+// collectVarFields adds the paths scope reads from $v, under prefix.
+func collectVarFields(scope, v, prefix string, fields map[string]bool) {
+	add := func(path string) {
+		if path == "" {
+			return
+		}
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		fields[path] = true
+	}
+	for _, m := range regexp.MustCompile(`\$`+v+`\.([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*)`).FindAllStringSubmatch(scope, -1) {
+		add(m[1])
+	}
+	for _, m := range regexp.MustCompile(`index\s+\(?\$`+v+`\)?\s+((?:"[^"]*"\s*)+)`).FindAllStringSubmatch(scope, -1) {
+		add(quotedKeys(m[1]))
+	}
+	// dig "k1" "k2" <default> $v: every argument but the last before $v is a key.
+	for _, m := range regexp.MustCompile(`dig\s+((?:(?:"[^"]*"|\w+)\s+)+)\$`+v+`\b`).FindAllStringSubmatch(scope, -1) {
+		args := lookupArgRe.FindAllString(m[1], -1)
+		if len(args) < 2 {
+			continue
+		}
+		add(quotedKeys(strings.Join(args[:len(args)-1], " ")))
+	}
+}
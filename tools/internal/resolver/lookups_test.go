@@ -0,0 +1,199 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+func TestLookupFields(t *testing.T) {
+	cases := []struct {
+		name, tmpl, apiVersion, kind string
+		want                         []string
+	}{
+		{"chain", `{{ (lookup "config.openshift.io/v1" "DNS" "" "cluster").spec.baseDomain }}`,
+			"config.openshift.io/v1", "DNS", []string{"spec.baseDomain"}},
+		{"variable", `{{- $mc := (lookup "cluster.open-cluster-management.io/v1" "ManagedCluster" "" $name) }}
+{{ $mc.metadata.labels }} {{ dig "metadata" "labels" "autoshift.io/owning-namespace" "" $mc }} {{ index $mc "spec" "hubAcceptsClient" }}`,
+			"cluster.open-cluster-management.io/v1", "ManagedCluster",
+			[]string{"metadata.labels", `metadata.labels["autoshift.io/owning-namespace"]`, "spec.hubAcceptsClient"}},
+		{"index prefix", `{{- $status := (index (lookup "config.openshift.io/v1" "ClusterVersion" "" "version" | default dict) "status" | default dict) }}
+{{- range $h := (index $status "history" | default list) }}{{ end }}`,
+			"config.openshift.io/v1", "ClusterVersion", []string{"status", "status.history"}},
+		{"range over items", `{{- range $n := (lookup "v1" "Node" "" "").items }}{{ $n.metadata.name }} {{ $n.status.capacity.cpu }}{{ end }}`,
+			"v1", "Node", []string{"metadata.name", "status.capacity.cpu"}},
+		{"list variable", `{{- $cms := lookup "v1" "ConfigMap" "" "" "autoshift.io/cluster-labels" }}
+{{- range $cm := $cms.items }}{{ $cm.data.labels }}{{ end }}`,
+			"v1", "ConfigMap", []string{"data.labels"}},
+		{"items variable", `{{- $nodes := (lookup "v1" "Node" "" "" "cluster.ocs.openshift.io/openshift-storage").items }}
+{{- range $i, $n := $nodes }}{{ $n.metadata.labels }}{{ end }}`,
+			"v1", "Node", []string{"metadata.labels"}},
+		{"range dot", `{{- range (lookup "v1" "Node" "" "").items }}
+  {{- if hasKey .metadata.labels "node-role.kubernetes.io/worker" }}{{ $cores = (.status.capacity.cpu | toInt) }}{{ end }}
+{{- end }}
+{{ .Values.outside }} {{ .metadata.outside }}`,
+			"v1", "Node", []string{"metadata.labels", `metadata.labels["node-role.kubernetes.io/worker"]`, "status.capacity.cpu"}},
+		{"range items variable", `{{- $nodes := (lookup "v1" "Node" "" "" "x").items }}
+{{- range $nodes }}{{ .status.conditions }}{{ end }}`,
+			"v1", "Node", []string{"status.conditions"}},
+		{"other document", `{{ $s := (lookup "v1" "Secret" "ns" "a") }}
+---
+{{ $s.data.password }}`,
+			"v1", "Secret", nil},
+	}
+	for _, tc := range cases {
+		got := LookupFields(tc.tmpl, tc.apiVersion, tc.kind)
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("%s: fields = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFieldPath(t *testing.T) {
+	keys := []string{"metadata", "labels", "autoshift.io/odf", "x"}
+	path := joinFieldPath(keys)
+	if path != `metadata.labels["autoshift.io/odf"].x` {
+		t.Errorf("joinFieldPath = %s", path)
+	}
+	if got := splitFieldPath(path); !reflect.DeepEqual(got, keys) {
+		t.Errorf("splitFieldPath(%s) = %q", path, got)
+	}
+}
+
+func TestMissingAPILookups(t *testing.T) {
+	got := missingAPILookups([]string{
+		`ConfigMap x: spoke resolve: failed to resolve the template {"a":"{{ (lookup \"foo.io/v1\" \"Bar\" \"ns\" $n).spec.a }}"}: template: tmpl:3:25: executing "tmpl" at <lookup "foo.io/v1" "Bar" "ns" $n>: error calling lookup: one or more API resources are not installed on the API server`,
+		`policy-x: template: tmpl:1:2: executing "tmpl" at <fromSecret "ns" "s" "k">: error calling fromSecret: not found`,
+	})
+	want := []Lookup{{APIVersion: "foo.io/v1", Kind: "Bar", Namespace: "ns"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("missingAPILookups = %+v, want %+v", got, want)
+	}
+}
+
+// TestResolver_RecordsMisses checks that a lookup of a registered kind that
+// finds nothing is recorded, and one that finds its object is not.
+func TestResolver_RecordsMisses(t *testing.T) {
+	dns := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "DNS",
+		"metadata":   map[string]interface{}{"name": "cluster"},
+		"spec":       map[string]interface{}{"baseDomain": "example.com"},
+	}}
+	r, err := NewSpokeResolver([]unstructured.Unstructured{dns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := r.ResolveSpokeTemplates(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: x
+data:
+  found: '{{ (lookup "config.openshift.io/v1" "DNS" "" "cluster").spec.baseDomain }}'
+  missing: '{{ (lookup "config.openshift.io/v1" "DNS" "" "other").spec.baseDomain | default "" }}'
+  list: '{{ len (lookup "config.openshift.io/v1" "DNS" "" "" "team=a").items }}'
+`)
+	if len(res.Errors) > 0 {
+		t.Fatalf("errors: %v", res.Errors)
+	}
+	got := r.takeMisses()
+	want := []Lookup{
+		{APIVersion: "config.openshift.io/v1", Kind: "DNS", Selector: "team=a"},
+		{APIVersion: "config.openshift.io/v1", Kind: "DNS", Name: "other"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("misses = %+v, want %+v", got, want)
+	}
+	if again := r.takeMisses(); len(again) != 0 {
+		t.Errorf("takeMisses did not reset: %+v", again)
+	}
+}
+
+func TestStubs(t *testing.T) {
+	files := Stubs([]Lookup{
+		{APIVersion: "v1", Kind: "Secret", Namespace: "gitlab-system", Name: "gitlab-redis-password",
+			Fields: []string{"data", "data.password"}, Policies: []string{"stable/gitlab"}},
+		{APIVersion: "config.openshift.io/v1", Kind: "ClusterVersion", Name: "version",
+			Fields: []string{"status", "status.history"}, Policies: []string{"stable/openshift-upgrade"}},
+		{APIVersion: "v1", Kind: "Node", Selector: "cluster.ocs.openshift.io/openshift-storage",
+			Fields: []string{"metadata.labels", "metadata.name", `metadata.labels["topology.kubernetes.io/zone"]`}, Policies: []string{"stable/odf"}},
+	})
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if want := "stub-clusterversions.config.openshift.io.yaml stub-nodes.yaml stub-secrets.yaml"; strings.Join(names, " ") != want {
+		t.Fatalf("files = %v, want %s", names, want)
+	}
+
+	objs := map[string]map[string]interface{}{}
+	for _, f := range files {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(f.Docs[0]), &obj); err != nil {
+			t.Fatalf("%s: %v\n%s", f.Name, err, f.Docs[0])
+		}
+		objs[f.Name] = obj
+	}
+	if got, _ := nestedString(objs["stub-secrets.yaml"], "data", "password"); got != "Q0hBTkdFTUU=" {
+		t.Errorf("secret data.password = %q, want base64 %s", got, StubPlaceholder)
+	}
+	if got, _ := nestedString(objs["stub-clusterversions.config.openshift.io.yaml"], "status", "history"); got != StubPlaceholder {
+		t.Errorf("clusterversion status.history = %q", got)
+	}
+	node := objs["stub-nodes.yaml"]
+	if name, _ := nestedString(node, "metadata", "name"); name != "stub-node" {
+		t.Errorf("node name = %q, want stub-node", name)
+	}
+	if v, ok := nestedString(node, "metadata", "labels", "cluster.ocs.openshift.io/openshift-storage"); !ok || v != "" {
+		t.Errorf("node labels = %v, want the selector's label", node["metadata"])
+	}
+	if v, _ := nestedString(node, "metadata", "labels", "topology.kubernetes.io/zone"); v != StubPlaceholder {
+		t.Errorf("node labels = %v, want the label the template reads", node["metadata"])
+	}
+	if !strings.Contains(files[1].Docs[0], "# Set the name (and namespace) the template expects.") {
+		t.Errorf("list stub without a hint to name it:\n%s", files[1].Docs[0])
+	}
+}
+
+func TestWriteStubs_KeepsEdits(t *testing.T) {
+	dir := t.TempDir()
+	lookups := []Lookup{{APIVersion: "config.openshift.io/v1", Kind: "DNS", Name: "cluster", Fields: []string{"spec.baseDomain"}}}
+	if _, err := WriteStubs(dir, Stubs(lookups)); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "stub-dnses.config.openshift.io.yaml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(data), StubPlaceholder, "example.com", 1)
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lookups = append(lookups, Lookup{APIVersion: "config.openshift.io/v1", Kind: "DNS", Name: "other"})
+	written, err := WriteStubs(dir, Stubs(lookups))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 1 {
+		t.Fatalf("written = %v", written)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.HasPrefix(string(data), edited) || strings.Count(string(data), "kind: DNS") != 2 {
+		t.Errorf("edit lost or stub not appended:\n%s", data)
+	}
+	res, err := LoadTestResources(dir)
+	if err != nil || len(res) != 2 {
+		t.Errorf("LoadTestResources = %d objects, %v", len(res), err)
+	}
+
+	if written, _ := WriteStubs(dir, Stubs(lookups)); len(written) != 0 {
+		t.Errorf("re-run rewrote %v", written)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/auto-shift/autoshiftv2/tools/internal/configkeys"
 	"github.com/auto-shift/autoshiftv2/tools/internal/labels"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	sigsyaml "sigs.k8s.io/yaml"
@@ -20,28 +21,54 @@ type NamedContext struct {
 	Ctx  HubContext
 }
 
-// ContextResult holds the resolution outcome for one chart against one extra
-// cluster profile.
+// ContextResult holds the resolution outcome for one chart against one
+// cluster profile: the primary context, embedded in ChartResult, or an extra
+// one, in ChartResult.ExtraResults.
 type ContextResult struct {
-	ResolveOK    bool
-	ResolveWarns []string
-	SpokeWarns   []string
+	ResolveOK    bool     // all Policy documents resolved without error
+	ResolveWarns []string // per-document resolution warnings (e.g. lookup failures)
+	SpokeWarns   []string // warnings from the spoke-side second pass
 	YAMLErrors   []string // malformed YAML / <no value> in the fully-resolved output
-	ResolvedYAML string
+	SecretLeaks  []string // testdata Secret values in clear text in the hub output, see SecretLeaks
+	Remediation  []string // Policies that enforce in a dryRun deployment, see CheckRemediation
+	ResolvedYAML string   // final multi-doc YAML after hub+spoke resolution (for output assertions)
+
+	// SecretFlows are the Secret values the chart's templates read and where
+	// they land (see TraceSecretFlows), when the deployment traces.
+	SecretFlows []SecretFlow
+
+	// HubRequirements are the objects the chart's templates read on the hub
+	// that AutoShift does not create itself (see TraceHubReads), when the
+	// deployment traces.
+	HubRequirements []HubRequirement
+
+	// RemovedAPIs and DeprecatedAPIs are the objects the Policies create whose
+	// API the profile's OpenShift release no longer serves or has deprecated,
+	// see CheckAPIVersions.
+	RemovedAPIs    []string
+	DeprecatedAPIs []string
+
+	Identifiers []string // names, namespaces, labels and annotation keys the API server rejects, see CheckIdentifiers
 }
 
-// ChartResult holds the outcome for one policy chart.
+// ChartResult holds the outcome for one policy chart. The embedded
+// ContextResult is the primary (hub, self-managed) context's.
 type ChartResult struct {
+	ContextResult
+
 	Policy       string   // "stable/cert-manager"
 	ChartDir     string   // path to chart directory
 	HelmOK       bool     // helm template succeeded
-	ResolveOK    bool     // all Policy documents resolved without error
-	ResolveWarns []string // per-document resolution warnings (e.g. lookup failures)
-	SpokeWarns   []string // warnings from the spoke-side second pass
-	YAMLErrors   []string // malformed YAML / <no value> in the fully-resolved primary output
+	Placeholders []string // unknown or unsubstituted ${...} placeholders of a PolicyGenerator policy
+	HelmEscapes  []string // ACM templates Helm evaluated or mangled, see CheckHelmEscapes
+	ACMCompat    []string // what the hub profiles' ACM releases lack, see CheckACMCompatibility
 	EmptyLabels  []string // label keys that resolved to empty string
+	ConfigKeys   []string // top-level rendered-config keys the templates read
 	Err          error    // fatal error (helm template failed or zero docs rendered)
-	ResolvedYAML string   // final multi-doc YAML after hub+spoke resolution (for output assertions)
+
+	// FailedLookups are the lookups this chart's templates made that came back
+	// empty, in any cluster profile — what a tools/testdata stub would answer.
+	FailedLookups []Lookup
 
 	// ExtraResults holds resolution outcomes for each additional cluster profile
 	// passed to RunPipeline (e.g. managed-baremetal, managed-aws, managed-vmware),
@@ -51,12 +78,110 @@ type ChartResult struct {
 	ExtraResults map[string]ContextResult
 }
 
+// Deployment is what a policy render depends on besides the policy itself:
+// the ${...} placeholders the repo-server CMP substitutes into PolicyGenerator
+// policies, and the valuesObject the autoshift chart passes Helm policies.
+type Deployment struct {
+	// Placeholders maps a CMP variable (POLICY_NAMESPACE, REMEDIATION, ...) to
+	// its value, as the ApplicationSet's plugin env sets it.
+	Placeholders map[string]string
+	// ValuesFile, when set, replaces the WriteTestValues output for every Helm
+	// policy. ChartValuesFiles overrides it per chart directory name, for the
+	// charts the autoshift chart deploys through a dedicated Application
+	// (cluster-labels, cluster-config-maps) rather than the ApplicationSet.
+	ValuesFile       string
+	ChartValuesFiles map[string]string
+	// GitopsNamespace is the namespace of the Argo CD instance the autoshift
+	// chart deploys into, as the test values pass it ("" is openshift-gitops).
+	GitopsNamespace string
+	// Trace fills ChartResult.SecretFlows and HubRequirements (TraceSecretFlows,
+	// TraceHubReads). Each trace resolves a chart again per profile, so only
+	// the commands that report them set it.
+	Trace bool
+}
+
+// DeploymentValues are the autoshift chart values a deployment's policies
+// depend on.
+type DeploymentValues struct {
+	Release              string // release name; the policy namespace is policies-<Release>
+	DryRun               bool   // autoshift.dryRun: every policy informs
+	CompliantInterval    string // autoshift.evaluationInterval.compliant ("" is watch)
+	NoncompliantInterval string // autoshift.evaluationInterval.noncompliant ("" is watch)
+	ClusterSetSuffix     string // the versioned clusterset suffix, e.g. "-v2"
+	GitopsNamespace      string // "" is openshift-gitops
+}
+
+// Deployment is the deployment v describes, with the placeholders the
+// ApplicationSet sets for it.
+func (v DeploymentValues) Deployment() Deployment {
+	orWatch := func(s string) string {
+		if s == "" {
+			return "watch"
+		}
+		return s
+	}
+	remediation := "enforce"
+	if v.DryRun {
+		remediation = "inform"
+	}
+	return Deployment{
+		Placeholders: map[string]string{
+			"POLICY_NAMESPACE":   "policies-" + v.Release,
+			"REMEDIATION":        remediation,
+			"EVAL_COMPLIANT":     orWatch(v.CompliantInterval),
+			"EVAL_NONCOMPLIANT":  orWatch(v.NoncompliantInterval),
+			"CLUSTER_SET_SUFFIX": v.ClusterSetSuffix,
+		},
+		GitopsNamespace: v.GitopsNamespace,
+	}
+}
+
+// DefaultDeployment mirrors a non-dryRun "autoshift" release without
+// versionedClusterSets, evaluating compliant policies every 10m and
+// noncompliant ones every 30s: the deployment TestPipeline_EndToEnd renders
+// for.
+func DefaultDeployment() Deployment {
+	return DeploymentValues{Release: "autoshift", CompliantInterval: "10m", NoncompliantInterval: "30s"}.Deployment()
+}
+
+// Namespace is the deployment's policy namespace.
+func (d Deployment) Namespace() string { return d.Placeholders["POLICY_NAMESPACE"] }
+
+// DryRun reports whether the deployment only informs.
+func (d Deployment) DryRun() bool { return d.Placeholders["REMEDIATION"] == "inform" }
+
+// valuesFor returns the values file a Helm chart renders with, or fallback
+// when the deployment supplies none.
+func (d Deployment) valuesFor(chartDir, fallback string) string {
+	if f, ok := d.ChartValuesFiles[filepath.Base(chartDir)]; ok {
+		return f
+	}
+	if d.ValuesFile != "" {
+		return d.ValuesFile
+	}
+	return fallback
+}
+
+// replacer substitutes every placeholder, in a fixed order.
+func (d Deployment) replacer() *strings.Replacer {
+	var pairs []string
+	for _, k := range d.names() {
+		pairs = append(pairs, "${"+k+"}", d.Placeholders[k])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
 // HelmTemplate runs `helm template <name> <chartDir>` and returns the raw
 // multi-document YAML output. If extraValuesFiles are provided, they are
 // passed as `-f` flags (used to inject the ApplicationSet-level values that
 // policy charts need to render conditional templates).
 func HelmTemplate(chartDir string, extraValuesFiles ...string) (string, error) {
-	name := filepath.Base(chartDir)
+	return HelmTemplateRelease(filepath.Base(chartDir), chartDir, extraValuesFiles...)
+}
+
+// HelmTemplateRelease is HelmTemplate with an explicit release name, which the
+// autoshift chart turns into the policy namespace (policies-<release>).
+func HelmTemplateRelease(name, chartDir string, extraValuesFiles ...string) (string, error) {
 	args := []string{"template", name, chartDir}
 	for _, f := range extraValuesFiles {
 		args = append(args, "-f", f)
@@ -71,30 +196,29 @@ func HelmTemplate(chartDir string, extraValuesFiles ...string) (string, error) {
 
 // KustomizeBuild renders a PolicyGenerator policy directory the same way the
 // repo-server CMP does: substitute the per-deployment ${...} placeholders, then
-// run `kustomize build` with the same flags as the CMP. Test values mirror a
-// non-dryRun deployment (REMEDIATION=enforce) so object-templates render in
-// enforce mode.
+// run `kustomize build` with the same flags as the CMP. It renders for
+// DefaultDeployment, a non-dryRun deployment (REMEDIATION=enforce), so
+// object-templates render in enforce mode; KustomizeBuildFor takes another.
 //
 // The kustomize binary and PolicyGenerator plugin come from (in order):
 // $KUSTOMIZE_BIN / $KUSTOMIZE_PLUGIN_HOME, then the repo-local .tools/ that
 // `make install-policy-generator` stages, then `kustomize` on PATH.
 func KustomizeBuild(policyDir string) (string, error) {
-	repl := strings.NewReplacer(
-		"${POLICY_NAMESPACE}", "policies-autoshift",
-		"${REMEDIATION}", "enforce",
-		"${EVAL_COMPLIANT}", "watch",
-		"${EVAL_NONCOMPLIANT}", "watch",
-		"${CLUSTER_SET_SUFFIX}", "",
-	)
+	return KustomizeBuildFor(policyDir, DefaultDeployment())
+}
+
+// KustomizeBuildFor is KustomizeBuild with dep's placeholder values.
+func KustomizeBuildFor(policyDir string, dep Deployment) (string, error) {
+	repl := dep.replacer()
 	work, err := os.MkdirTemp("", "autoshift-kustomize-*")
 	if err != nil {
 		return "", err
 	}
 	defer os.RemoveAll(work)
 
-	// Stage the policy dir, substituting placeholders in every file. The exact
-	// ${...} tokens never appear in manifests (whose hub templates use $var), so
-	// substituting broadly is safe — mirrors an envsubst restricted to these vars.
+	// Stage the policy dir, substituting placeholders in every .yaml file — the
+	// CMP's sed restricted to these exact tokens. CheckPlaceholders and
+	// LeftoverPlaceholders catch a ${...} outside the set.
 	//
 	// A policy may render a shared Helm chart via a nested kustomization whose
 	// helmGlobals.chartHome reaches up to the repo-level components/ dir. To keep
@@ -185,7 +309,6 @@ func isDir(p string) bool {
 	return err == nil && fi.IsDir()
 }
 
-// copyDirSubst copies src to dst, applying repl to the contents of every file.
 // findComponentsRoot walks up from dir to the nearest ancestor containing a
 // components/ directory (the repo-level home for shared Helm charts). Returns ""
 // if none is found before the filesystem root.
@@ -202,6 +325,8 @@ func findComponentsRoot(dir string) string {
 	}
 }
 
+// copyDirSubst copies src to dst, applying repl to the contents of every
+// .yaml file — the files the CMP's sed rewrites.
 func copyDirSubst(src, dst string, repl *strings.Replacer) error {
 	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -216,14 +341,19 @@ func copyDirSubst(src, dst string, repl *strings.Replacer) error {
 		if err != nil {
 			return err
 		}
-		return os.WriteFile(target, []byte(repl.Replace(string(data))), 0o644)
+		if filepath.Ext(path) == ".yaml" {
+			data = []byte(repl.Replace(string(data)))
+		}
+		return os.WriteFile(target, data, 0o644)
 	})
 }
 
 // RunPipeline processes all policy charts under policiesDir:
 //
-//  1. Generates synthetic ConfigMaps from example file configs and pre-seeds
-//     the hub resolver so all downstream lookup calls get realistic data.
+//  1. Generates synthetic ConfigMaps from example file configs and a cluster
+//     identity per profile (SyntheticClusters), and pre-seeds the hub
+//     resolver so all downstream lookup calls get realistic data. Each
+//     profile's spoke pass also sees that profile's ClusterClaims.
 //  2. Discovers charts at <category>/<chart>/Chart.yaml
 //  3. Runs `helm template` on each with fully-populated test values
 //  4. Verifies each chart renders at least one non-empty document
@@ -240,6 +370,23 @@ func RunPipeline(
 	declared map[string]*labels.Declared,
 	configs *ExampleConfigs,
 	testdataDir string,
+) (map[string]*labels.Consumed, []ChartResult, error) {
+	return RunDeployment(DefaultDeployment(), policiesDir, ctx, extraCtxs, r, spokeR, declared, configs, testdataDir)
+}
+
+// RunDeployment is RunPipeline for a given deployment: PolicyGenerator
+// policies get dep's placeholders, Helm policies dep's values files, and the
+// synthetic ConfigMaps live in dep's policy namespace.
+func RunDeployment(
+	dep Deployment,
+	policiesDir string,
+	ctx HubContext,
+	extraCtxs []NamedContext,
+	r *Resolver,
+	spokeR *Resolver,
+	declared map[string]*labels.Declared,
+	configs *ExampleConfigs,
+	testdataDir string,
 ) (map[string]*labels.Consumed, []ChartResult, error) {
 	charts, err := discoverCharts(policiesDir)
 	if err != nil {
@@ -252,7 +399,13 @@ func RunPipeline(
 	}
 	defer os.RemoveAll(tmpDir)
 
-	testValuesPath, err := WriteTestValues(tmpDir, ctx.ManagedClusterName, configs)
+	allCtxs := []HubContext{ctx}
+	for _, ec := range extraCtxs {
+		allCtxs = append(allCtxs, ec.Ctx)
+	}
+	acmTargets := ACMTargets(allCtxs...)
+
+	testValuesPath, err := WriteTestValuesFor(tmpDir, ctx.ManagedClusterName, configs, dep)
 	if err != nil {
 		return nil, nil, fmt.Errorf("write test values: %w", err)
 	}
@@ -277,15 +430,30 @@ func RunPipeline(
 		return nil, nil, fmt.Errorf("load test resources: %w", err)
 	}
 
-	syntheticCMs, err := GenerateSyntheticConfigMaps(configs, ctx.ManagedClusterName, "policies-autoshift")
+	syntheticCMs, err := GenerateSyntheticConfigMaps(configs, ctx.ManagedClusterName, dep.Namespace())
 	if err != nil {
 		return nil, nil, fmt.Errorf("generate synthetic configmaps: %w", err)
 	}
 
-	seedResources := append(syntheticCMs, testResources...)
+	// Every profile's cluster is registered on the hub (ManagedCluster,
+	// ManagedClusterInfo); its ClusterClaims and encryption key only exist on
+	// the cluster itself, so the spoke pass swaps them in per profile
+	// (spokeResources).
+	clusters := SyntheticClusters(ctx, extraCtxs, configs, dep.Namespace(), testResources)
+	spokeObjects := SpokeObjects(clusters)
+	hubObjects := HubObjects(clusters)
+
+	seedResources := append(append(append([]unstructured.Unstructured{}, syntheticCMs...), hubObjects...), testResources...)
 	r.SetLocalResources(seedResources)
-	if spokeR != nil {
-		spokeR.SetLocalResources(seedResources)
+	spokeBase := seedResources
+	spokeResources := func(c HubContext) []unstructured.Unstructured {
+		return append(append([]unstructured.Unstructured{}, spokeBase...), spokeObjects[c.ManagedClusterName]...)
+	}
+	// The OpenShift release of each profile's cluster, as its ManagedCluster
+	// reports it.
+	ocpVersions, err := clusterOCPVersions(clusters)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Build the set of declared keys for quick lookup.
@@ -297,36 +465,72 @@ func RunPipeline(
 	keysByPolicy := map[string]map[string]bool{}
 	var results []ChartResult
 
+	// Objects the deployment creates itself, which no operator has to: the
+	// cluster-config-maps output and whatever a chart or its policies deploy.
+	created := map[string]bool{}
+	for _, cm := range syntheticCMs {
+		created[fixtureID(cm.Object)] = true
+	}
+
 	// resolvePasses runs the two-stage resolution for one chart's rendered YAML
 	// against a given cluster context: pass 1 resolves hub templates
-	// ({{hub ... hub}}), pass 2 resolves spoke templates ({{ ... }}). Returns
-	// (hubResolveOK, hubErrors, spokeErrors, resolvedYAML). Called once for the
-	// primary context and, when managedCtx is set, once for the managed context.
-	resolvePasses := func(rawYAML string, c HubContext) (bool, []string, []string, string) {
-		var resolveWarns, spokeWarns []string
-		resolveOK := false
+	// ({{hub ... hub}}), pass 2 resolves spoke templates ({{ ... }}). The
+	// returned YAMLErrors are left for the caller. Called once for the primary
+	// context and once per extra context; the error is a failed trace.
+	resolvePasses := func(rawYAML string, c HubContext) (ContextResult, error) {
+		var res ContextResult
 
 		hubResult := r.ResolvePolicy(rawYAML, c)
 		if len(hubResult.Errors) == 0 {
-			resolveOK = true
+			res.ResolveOK = true
 		} else {
-			resolveWarns = hubResult.Errors
+			res.ResolveWarns = hubResult.Errors
 		}
+		res.SecretLeaks = SecretLeaks(hubResult.Resolved, testResources)
 
 		// Strip string defaults first so any config key the template consumes but
 		// the example file doesn't declare produces "<no value>" in the output
 		// rather than silently falling back to a hardcoded string.
 		spokeInput := stripStringDefaults(hubResult.Resolved)
 		if spokeR != nil && strings.Contains(spokeInput, "{{") {
-			spokeResult := spokeR.ResolveSpokeTemplates(spokeInput, c)
+			spokeR.SetLocalResources(spokeResources(c))
+			spokeResult := spokeR.ResolveSpokePolicies(spokeInput)
 			if len(spokeResult.Errors) > 0 {
-				spokeWarns = spokeResult.Errors
+				res.SpokeWarns = spokeResult.Errors
 			}
 			if spokeResult.Resolved != "" {
 				spokeInput = spokeResult.Resolved
 			}
 		}
-		return resolveOK, resolveWarns, spokeWarns, spokeInput
+		res.ResolvedYAML = spokeInput
+		if v, ok := ocpVersions[c.ManagedClusterName]; ok {
+			res.RemovedAPIs, res.DeprecatedAPIs = CheckAPIVersions(spokeInput, v)
+		}
+		res.Identifiers = CheckIdentifiers(spokeInput)
+		if !dep.Trace {
+			return res, nil
+		}
+		if readsSecrets(rawYAML) {
+			flows, err := TraceSecretFlows(rawYAML, c, spokeBase, spokeResources(c))
+			if err != nil {
+				return res, fmt.Errorf("trace secret flows for %s: %w", c.ManagedClusterName, err)
+			}
+			res.SecretFlows = flows
+		}
+		// The primary context is the hub itself: its spoke templates read the
+		// hub too.
+		var hubCluster []unstructured.Unstructured
+		if c.ManagedClusterName == ctx.ManagedClusterName {
+			hubCluster = spokeResources(c)
+		}
+		if strings.Contains(rawYAML, "{{hub") || (hubCluster != nil && readsHubObjects(rawYAML)) {
+			reqs, err := TraceHubReads(rawYAML, c, spokeBase, hubCluster)
+			if err != nil {
+				return res, fmt.Errorf("trace hub reads for %s: %w", c.ManagedClusterName, err)
+			}
+			res.HubRequirements = reqs
+		}
+		return res, nil
 	}
 
 	for _, chart := range charts {
@@ -338,12 +542,19 @@ func RunPipeline(
 		// 1. Render the policy — kustomize+PolicyGenerator or Helm, per marker file.
 		var rawYAML string
 		if chart.kind == "kustomize" {
-			rawYAML, err = KustomizeBuild(chart.dir)
+			rawYAML, err = KustomizeBuildFor(chart.dir, dep)
 			if err != nil {
 				result.Err = err
 				results = append(results, result)
 				continue
 			}
+			result.Placeholders, err = CheckPlaceholders(chart.dir, dep.names())
+			if err != nil {
+				result.Err = fmt.Errorf("check placeholders: %w", err)
+				results = append(results, result)
+				continue
+			}
+			result.Placeholders = append(result.Placeholders, LeftoverPlaceholders(rawYAML, dep.names())...)
 		} else {
 			// Prepare chart for rendering (activate .example files if present).
 			renderDir, cleanup, perr := prepareChartForRender(chart.dir, tmpDir)
@@ -352,13 +563,19 @@ func RunPipeline(
 				results = append(results, result)
 				continue
 			}
-			rawYAML, err = HelmTemplate(renderDir, testValuesPath)
+			rawYAML, err = HelmTemplate(renderDir, dep.valuesFor(chart.dir, testValuesPath))
 			cleanup()
 			if err != nil {
 				result.Err = err
 				results = append(results, result)
 				continue
 			}
+			result.HelmEscapes, err = CheckHelmEscapes(chart.dir, rawYAML)
+			if err != nil {
+				result.Err = fmt.Errorf("check escapes: %w", err)
+				results = append(results, result)
+				continue
+			}
 		}
 		result.HelmOK = true
 
@@ -376,78 +593,40 @@ func RunPipeline(
 			continue
 		}
 
-		// 3. Determine consumed labels from the rendered output (two passes).
-		consumed := make(map[string]bool)
-
-		// Pass a: declared keys → check if consumed.
-		for key := range declaredKeys {
-			if strings.Contains(rawYAML, "autoshift.io/"+key) {
-				consumed[key] = true
-				continue
-			}
-			prefix := stripNumberedSuffix(key)
-			if prefix != key && strings.Contains(rawYAML, "autoshift.io/"+prefix) {
-				consumed[key] = true
+		// 2b. Check the policy APIs and template functions against the ACM
+		// release of every hub profile.
+		for _, v := range acmTargets {
+			for _, e := range CheckACMCompatibility(rawYAML, v) {
+				result.ACMCompat = append(result.ACMCompat, fmt.Sprintf("ACM %s: %s", v, e))
 			}
 		}
 
-		// Pass b: scan rendered output for any autoshift.io/* keys not yet in
-		// the declared map (these will surface as "missing" in the contract).
-		for _, pattern := range []string{
-			`.ManagedClusterLabels "autoshift.io/`,
-			`hasPrefix "autoshift.io/`,
-			`key: 'autoshift.io/`,
-			`key: "autoshift.io/`,
-			`key: autoshift.io/`, // unquoted: kustomize/PolicyGenerator placement predicates
-			// Labels read off a ManagedCluster fetched with `lookup`, rather than from the
-			// hub-template .ManagedClusterLabels context. cluster-install does this to read
-			// the runtime-stamped autoshift.io/owning-namespace. Without this pattern such a
-			// label is consumed but reported neither missing nor orphaned.
-			`dig "metadata" "labels" "autoshift.io/`,
-		} {
-			remaining := rawYAML
-			for {
-				idx := strings.Index(remaining, pattern)
-				if idx < 0 {
-					break
-				}
-				after := remaining[idx+len(pattern):]
-				remaining = after
-				end := 0
-				for end < len(after) {
-					c := after[end]
-					if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' {
-						end++
-					} else {
-						break
-					}
-				}
-				if end < 2 {
-					continue
-				}
-				key := after[:end]
-				if strings.HasSuffix(key, "-") {
-					continue
-				}
-				isPrefix := false
-				for dk := range declaredKeys {
-					if strings.HasPrefix(dk, key+"-") {
-						isPrefix = true
-						break
-					}
-				}
-				if isPrefix {
-					continue
-				}
-				consumed[key] = true
-			}
+		// 3. Determine consumed labels from the rendered output.
+		consumed := make(map[string]bool)
+		for _, key := range labels.ScanReads(rawYAML, declaredKeys) {
+			consumed[key] = true
 		}
 		keysByPolicy[chart.policy] = consumed
 
+		// 3b. Record which top-level rendered-config keys the templates read, for
+		// the reverse config-key check (consumed but never declared).
+		result.ConfigKeys = configkeys.ScanReads(rawYAML)
+
 		// 4-5. Resolve hub + spoke templates against the primary (hub,
 		// self-managed) context.
-		var spokeInput string
-		result.ResolveOK, result.ResolveWarns, result.SpokeWarns, spokeInput = resolvePasses(rawYAML, ctx)
+		primary, err := resolvePasses(rawYAML, ctx)
+		if err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		result.ContextResult = primary
+		spokeInput := primary.ResolvedYAML
+		result.SecretFlows = labelFlows(primary.SecretFlows, chart.policy, PrimaryProfile)
+		result.HubRequirements = labelRequirements(primary.HubRequirements, chart.policy, PrimaryProfile)
+		for id := range CreatedObjects(rawYAML, spokeInput) {
+			created[id] = true
+		}
 
 		// 5b. Resolve against each additional cluster profile (managed spokes,
 		// one per install platform). Same rendered YAML and seed resources — only
@@ -456,14 +635,18 @@ func RunPipeline(
 		if len(extraCtxs) > 0 {
 			result.ExtraResults = make(map[string]ContextResult, len(extraCtxs))
 			for _, ec := range extraCtxs {
-				ok, rw, sw, out := resolvePasses(rawYAML, ec.Ctx)
-				result.ExtraResults[ec.Name] = ContextResult{
-					ResolveOK:    ok,
-					ResolveWarns: rw,
-					SpokeWarns:   sw,
-					YAMLErrors:   validateYAML(out),
-					ResolvedYAML: out,
+				cr, err := resolvePasses(rawYAML, ec.Ctx)
+				if err != nil {
+					result.Err = err
+					break
+				}
+				cr.YAMLErrors = validateYAML(cr.ResolvedYAML)
+				if dep.DryRun() {
+					cr.Remediation = CheckRemediation(cr.ResolvedYAML)
 				}
+				cr.SecretFlows = labelFlows(cr.SecretFlows, chart.policy, ec.Name)
+				cr.HubRequirements = labelRequirements(cr.HubRequirements, chart.policy, ec.Name)
+				result.ExtraResults[ec.Name] = cr
 			}
 		}
 
@@ -474,19 +657,18 @@ func RunPipeline(
 			rawCMs, parseErr := ParseConfigMaps(rawYAML)
 			if parseErr == nil && len(rawCMs) > 0 {
 				renderedCM, mergeErr := MergeRenderedConfig(
-					ctx.ManagedClusterName, "policies-autoshift", rawCMs,
+					ctx.ManagedClusterName, dep.Namespace(), rawCMs,
 				)
 				if mergeErr == nil {
 					helmResources := append(testResources, rawCMs...)
 					helmResources = append(helmResources, renderedCM)
+					helmResources = append(helmResources, hubObjects...)
 					// Merge with synthetic CMs: helm output takes precedence.
 					// Deduplicate so helm-rendered CMs replace synthetic ones
 					// with the same identity (e.g. rendered-config).
 					allResources := deduplicateResources(syntheticCMs, helmResources)
 					r.SetLocalResources(allResources)
-					if spokeR != nil {
-						spokeR.SetLocalResources(allResources)
-					}
+					spokeBase = allResources
 				}
 			}
 		}
@@ -496,6 +678,9 @@ func RunPipeline(
 		// own hard failures (result.YAMLErrors), independent of hub ResolveOK, so
 		// malformed YAML / <no value> on an otherwise-clean chart still fails CI.
 		result.YAMLErrors = validateYAML(spokeInput)
+		if dep.DryRun() {
+			result.Remediation = CheckRemediation(spokeInput)
+		}
 
 		// 8. Track empty-string label substitutions for diagnostics.
 		if result.ResolveOK {
@@ -509,12 +694,26 @@ func RunPipeline(
 			sort.Strings(result.EmptyLabels)
 		}
 
-		// 9. Preserve final resolved YAML for output assertions in tests.
-		result.ResolvedYAML = spokeInput
+		// 8b. Record the lookups that came back empty, across every profile, so
+		// cmd/testdata-stubs can scaffold the fixtures that would answer them.
+		lookupErrs := append(append([]string{}, result.ResolveWarns...), result.SpokeWarns...)
+		for _, cr := range result.ExtraResults {
+			lookupErrs = append(lookupErrs, cr.ResolveWarns...)
+			lookupErrs = append(lookupErrs, cr.SpokeWarns...)
+		}
+		result.FailedLookups = chartLookups(chart.policy, rawYAML, lookupErrs, r, spokeR)
 
 		results = append(results, result)
 	}
 
+	for i := range results {
+		results[i].HubRequirements = DropCreated(results[i].HubRequirements, created)
+		for name, cr := range results[i].ExtraResults {
+			cr.HubRequirements = DropCreated(cr.HubRequirements, created)
+			results[i].ExtraResults[name] = cr
+		}
+	}
+
 	allConsumed := KeysToConsumed(keysByPolicy)
 	return allConsumed, results, nil
 }
@@ -591,25 +790,6 @@ func copyDir(src, dst string) error {
 	})
 }
 
-// stripNumberedSuffix removes a trailing `-<digits>` segment from a key.
-// E.g. "worker-nodes-zone-1" → "worker-nodes-zone",
-//
-//	"metallb-bgp-1" → "metallb-bgp",
-//	"nmstate" → "nmstate" (unchanged).
-func stripNumberedSuffix(key string) string {
-	idx := strings.LastIndex(key, "-")
-	if idx < 0 {
-		return key
-	}
-	suffix := key[idx+1:]
-	for _, c := range suffix {
-		if c < '0' || c > '9' {
-			return key
-		}
-	}
-	return key[:idx]
-}
-
 // deduplicateResources merges base and override slices, keeping the last
 // occurrence of any resource with the same (kind, namespace, name) key.
 // Resources in override take precedence over resources in base.
@@ -808,3 +988,11 @@ func discoverCharts(policiesDir string) ([]chartInfo, error) {
 
 	return charts, err
 }
+
+// labelFlows sets the policy and profile of flows.
+func labelFlows(flows []SecretFlow, policy, profile string) []SecretFlow {
+	for i := range flows {
+		flows[i].Policy, flows[i].Profile = policy, profile
+	}
+	return flows
+}
@@ -0,0 +1,119 @@
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/valuesschema"
+)
+
+// CMPDefinition is the repo-server ConfigManagementPlugin that substitutes
+// the ${...} placeholders of PolicyGenerator policies, relative to the repo
+// root.
+const CMPDefinition = "openshift-gitops/templates/policy-generator-cmp.yaml"
+
+// placeholderRe matches a ${NAME} placeholder. Shell expansions such as
+// ${HOSTNAME##*-} and regexp references such as ${1} are no placeholders and
+// pass through the CMP untouched.
+var placeholderRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// cmpTokenRe matches one substitution of the CMP's sed command.
+var cmpTokenRe = regexp.MustCompile(`s\|\\\$\{([A-Za-z_][A-Za-z0-9_]*)\}\|`)
+
+// CMPPlaceholders returns the placeholders the CMP under repoRoot
+// substitutes, sorted.
+func CMPPlaceholders(repoRoot string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(repoRoot, CMPDefinition))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, m := range cmpTokenRe.FindAllStringSubmatch(string(data), -1) {
+		if !contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s: no sed substitution of a ${...} placeholder", CMPDefinition)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// names returns the deployment's placeholder names, sorted.
+func (d Deployment) names() []string {
+	names := make([]string, 0, len(d.Placeholders))
+	for k := range d.Placeholders {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// placeholderFiles are the files of a PolicyGenerator policy that hold no
+// shell, so that any ${NAME} in them is meant for the CMP.
+var placeholderFiles = map[string]bool{
+	"policy-generator-config.yaml": true,
+	"kustomization.yaml":           true,
+}
+
+// CheckPlaceholders reports the ${NAME}s in the .yaml files of the
+// PolicyGenerator policy dir policyDir — the files the CMP substitutes — that
+// are not one of known, as file:line: any in policy-generator-config.yaml and
+// kustomization.yaml, and elsewhere those within typo distance of a known name
+// (see valuesschema.Closest). Such a placeholder renders literally. A
+// manifest's other ${NAME}s are shell or script variables, which the CMP
+// leaves alone.
+func CheckPlaceholders(policyDir string, known []string) ([]string, error) {
+	var errs []string
+	err := filepath.WalkDir(policyDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(policyDir, path)
+		for i, line := range strings.Split(string(data), "\n") {
+			for _, m := range placeholderRe.FindAllStringSubmatch(line, -1) {
+				if contains(known, m[1]) {
+					continue
+				}
+				closest := valuesschema.Closest(m[1], known)
+				if closest == "" && !placeholderFiles[d.Name()] {
+					continue
+				}
+				msg := fmt.Sprintf("%s:%d: unknown placeholder %s: the CMP substitutes only %s", rel, i+1, m[0], strings.Join(known, ", "))
+				if closest != "" {
+					msg += fmt.Sprintf(" (did you mean ${%s}?)", closest)
+				}
+				errs = append(errs, msg)
+			}
+		}
+		return nil
+	})
+	return errs, err
+}
+
+// LeftoverPlaceholders reports every ${NAME} the kustomize output rawYAML
+// still contains that is one of known, or within typo distance of one, with
+// its document and line: a placeholder the deployment did not substitute.
+func LeftoverPlaceholders(rawYAML string, known []string) []string {
+	var errs []string
+	for i, doc := range splitYAMLDocuments(rawYAML) {
+		for j, line := range strings.Split(doc, "\n") {
+			for _, m := range placeholderRe.FindAllStringSubmatch(line, -1) {
+				if !contains(known, m[1]) && valuesschema.Closest(m[1], known) == "" {
+					continue
+				}
+				errs = append(errs, fmt.Sprintf("%s, line %d: %s survived substitution: %s", docIdentity(doc, i), j+1, m[0], strings.TrimSpace(line)))
+			}
+		}
+	}
+	return errs
+}
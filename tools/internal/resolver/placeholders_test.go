@@ -0,0 +1,98 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestCMPPlaceholders checks that the CMP, the ApplicationSet's plugin env and
+// Deployment agree on the placeholder set: a placeholder the CMP gains must
+// reach the test render too.
+func TestCMPPlaceholders(t *testing.T) {
+	root := repoRoot(t)
+	cmp, err := CMPPlaceholders(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DefaultDeployment().names(); strings.Join(got, ",") != strings.Join(cmp, ",") {
+		t.Errorf("Deployment placeholders = %v, the CMP substitutes %v", got, cmp)
+	}
+
+	appSet, err := os.ReadFile(filepath.Join(root, "autoshift/templates/autoshift-app-set.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env []string
+	for _, m := range regexp.MustCompile(`(?m)^\s+- name: ([A-Z_]+)$`).FindAllStringSubmatch(string(appSet), -1) {
+		if !contains(env, m[1]) {
+			env = append(env, m[1])
+		}
+	}
+	sort.Strings(env)
+	if strings.Join(env, ",") != strings.Join(cmp, ",") {
+		t.Errorf("ApplicationSet plugin env = %v, the CMP substitutes %v", env, cmp)
+	}
+}
+
+func TestCheckPlaceholders(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFile(t, dir, "policy-generator-config.yaml", `policyDefaults:
+  namespace: ${POLICY_NAMESPCE}
+  remediationAction: ${REMEDIATION}
+  complianceType: ${COMPLIANCE}
+`)
+	mustWriteFile(t, filepath.Join(dir, "manifests"), "job.yaml", `command:
+- ORDINAL=${HOSTNAME##*-}; echo ${ORDINAL}
+replacement: ${1}${2}
+remediationAction: ${REMEDATION}
+`)
+	mustWriteFile(t, dir, "README.md", "set ${ANYTHING}\n")
+
+	got, err := CheckPlaceholders(dir, []string{"POLICY_NAMESPACE", "REMEDIATION"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"manifests/job.yaml:4: unknown placeholder ${REMEDATION}: the CMP substitutes only POLICY_NAMESPACE, REMEDIATION (did you mean ${REMEDIATION}?)",
+		"policy-generator-config.yaml:2: unknown placeholder ${POLICY_NAMESPCE}: the CMP substitutes only POLICY_NAMESPACE, REMEDIATION (did you mean ${POLICY_NAMESPACE}?)",
+		"policy-generator-config.yaml:4: unknown placeholder ${COMPLIANCE}: the CMP substitutes only POLICY_NAMESPACE, REMEDIATION",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CheckPlaceholders:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	left := LeftoverPlaceholders("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: x\ndata:\n  ns: ${POLICY_NAMESPCE}\n  ordinal: ${HOSTNAME##*-}\n  base: ${BASE}\n", []string{"POLICY_NAMESPACE", "REMEDIATION"})
+	if len(left) != 1 || left[0] != "ConfigMap/x (document 1), line 6: ${POLICY_NAMESPCE} survived substitution: ns: ${POLICY_NAMESPCE}" {
+		t.Errorf("LeftoverPlaceholders = %q", left)
+	}
+}
+
+// TestPolicyPlaceholders checks every PolicyGenerator policy in the repo
+// against the CMP's placeholders, without kustomize.
+func TestPolicyPlaceholders(t *testing.T) {
+	root := repoRoot(t)
+	cmp, err := CMPPlaceholders(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	charts, err := discoverCharts(filepath.Join(root, "policies"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chart := range charts {
+		if chart.kind != "kustomize" {
+			continue
+		}
+		errs, err := CheckPlaceholders(chart.dir, cmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range errs {
+			t.Errorf("%s: %s", chart.policy, e)
+		}
+	}
+}
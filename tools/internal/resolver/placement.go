@@ -0,0 +1,222 @@
+package resolver
+
+import (
+	"sort"
+	"strings"
+
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// FleetCluster is a managed cluster as a Placement sees it: its clusterset
+// membership, its labels and its ClusterClaims.
+type FleetCluster struct {
+	Name       string
+	ClusterSet string
+	Labels     map[string]string
+	Claims     map[string]string
+}
+
+// Requirement is one matchExpressions entry.
+type Requirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+// Selector is a label selector as Placement predicates use it.
+type Selector struct {
+	MatchLabels      map[string]string `json:"matchLabels"`
+	MatchExpressions []Requirement     `json:"matchExpressions"`
+}
+
+// Matches reports whether set satisfies every term of the selector. An unknown
+// operator matches nothing, the way the API server rejects it.
+func (s Selector) Matches(set map[string]string) bool {
+	for k, v := range s.MatchLabels {
+		if got, ok := set[k]; !ok || got != v {
+			return false
+		}
+	}
+	for _, req := range s.MatchExpressions {
+		got, ok := set[req.Key]
+		switch req.Operator {
+		case "In":
+			if !ok || !contains(req.Values, got) {
+				return false
+			}
+		case "NotIn":
+			if ok && contains(req.Values, got) {
+				return false
+			}
+		case "Exists":
+			if !ok {
+				return false
+			}
+		case "DoesNotExist":
+			if ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Predicate is one requiredClusterSelector; both selectors must match.
+type Predicate struct {
+	LabelSelector Selector `json:"labelSelector"`
+	ClaimSelector Selector `json:"claimSelector"`
+}
+
+// Placement is the part of a cluster.open-cluster-management.io Placement that
+// decides which clusters it selects. Tolerations and numberOfClusters are
+// ignored: a fleet cluster carries no taints and every match is selected.
+type Placement struct {
+	Name        string
+	Namespace   string
+	ClusterSets []string
+	Predicates  []Predicate
+}
+
+// Selects reports whether the placement picks c. bound are the clustersets
+// bound to the placement's namespace by ManagedClusterSetBindings: without
+// spec.clusterSets a Placement draws from all of them, with it from the bound
+// ones it names. The predicates are ORed; no predicates select every cluster.
+func (p Placement) Selects(c FleetCluster, bound []string) bool {
+	if !contains(bound, c.ClusterSet) {
+		return false
+	}
+	if len(p.ClusterSets) > 0 && !contains(p.ClusterSets, c.ClusterSet) {
+		return false
+	}
+	if len(p.Predicates) == 0 {
+		return true
+	}
+	for _, pred := range p.Predicates {
+		if pred.LabelSelector.Matches(c.Labels) && pred.ClaimSelector.Matches(c.Claims) {
+			return true
+		}
+	}
+	return false
+}
+
+// Placements is the placement wiring of a resolved render: the Placements,
+// which policies each one is bound to, and the clustersets bound per
+// namespace.
+type Placements struct {
+	Placements []Placement
+	// Bound maps namespace/placement to the Policy and PolicySet names its
+	// PlacementBindings list.
+	Bound map[string][]string
+	// ClusterSets maps a namespace to the clustersets its
+	// ManagedClusterSetBindings bind.
+	ClusterSets map[string][]string
+}
+
+// ParsePlacements collects the placement wiring from multi-document YAML.
+// Documents that fail to decode are skipped; validateYAML reports them.
+func ParsePlacements(multiDocYAML string) *Placements {
+	out := &Placements{Bound: map[string][]string{}, ClusterSets: map[string][]string{}}
+	for _, doc := range splitYAMLDocuments(multiDocYAML) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		var obj struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				ClusterSets []string `json:"clusterSets"`
+				ClusterSet  string   `json:"clusterSet"`
+				Predicates  []struct {
+					RequiredClusterSelector Predicate `json:"requiredClusterSelector"`
+				} `json:"predicates"`
+			} `json:"spec"`
+			PlacementRef struct {
+				Name string `json:"name"`
+				Kind string `json:"kind"`
+			} `json:"placementRef"`
+			Subjects []struct {
+				Name string `json:"name"`
+				Kind string `json:"kind"`
+			} `json:"subjects"`
+		}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil {
+			continue
+		}
+		if !strings.HasPrefix(obj.APIVersion, "cluster.open-cluster-management.io/") &&
+			!strings.HasPrefix(obj.APIVersion, "policy.open-cluster-management.io/") {
+			continue
+		}
+		ns := obj.Metadata.Namespace
+		switch obj.Kind {
+		case "Placement":
+			p := Placement{Name: obj.Metadata.Name, Namespace: ns, ClusterSets: obj.Spec.ClusterSets}
+			for _, pred := range obj.Spec.Predicates {
+				p.Predicates = append(p.Predicates, pred.RequiredClusterSelector)
+			}
+			out.Placements = append(out.Placements, p)
+		case "PlacementBinding":
+			if obj.PlacementRef.Kind != "" && obj.PlacementRef.Kind != "Placement" {
+				continue
+			}
+			key := ns + "/" + obj.PlacementRef.Name
+			for _, s := range obj.Subjects {
+				out.Bound[key] = append(out.Bound[key], s.Name)
+			}
+		case "ManagedClusterSetBinding":
+			set := obj.Spec.ClusterSet
+			if set == "" {
+				set = obj.Metadata.Name
+			}
+			if !contains(out.ClusterSets[ns], set) {
+				out.ClusterSets[ns] = append(out.ClusterSets[ns], set)
+			}
+		}
+	}
+	sort.SliceStable(out.Placements, func(i, j int) bool {
+		return out.Placements[i].Namespace+"/"+out.Placements[i].Name < out.Placements[j].Namespace+"/"+out.Placements[j].Name
+	})
+	return out
+}
+
+// Decision is one policy a placement puts on one cluster.
+type Decision struct {
+	Policy    string
+	Placement string
+	Cluster   FleetCluster
+}
+
+// Decide evaluates every bound placement against fleet and returns what lands
+// where. A placement no PlacementBinding references places nothing.
+func (p *Placements) Decide(fleet []FleetCluster) []Decision {
+	var out []Decision
+	for _, pl := range p.Placements {
+		policies := p.Bound[pl.Namespace+"/"+pl.Name]
+		if len(policies) == 0 {
+			continue
+		}
+		for _, c := range fleet {
+			if !pl.Selects(c, p.ClusterSets[pl.Namespace]) {
+				continue
+			}
+			for _, pol := range policies {
+				out = append(out, Decision{Policy: pol, Placement: pl.Name, Cluster: c})
+			}
+		}
+	}
+	return out
+}
+
+func contains(xs []string, x string) bool {
+	for _, v := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelector_Matches(t *testing.T) {
+	set := map[string]string{"autoshift.io/odf": "true", "autoshift.io/cluster-type": "hub"}
+	cases := []struct {
+		name string
+		sel  Selector
+		want bool
+	}{
+		{"empty", Selector{}, true},
+		{"matchLabels", Selector{MatchLabels: map[string]string{"autoshift.io/odf": "true"}}, true},
+		{"matchLabels mismatch", Selector{MatchLabels: map[string]string{"autoshift.io/odf": "false"}}, false},
+		{"In", Selector{MatchExpressions: []Requirement{{Key: "autoshift.io/cluster-type", Operator: "In", Values: []string{"hub"}}}}, true},
+		{"In absent", Selector{MatchExpressions: []Requirement{{Key: "autoshift.io/acs", Operator: "In", Values: []string{"true"}}}}, false},
+		{"NotIn absent", Selector{MatchExpressions: []Requirement{{Key: "autoshift.io/acs", Operator: "NotIn", Values: []string{"true"}}}}, true},
+		{"NotIn", Selector{MatchExpressions: []Requirement{{Key: "autoshift.io/cluster-type", Operator: "NotIn", Values: []string{"hub"}}}}, false},
+		{"Exists", Selector{MatchExpressions: []Requirement{{Key: "autoshift.io/odf", Operator: "Exists"}}}, true},
+		{"DoesNotExist", Selector{MatchExpressions: []Requirement{{Key: "autoshift.io/odf", Operator: "DoesNotExist"}}}, false},
+		{"unknown operator", Selector{MatchExpressions: []Requirement{{Key: "autoshift.io/odf", Operator: "Gt"}}}, false},
+	}
+	for _, tc := range cases {
+		if got := tc.sel.Matches(set); got != tc.want {
+			t.Errorf("%s: Matches = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPlacements_Decide(t *testing.T) {
+	out := ParsePlacements(`
+apiVersion: cluster.open-cluster-management.io/v1beta1
+kind: Placement
+metadata:
+  name: placement-odf
+  namespace: policies-hub1
+spec:
+  predicates:
+    - requiredClusterSelector:
+        labelSelector:
+          matchExpressions:
+            - key: autoshift.io/odf
+              operator: In
+              values: ['true']
+---
+apiVersion: cluster.open-cluster-management.io/v1beta1
+kind: Placement
+metadata:
+  name: placement-hubs
+  namespace: policies-hub1
+spec:
+  clusterSets: [hub, unbound]
+---
+apiVersion: cluster.open-cluster-management.io/v1beta1
+kind: Placement
+metadata:
+  name: placement-unbound
+  namespace: policies-hub1
+---
+apiVersion: policy.open-cluster-management.io/v1
+kind: PlacementBinding
+metadata:
+  name: binding-odf
+  namespace: policies-hub1
+placementRef:
+  name: placement-odf
+  kind: Placement
+subjects:
+  - name: policy-odf
+    kind: Policy
+---
+apiVersion: policy.open-cluster-management.io/v1
+kind: PlacementBinding
+metadata:
+  name: binding-hubs
+  namespace: policies-hub1
+placementRef:
+  name: placement-hubs
+  kind: Placement
+subjects:
+  - name: policy-hub-a
+    kind: Policy
+  - name: policy-hub-b
+    kind: Policy
+---
+apiVersion: cluster.open-cluster-management.io/v1beta2
+kind: ManagedClusterSetBinding
+metadata:
+  name: hub
+  namespace: policies-hub1
+spec:
+  clusterSet: hub
+---
+apiVersion: cluster.open-cluster-management.io/v1beta2
+kind: ManagedClusterSetBinding
+metadata:
+  name: managed
+  namespace: policies-hub1
+spec:
+  clusterSet: managed
+`)
+	fleet := []FleetCluster{
+		{Name: "local-cluster", ClusterSet: "hub", Labels: map[string]string{"autoshift.io/odf": "false"}},
+		{Name: "spoke", ClusterSet: "managed", Labels: map[string]string{"autoshift.io/odf": "true"}},
+		{Name: "stray", ClusterSet: "unbound", Labels: map[string]string{"autoshift.io/odf": "true"}},
+	}
+
+	var got []string
+	for _, d := range out.Decide(fleet) {
+		got = append(got, d.Policy+"@"+d.Cluster.Name)
+	}
+	want := "policy-hub-a@local-cluster policy-hub-b@local-cluster policy-odf@spoke"
+	if strings.Join(got, " ") != want {
+		t.Errorf("decisions = %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,270 @@
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/valueslint"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// PreflightFinding is a problem a policy would hit on a live hub.
+type PreflightFinding struct {
+	Cluster string // "" for every cluster
+	Policy  string // "" for a problem of the cluster itself, or a chart that does not render
+	Problem string
+}
+
+func (f PreflightFinding) String() string {
+	s := f.Cluster
+	if s == "" {
+		s = "all clusters"
+	}
+	if f.Policy != "" {
+		s += ": " + f.Policy
+	}
+	return s + ": " + f.Problem
+}
+
+// LoadHubSnapshot reads an export of a live hub — `oc get -o yaml` (or -o
+// json) dumps, see ReadClusterExport — from the files and directories in
+// paths. Objects without an apiVersion, kind or name are skipped.
+func LoadHubSnapshot(paths []string) ([]unstructured.Unstructured, error) {
+	files, err := ClusterExportPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	var out []unstructured.Unstructured
+	for _, f := range files {
+		objs, err := ReadClusterExport(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objs {
+			u := unstructured.Unstructured{Object: obj}
+			if u.GetAPIVersion() != "" && u.GetKind() != "" && u.GetName() != "" {
+				out = append(out, u)
+			}
+		}
+	}
+	return out, nil
+}
+
+// SnapshotFleet returns the ManagedClusters in snapshot as Placements see
+// them, sorted by name: their labels, clusterset label and the ClusterClaims
+// their status reports.
+func SnapshotFleet(snapshot []unstructured.Unstructured) []FleetCluster {
+	var fleet []FleetCluster
+	for _, u := range snapshot {
+		if u.GetKind() != "ManagedCluster" || !strings.HasPrefix(u.GetAPIVersion(), "cluster.open-cluster-management.io/") {
+			continue
+		}
+		c := FleetCluster{Name: u.GetName(), Labels: u.GetLabels(), Claims: map[string]string{}}
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		c.ClusterSet = c.Labels["cluster.open-cluster-management.io/clusterset"]
+		claims, _, _ := unstructured.NestedSlice(u.Object, "status", "clusterClaims")
+		for _, claim := range claims {
+			claim, _ := claim.(map[string]interface{})
+			name, _ := claim["name"].(string)
+			value, _ := claim["value"].(string)
+			if name != "" {
+				c.Claims[name] = value
+			}
+		}
+		fleet = append(fleet, c)
+	}
+	sort.Slice(fleet, func(i, j int) bool { return fleet[i].Name < fleet[j].Name })
+	return fleet
+}
+
+// snapshotClusterSets maps each namespace to the clustersets its
+// ManagedClusterSetBindings in snapshot bind.
+func snapshotClusterSets(snapshot []unstructured.Unstructured) map[string][]string {
+	out := map[string][]string{}
+	for _, u := range snapshot {
+		if u.GetKind() != "ManagedClusterSetBinding" {
+			continue
+		}
+		set, _, _ := unstructured.NestedString(u.Object, "spec", "clusterSet")
+		if set == "" {
+			set = u.GetName()
+		}
+		if !contains(out[u.GetNamespace()], set) {
+			out[u.GetNamespace()] = append(out[u.GetNamespace()], set)
+		}
+	}
+	return out
+}
+
+// Preflight renders every policy under policiesDir for dep and resolves its
+// hub templates for each cluster of fleet that its placements select, with r
+// seeded from a live hub's snapshot (see LoadHubSnapshot) in place of
+// tools/testdata. It reports what would fail on that hub: a chart that does
+// not render, a hub template that errors (a Secret or ConfigMap fromSecret /
+// fromConfigMap reads that does not exist), a hub template that prints
+// <no value> (a label or config key the cluster lacks), and, when lint is not
+// nil, an autoshift.io label on a cluster no values file declares — likely a
+// typo. A ${...} placeholder dep leaves in a PolicyGenerator policy is a
+// finding too. Helm policies render with dep's values file, or their own defaults
+// when it names none.
+func Preflight(policiesDir string, dep Deployment, r *Resolver, snapshot []unstructured.Unstructured, fleet []FleetCluster, lint *valueslint.Linter) ([]PreflightFinding, error) {
+	charts, err := discoverCharts(policiesDir)
+	if err != nil {
+		return nil, fmt.Errorf("discover charts: %w", err)
+	}
+	sort.Slice(charts, func(i, j int) bool { return charts[i].policy < charts[j].policy })
+	r.SetLocalResources(snapshot)
+	bound := snapshotClusterSets(snapshot)
+
+	var out []PreflightFinding
+	if lint != nil {
+		out = append(out, labelTypos(fleet, lint)...)
+	}
+	for _, chart := range charts {
+		rawYAML, err := renderForHub(chart, dep)
+		if err != nil {
+			out = append(out, PreflightFinding{Policy: chart.policy, Problem: fmt.Sprintf("does not render: %v", err)})
+			continue
+		}
+		if chart.kind == "kustomize" {
+			for _, e := range LeftoverPlaceholders(rawYAML, dep.names()) {
+				out = append(out, PreflightFinding{Policy: chart.policy, Problem: e})
+			}
+		}
+		out = append(out, preflightChart(chart.policy, rawYAML, r, fleet, bound)...)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Cluster != out[j].Cluster {
+			return out[i].Cluster < out[j].Cluster
+		}
+		return out[i].Policy < out[j].Policy
+	})
+	return out, nil
+}
+
+// renderForHub renders a policy as the deployment does: kustomize with dep's
+// placeholders, or helm with dep's values file and without the .example
+// files the lint activates.
+func renderForHub(chart chartInfo, dep Deployment) (string, error) {
+	if chart.kind == "kustomize" {
+		return KustomizeBuildFor(chart.dir, dep)
+	}
+	var values []string
+	if f := dep.valuesFor(chart.dir, ""); f != "" {
+		if _, err := os.Stat(f); err != nil {
+			return "", err
+		}
+		values = append(values, f)
+	}
+	return HelmTemplate(chart.dir, values...)
+}
+
+// preflightChart resolves the Policies in one chart's rawYAML for every
+// cluster of fleet its placements select. bound adds the hub's
+// ManagedClusterSetBindings to the ones the chart renders.
+func preflightChart(policy, rawYAML string, r *Resolver, fleet []FleetCluster, bound map[string][]string) []PreflightFinding {
+	placements := ParsePlacements(rawYAML)
+	for ns, sets := range bound {
+		for _, set := range sets {
+			if !contains(placements.ClusterSets[ns], set) {
+				placements.ClusterSets[ns] = append(placements.ClusterSets[ns], set)
+			}
+		}
+	}
+	placed := map[string]map[string]bool{}
+	for _, d := range placements.Decide(fleet) {
+		if placed[d.Cluster.Name] == nil {
+			placed[d.Cluster.Name] = map[string]bool{}
+		}
+		placed[d.Cluster.Name][d.Policy] = true
+	}
+
+	var out []PreflightFinding
+	for _, c := range fleet {
+		if len(placed[c.Name]) == 0 {
+			continue
+		}
+		docs := policyDocuments(rawYAML, placed[c.Name])
+		if len(docs) == 0 {
+			continue
+		}
+		res := r.ResolvePolicy(joinYAMLDocuments(docs), HubContext{ManagedClusterName: c.Name, ManagedClusterLabels: c.Labels})
+		r.takeMisses() // a lookup that finds nothing is no failure
+		for _, e := range res.Errors {
+			out = append(out, PreflightFinding{Cluster: c.Name, Policy: policy, Problem: "hub templates fail: " + trimTemplateDump(e)})
+		}
+		for _, doc := range splitYAMLDocuments(res.Resolved) {
+			for _, line := range strings.Split(doc, "\n") {
+				if strings.Contains(line, "<no value>") {
+					out = append(out, PreflightFinding{Cluster: c.Name, Policy: policy, Problem: fmt.Sprintf(
+						"%s: <no value> — a label or config key the hub template reads is not set for this cluster: %s",
+						docIdentity(doc, 0), strings.TrimSpace(line))})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// trimTemplateDump drops the Policy JSON a template error quotes, keeping
+// the policy name and the template error itself.
+func trimTemplateDump(e string) string {
+	name, rest, ok := strings.Cut(e, ": failed to resolve the template ")
+	if !ok {
+		return e
+	}
+	if i := strings.Index(rest, "}: template: "); i >= 0 {
+		return name + ": " + rest[i+len("}: "):]
+	}
+	return e
+}
+
+// policyDocuments returns the Policy documents of rawYAML whose names are in
+// names.
+func policyDocuments(rawYAML string, names map[string]bool) []string {
+	var out []string
+	for _, doc := range splitYAMLDocuments(rawYAML) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil || obj["kind"] != "Policy" {
+			continue
+		}
+		if name, _ := nestedString(obj, "metadata", "name"); names[name] {
+			out = append(out, doc)
+		}
+	}
+	return out
+}
+
+// labelTypos reports every autoshift.io label on a cluster of fleet that lint
+// does not know.
+func labelTypos(fleet []FleetCluster, lint *valueslint.Linter) []PreflightFinding {
+	var out []PreflightFinding
+	for _, c := range fleet {
+		keys := make([]string, 0, len(c.Labels))
+		for k := range c.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			key, ok := strings.CutPrefix(k, "autoshift.io/")
+			if !ok {
+				continue
+			}
+			known, closest := lint.CheckClusterLabel(key)
+			if known {
+				continue
+			}
+			msg := fmt.Sprintf("unknown label %s: no _example*.yaml file declares it", k)
+			if closest != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", "autoshift.io/"+closest)
+			}
+			out = append(out, PreflightFinding{Cluster: c.Name, Problem: msg})
+		}
+	}
+	return out
+}
@@ -0,0 +1,174 @@
+package resolver
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/labels"
+	"github.com/auto-shift/autoshiftv2/tools/internal/valueslint"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+func TestPreflight(t *testing.T) {
+	var snapshot []unstructured.Unstructured
+	for _, doc := range splitYAMLDocuments(`apiVersion: cluster.open-cluster-management.io/v1
+kind: ManagedCluster
+metadata:
+  name: a
+  labels:
+    cluster.open-cluster-management.io/clusterset: managed
+    autoshift.io/odf: 'true'
+status:
+  clusterClaims:
+  - name: platform.open-cluster-management.io
+    value: AWS
+---
+apiVersion: cluster.open-cluster-management.io/v1
+kind: ManagedCluster
+metadata:
+  name: b
+  labels:
+    cluster.open-cluster-management.io/clusterset: managed
+    autoshift.io/odf: 'true'
+    autoshift.io/metalb: 'true'
+---
+apiVersion: cluster.open-cluster-management.io/v1
+kind: ManagedCluster
+metadata:
+  name: d
+  labels:
+    cluster.open-cluster-management.io/clusterset: managed
+    autoshift.io/odf: 'true'
+---
+apiVersion: cluster.open-cluster-management.io/v1
+kind: ManagedCluster
+metadata:
+  name: c
+  labels:
+    cluster.open-cluster-management.io/clusterset: managed
+---
+apiVersion: cluster.open-cluster-management.io/v1beta2
+kind: ManagedClusterSetBinding
+metadata:
+  name: managed
+  namespace: policies-autoshift
+spec:
+  clusterSet: managed
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: a-creds
+  namespace: creds
+data:
+  password: ` + base64.StdEncoding.EncodeToString([]byte("p")) + `
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a.rendered-config
+  namespace: policies-autoshift
+data:
+  config: 'region: east'
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b.rendered-config
+  namespace: policies-autoshift
+data:
+  config: 'zone: east'
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: b-creds
+  namespace: creds
+data:
+  password: ` + base64.StdEncoding.EncodeToString([]byte("p")) + `
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: d.rendered-config
+  namespace: policies-autoshift
+data:
+  config: 'region: west'
+`) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil {
+			t.Fatal(err)
+		}
+		snapshot = append(snapshot, unstructured.Unstructured{Object: obj})
+	}
+
+	raw := configurationPolicy(`object-templates:
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: odf
+      namespace: openshift-storage
+    data:
+      password: '{{hub fromSecret "creds" (printf "%s-creds" .ManagedClusterName) "password" hub}}'
+      region: '{{hub (fromConfigMap .PolicyMetadata.namespace (printf "%s.rendered-config" .ManagedClusterName) "config" | fromYaml).region hub}}'
+`) + `---
+apiVersion: cluster.open-cluster-management.io/v1beta1
+kind: Placement
+metadata:
+  name: placement-test
+  namespace: policies-autoshift
+spec:
+  predicates:
+  - requiredClusterSelector:
+      labelSelector:
+        matchExpressions:
+        - key: autoshift.io/odf
+          operator: Exists
+---
+apiVersion: policy.open-cluster-management.io/v1
+kind: PlacementBinding
+metadata:
+  name: placement-test
+  namespace: policies-autoshift
+placementRef:
+  name: placement-test
+  kind: Placement
+subjects:
+- name: policy-test
+  kind: Policy
+`
+
+	fleet := SnapshotFleet(snapshot)
+	if len(fleet) != 4 || fleet[0].Claims[ClaimPlatform] != "AWS" || fleet[1].ClusterSet != "managed" {
+		t.Fatalf("fleet: %+v", fleet)
+	}
+	r, err := NewResolver(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := preflightChart("stable/test", raw, r, fleet, snapshotClusterSets(snapshot))
+	lint := valueslint.New(nil, map[string]*labels.Declared{"odf": {Key: "odf"}, "metallb": {Key: "metallb"}}, nil)
+	got = append(labelTypos(fleet, lint), got...)
+
+	var lines []string
+	for _, f := range got {
+		lines = append(lines, f.String())
+	}
+	joined := strings.Join(lines, "\n")
+	for _, want := range []string{
+		`b: unknown label autoshift.io/metalb: no _example*.yaml file declares it (did you mean "autoshift.io/metallb"?)`,
+		`b: stable/test: Policy/policy-test (document 1): <no value> — a label or config key the hub template reads is not set for this cluster: region: <no value>`,
+		`d: stable/test: hub templates fail: policy-test: template: tmpl:19:35: executing "tmpl" at <fromSecret "creds" (printf "%s-creds" .ManagedClusterName) "password">: error calling fromSecret: failed to get the secret d-creds from creds: secrets "d-creds" not found`,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("findings lack %q:\n%s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "a: ") || strings.Contains(joined, "c: ") {
+		t.Errorf("a resolves cleanly and c is not placed:\n%s", joined)
+	}
+}
@@ -0,0 +1,243 @@
+package resolver
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/stolostron/go-template-utils/v7/pkg/templates"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// ACM encrypts hub template output that handles Secret data: with encryption
+// on, protect, fromSecret and copySecretData return
+// "$ocm_encrypted:<base64>" values, AES-CBC encrypted with the managed
+// cluster's key. The propagator keeps that key in the cluster namespace's
+// EncryptionKeySecret and records the IV on each policy template it
+// encrypted; the config-policy-controller decrypts with both.
+const (
+	EncryptionKeySecret    = "policy-encryption-key"
+	EncryptionIVAnnotation = "policy.open-cluster-management.io/encryption-iv"
+
+	encryptedPrefix = "$ocm_encrypted:"
+)
+
+// TestEncryptionKey is the fixed AES-256 key the offline hub encrypts
+// clusterName's values with. A real hub generates one at random.
+func TestEncryptionKey(clusterName string) []byte {
+	sum := sha256.Sum256([]byte("autoshift-offline-policy-encryption/" + clusterName))
+	return sum[:]
+}
+
+// testEncryptionIV is the IV of a Policy: fixed per namespace and name, where
+// the propagator generates one at random and keeps it.
+func testEncryptionIV(policyNamespace, policyName string) []byte {
+	sum := sha256.Sum256([]byte(policyNamespace + "/" + policyName))
+	return sum[:templates.IVSize]
+}
+
+// hubEncryption is the EncryptionConfig the hub resolves a Policy for
+// clusterName with.
+func hubEncryption(clusterName, policyNamespace, policyName string) templates.EncryptionConfig {
+	return templates.EncryptionConfig{
+		EncryptionEnabled:    true,
+		AESKey:               TestEncryptionKey(clusterName),
+		InitializationVector: testEncryptionIV(policyNamespace, policyName),
+	}
+}
+
+// EncryptionKeyObject is the EncryptionKeySecret the propagator syncs to
+// clusterName, in the cluster namespace the config-policy-controller reads it
+// from.
+func EncryptionKeyObject(clusterName string) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": EncryptionKeySecret, "namespace": clusterName},
+		"data":       map[string]interface{}{"key": base64.StdEncoding.EncodeToString(TestEncryptionKey(clusterName))},
+	}}
+}
+
+// annotateEncryptionIV sets EncryptionIVAnnotation on every policy template
+// of the resolved Policy policyJSON that holds an encrypted value, as the
+// propagator does.
+func annotateEncryptionIV(policyJSON []byte, iv []byte) ([]byte, error) {
+	if !strings.Contains(string(policyJSON), encryptedPrefix) {
+		return policyJSON, nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(policyJSON, &obj); err != nil {
+		return nil, err
+	}
+	spec, _ := obj["spec"].(map[string]interface{})
+	policyTemplates, _ := spec["policy-templates"].([]interface{})
+	for _, pt := range policyTemplates {
+		pt, _ := pt.(map[string]interface{})
+		def, _ := pt["objectDefinition"].(map[string]interface{})
+		if def == nil {
+			continue
+		}
+		data, err := json.Marshal(def)
+		if err != nil || !strings.Contains(string(data), encryptedPrefix) {
+			continue
+		}
+		u := unstructured.Unstructured{Object: def}
+		annotations := u.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[EncryptionIVAnnotation] = base64.StdEncoding.EncodeToString(iv)
+		u.SetAnnotations(annotations)
+	}
+	return json.Marshal(obj)
+}
+
+// decryptOptions returns the ResolveOptions the config-policy-controller
+// resolves the policy template def with: decryption on when def carries an
+// IV, with the key from the resolver's EncryptionKeySecret.
+func (r *Resolver) decryptOptions(def map[string]interface{}) (*templates.ResolveOptions, error) {
+	ivStr := (&unstructured.Unstructured{Object: def}).GetAnnotations()[EncryptionIVAnnotation]
+	if ivStr == "" {
+		return &templates.ResolveOptions{}, nil
+	}
+	iv, err := base64.StdEncoding.DecodeString(ivStr)
+	if err != nil {
+		return nil, fmt.Errorf("annotation %s is not base64: %w", EncryptionIVAnnotation, err)
+	}
+	for _, u := range r.local {
+		if u.GetAPIVersion() != "v1" || u.GetKind() != "Secret" || u.GetName() != EncryptionKeySecret {
+			continue
+		}
+		keyStr, _ := nestedString(u.Object, "data", "key")
+		key, err := base64.StdEncoding.DecodeString(keyStr)
+		if err != nil {
+			return nil, fmt.Errorf("Secret %s/%s: data.key is not base64: %w", u.GetNamespace(), u.GetName(), err)
+		}
+		return &templates.ResolveOptions{EncryptionConfig: templates.EncryptionConfig{
+			DecryptionEnabled:    true,
+			AESKey:               key,
+			InitializationVector: iv,
+		}}, nil
+	}
+	return nil, fmt.Errorf("holds encrypted values, but the cluster has no %s Secret to decrypt them with", EncryptionKeySecret)
+}
+
+// nonSecretKeys are the Secret keys SecretLeaks does not look for: they say
+// where a service is or who connects to it, not how, and their values (a
+// host, a port, a bucket, a database user) are all over the policies anyway.
+// Every other key is checked, whatever its name or the length of its value.
+var nonSecretKeys = map[string]bool{
+	// CloudNativePG app Secrets
+	"host": true, "port": true, "dbname": true, "username": true,
+	// ObjectBucketClaim Secrets and the image registry's storage
+	"BUCKET_HOST": true, "BUCKET_NAME": true, "BUCKET_PORT": true, "BUCKET_REGION": true, "BUCKET_SUBREGION": true,
+	"bucket": true, "region": true,
+	// ACS init bundle and ACM hub-kubeconfig-secret
+	"acs-host": true, "cluster-name": true, "agent-name": true,
+}
+
+// SecretLeaks reports every credential in secrets — decoded data, data as
+// stored, and stringData, under any key but nonSecretKeys — that appears
+// in clear text in a Policy of the hub-resolved YAML. A hub template that
+// reads a Secret must hand its values to the managed cluster through
+// fromSecret, copySecretData or protect, which the hub encrypts; a lookup of
+// the Secret, or a value copied into the values files, ends up readable by
+// anyone who can read Policies. Empty values are not checked, and a match
+// must not be part of a longer name (ACCESS_KEY_ID in AWS_ACCESS_KEY_ID).
+func SecretLeaks(hubResolved string, secrets []unstructured.Unstructured) []string {
+	type secretValue struct{ value, source string }
+	var values []secretValue
+	for _, s := range secrets {
+		if s.GetAPIVersion() != "v1" || s.GetKind() != "Secret" {
+			continue
+		}
+		id := s.GetNamespace() + "/" + s.GetName()
+		data, _ := s.Object["data"].(map[string]interface{})
+		for _, key := range sortedKeys(data) {
+			if nonSecretKeys[key] {
+				continue
+			}
+			encoded, _ := data[key].(string)
+			values = append(values, secretValue{encoded, fmt.Sprintf("Secret %s data.%s", id, key)})
+			if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+				values = append(values, secretValue{string(decoded), fmt.Sprintf("Secret %s data.%s (decoded)", id, key)})
+			}
+		}
+		stringData, _ := s.Object["stringData"].(map[string]interface{})
+		for _, key := range sortedKeys(stringData) {
+			if nonSecretKeys[key] {
+				continue
+			}
+			v, _ := stringData[key].(string)
+			values = append(values, secretValue{v, fmt.Sprintf("Secret %s stringData.%s", id, key)})
+		}
+	}
+
+	found := map[string]bool{}
+	for _, doc := range splitYAMLDocuments(hubResolved) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil || obj["kind"] != "Policy" {
+			continue
+		}
+		name, _ := nestedString(obj, "metadata", "name")
+		var strs []string
+		collectStrings(obj, &strs)
+		for _, sv := range values {
+			if strings.TrimSpace(sv.value) == "" {
+				continue
+			}
+			for _, s := range strs {
+				if containsWord(s, sv.value) {
+					found[fmt.Sprintf("Policy %s: holds the value of %s in clear text — read it with fromSecret or copySecretData, or wrap it in protect", name, sv.source)] = true
+					break
+				}
+			}
+		}
+	}
+	out := make([]string, 0, len(found))
+	for f := range found {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// containsWord reports whether s contains v, not as part of a longer name.
+func containsWord(s, v string) bool {
+	for i := 0; ; i++ {
+		j := strings.Index(s[i:], v)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(v)
+		if (start == 0 || !isNameChar(s[start-1])) && (end == len(s) || !isNameChar(s[end])) {
+			return true
+		}
+		i = start
+	}
+}
+
+func isNameChar(c byte) bool {
+	return isHostChar(c) || c == '_'
+}
+
+// collectStrings appends every string in v, map keys included, to out.
+func collectStrings(v interface{}, out *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			*out = append(*out, k)
+			collectStrings(child, out)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectStrings(child, out)
+		}
+	case string:
+		*out = append(*out, v)
+	}
+}
@@ -0,0 +1,132 @@
+package resolver
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func protectTestSecret() unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "creds", "namespace": "hub-secrets"},
+		"data": map[string]interface{}{
+			"password": base64.StdEncoding.EncodeToString([]byte("s3cret-hub-password")),
+			"host":     base64.StdEncoding.EncodeToString([]byte("db.example.com")),
+		},
+	}}
+}
+
+const protectTestPolicy = `object-templates:
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: Secret
+    metadata:
+      name: copied
+      namespace: app
+    data:
+      password: '{{hub fromSecret "hub-secrets" "creds" "password" hub}}'
+    stringData:
+      token: '{{hub protect "plain-token-value" hub}}'
+`
+
+func TestProtect_HubEncryptsSpokeDecrypts(t *testing.T) {
+	hub, err := NewResolver([]unstructured.Unstructured{protectTestSecret()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := hub.ResolvePolicy(configurationPolicy(protectTestPolicy), HubContext{ManagedClusterName: "c1"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("hub: %v", res.Errors)
+	}
+	for _, want := range []string{encryptedPrefix, EncryptionIVAnnotation} {
+		if !strings.Contains(res.Resolved, want) {
+			t.Errorf("hub output lacks %q:\n%s", want, res.Resolved)
+		}
+	}
+	if leaks := SecretLeaks(res.Resolved, []unstructured.Unstructured{protectTestSecret()}); len(leaks) > 0 {
+		t.Errorf("encrypted values reported as leaks: %v", leaks)
+	}
+
+	spoke, err := NewSpokeResolver([]unstructured.Unstructured{EncryptionKeyObject("c1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := spoke.ResolveSpokePolicies(res.Resolved)
+	if len(out.Errors) > 0 {
+		t.Fatalf("spoke: %v", out.Errors)
+	}
+	for _, want := range []string{"password: " + base64.StdEncoding.EncodeToString([]byte("s3cret-hub-password")), "token: plain-token-value"} {
+		if !strings.Contains(out.Resolved, want) {
+			t.Errorf("spoke output lacks %q:\n%s", want, out.Resolved)
+		}
+	}
+
+	for name, local := range map[string][]unstructured.Unstructured{
+		"another cluster's key": {EncryptionKeyObject("c2")},
+		"no key":                nil,
+	} {
+		spoke, err := NewSpokeResolver(local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out := spoke.ResolveSpokePolicies(res.Resolved); len(out.Errors) == 0 {
+			t.Errorf("%s: decrypted without error:\n%s", name, out.Resolved)
+		}
+	}
+}
+
+func TestSecretLeaks(t *testing.T) {
+	// Neither key names a credential, and pin is short: every key but the
+	// listed non-secret ones is checked.
+	conn := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "conn", "namespace": "hub-secrets"},
+		"data": map[string]interface{}{
+			"uri": base64.StdEncoding.EncodeToString([]byte("postgres://db:5432/app")),
+			"pin": base64.StdEncoding.EncodeToString([]byte("4711")),
+		},
+	}}
+	secrets := []unstructured.Unstructured{protectTestSecret(), conn}
+	hub, err := NewResolver(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := hub.ResolvePolicy(configurationPolicy(`object-templates:
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: leaky
+      namespace: app
+    data:
+      host: '{{hub (lookup "v1" "Secret" "hub-secrets" "creds").data.host | base64dec hub}}'
+      url: '{{hub printf "postgres://app:%s@db" ((lookup "v1" "Secret" "hub-secrets" "creds").data.password | base64dec) hub}}'
+      name: 'not-s3cret-hub-password-at-all_s3cret-hub-password2'
+      uri: '{{hub (lookup "v1" "Secret" "hub-secrets" "conn").data.uri | base64dec hub}}'
+      pin: '{{hub (lookup "v1" "Secret" "hub-secrets" "conn").data.pin | base64dec hub}}'
+`), HubContext{ManagedClusterName: "c1"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("hub: %v", res.Errors)
+	}
+	leaks := SecretLeaks(res.Resolved, secrets)
+	var sources []string
+	for _, l := range leaks {
+		sources = append(sources, l[strings.Index(l, "Secret "):strings.Index(l, " in clear text")])
+	}
+	want := []string{
+		"Secret hub-secrets/conn data.pin (decoded)",
+		"Secret hub-secrets/conn data.uri (decoded)",
+		"Secret hub-secrets/creds data.password (decoded)",
+	}
+	if !reflect.DeepEqual(sources, want) {
+		t.Errorf("leaks = %v, want %v", leaks, want)
+	}
+}
@@ -0,0 +1,46 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// CheckRemediation reports every Policy in the resolved multiDocYAML that
+// would enforce in a dryRun deployment: one whose spec.remediationAction is
+// not inform, or with a policy template whose spec.remediationAction is
+// neither inform nor informOnly. A template without one informs, the
+// ConfigurationPolicy default.
+func CheckRemediation(multiDocYAML string) []string {
+	informs := func(action string) bool {
+		return strings.EqualFold(action, "inform") || strings.EqualFold(action, "informOnly")
+	}
+	var errs []string
+	for i, doc := range splitYAMLDocuments(multiDocYAML) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil || obj["kind"] != "Policy" {
+			continue
+		}
+		id := docIdentity(doc, i)
+		if action, _ := nestedString(obj, "spec", "remediationAction"); !strings.EqualFold(action, "inform") {
+			errs = append(errs, fmt.Sprintf("%s: spec.remediationAction is %q, want inform in a dryRun deployment", id, action))
+		}
+		spec, _ := obj["spec"].(map[string]interface{})
+		policyTemplates, _ := spec["policy-templates"].([]interface{})
+		for _, pt := range policyTemplates {
+			pt, _ := pt.(map[string]interface{})
+			def, _ := pt["objectDefinition"].(map[string]interface{})
+			if def == nil {
+				continue
+			}
+			action, ok := nestedString(def, "spec", "remediationAction")
+			if ok && !informs(action) {
+				kind, _ := def["kind"].(string)
+				name, _ := nestedString(def, "metadata", "name")
+				errs = append(errs, fmt.Sprintf("%s: policy template %s/%s: spec.remediationAction is %q, want inform in a dryRun deployment", id, kind, name, action))
+			}
+		}
+	}
+	return errs
+}
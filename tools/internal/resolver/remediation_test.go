@@ -0,0 +1,84 @@
+package resolver
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCheckRemediation(t *testing.T) {
+	policy := func(name, policyAction, templateAction string) string {
+		doc := `apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+metadata:
+  name: ` + name + `
+spec:
+`
+		if policyAction != "" {
+			doc += "  remediationAction: " + policyAction + "\n"
+		}
+		doc += `  policy-templates:
+  - objectDefinition:
+      apiVersion: policy.open-cluster-management.io/v1
+      kind: ConfigurationPolicy
+      metadata:
+        name: ` + name + `-config
+      spec:
+`
+		if templateAction != "" {
+			doc += "        remediationAction: " + templateAction + "\n"
+		}
+		return doc + "        severity: low\n"
+	}
+	got := CheckRemediation(joinYAMLDocuments([]string{
+		policy("policy-ok", "inform", "inform"),
+		policy("policy-default", "inform", ""),
+		policy("policy-enforce", "enforce", "inform"),
+		policy("policy-unset", "", "informOnly"),
+		policy("policy-template", "inform", "enforce"),
+	}))
+	want := []string{
+		`Policy/policy-enforce (document 3): spec.remediationAction is "enforce", want inform in a dryRun deployment`,
+		`Policy/policy-unset (document 4): spec.remediationAction is "", want inform in a dryRun deployment`,
+		`Policy/policy-template (document 5): policy template ConfigurationPolicy/policy-template-config: spec.remediationAction is "enforce", want inform in a dryRun deployment`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDeploymentValues(t *testing.T) {
+	dep := DeploymentValues{Release: "ops", DryRun: true, CompliantInterval: "10m", ClusterSetSuffix: "-v2"}.Deployment()
+	if dep.Namespace() != "policies-ops" || !dep.DryRun() {
+		t.Errorf("namespace %q, dryRun %v", dep.Namespace(), dep.DryRun())
+	}
+	for k, want := range map[string]string{"REMEDIATION": "inform", "EVAL_COMPLIANT": "10m", "EVAL_NONCOMPLIANT": "watch", "CLUSTER_SET_SUFFIX": "-v2"} {
+		if dep.Placeholders[k] != want {
+			t.Errorf("%s = %q, want %q", k, dep.Placeholders[k], want)
+		}
+	}
+	if DefaultDeployment().DryRun() {
+		t.Error("DefaultDeployment should enforce")
+	}
+	defaults, err := WriteTestValuesFor(t.TempDir(), "lint-cluster", nil, DefaultDeployment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(defaults); err != nil || !strings.Contains(string(data), "compliant: 10m") || !strings.Contains(string(data), "noncompliant: 30s") {
+		t.Errorf("default test values want intervals 10m/30s (%v):\n%s", err, data)
+	}
+
+	path, err := WriteTestValuesFor(t.TempDir(), "lint-cluster", nil, dep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"policy_namespace: policies-ops", "dryRun: true", "compliant: 10m", "clusterSetSuffix: -v2", "gitopsNamespace: openshift-gitops"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("test values lack %q:\n%s", want, data)
+		}
+	}
+}
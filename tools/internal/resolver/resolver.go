@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/stolostron/go-template-utils/v7/pkg/templates"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -21,6 +22,14 @@ import (
 // Resolver wraps the ACM TemplateResolver for offline hub template resolution.
 type Resolver struct {
 	inner *templates.TemplateResolver
+
+	// local holds the objects lookups can return; ResolveSpokePolicies
+	// expands namespace and object selectors against them.
+	local []unstructured.Unstructured
+
+	mu     sync.Mutex
+	misses []Lookup // lookups that came back empty, see missReactor
+	missed map[string]bool
 }
 
 // kindToResource converts a Kind to its lowercase plural resource name.
@@ -158,7 +167,9 @@ func NewResolver(localResources []unstructured.Unstructured) (*Resolver, error)
 	if len(localResources) > 0 {
 		tr.WithLocalResources(localResources)
 	}
-	return &Resolver{inner: tr}, nil
+	r := &Resolver{inner: tr, local: localResources}
+	dynClient.PrependReactor("*", "*", r.missReactor(dynClient.Tracker(), listKinds))
+	return r, nil
 }
 
 // SetLocalResources updates the resolver's local resources. These are
@@ -166,6 +177,7 @@ func NewResolver(localResources []unstructured.Unstructured) (*Resolver, error)
 // `lookup`/`fromConfigMap` calls instead of hitting a real API server.
 func (r *Resolver) SetLocalResources(resources []unstructured.Unstructured) {
 	r.inner.WithLocalResources(resources)
+	r.local = resources
 }
 
 // HubContext is the template context struct passed to the ACM resolver. The
@@ -248,15 +260,24 @@ func (r *Resolver) ResolvePolicy(rawYAML string, ctx HubContext) ResolvePolicyRe
 			"labels":      mapOrEmpty(metadata, "labels"),
 		}
 
-		result, err := r.inner.ResolveTemplate(jsonBytes, docCtx, &templates.ResolveOptions{})
+		// Encrypt what protect, fromSecret and copySecretData return with the
+		// cluster's test key, as the propagator does with the real one.
+		enc := hubEncryption(ctx.ManagedClusterName, policyNamespace, policyName)
+		result, err := r.inner.ResolveTemplate(jsonBytes, docCtx, &templates.ResolveOptions{EncryptionConfig: enc})
 		if err != nil {
 			errs = append(errs, fmt.Sprintf("%s: %v", policyName, err))
 			resolved = append(resolved, doc) // pass through the original
 			continue
 		}
+		resolvedJSON, err := annotateEncryptionIV(result.ResolvedJSON, enc.InitializationVector)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: annotate encryption IV: %v", policyName, err))
+			resolved = append(resolved, doc)
+			continue
+		}
 
 		// Convert resolved JSON back to YAML.
-		resolvedYAML, err := sigsyaml.JSONToYAML(result.ResolvedJSON)
+		resolvedYAML, err := sigsyaml.JSONToYAML(resolvedJSON)
 		if err != nil {
 			errs = append(errs, fmt.Sprintf("%s: convert to YAML: %v", policyName, err))
 			resolved = append(resolved, doc)
@@ -313,7 +334,9 @@ func NewSpokeResolver(localResources []unstructured.Unstructured) (*Resolver, er
 	if len(localResources) > 0 {
 		tr.WithLocalResources(localResources)
 	}
-	return &Resolver{inner: tr}, nil
+	r := &Resolver{inner: tr, local: localResources}
+	dynClient.PrependReactor("*", "*", r.missReactor(dynClient.Tracker(), listKinds))
+	return r, nil
 }
 
 // ResolveSpokeTemplates resolves spoke-side {{ }} template expressions in
@@ -327,6 +350,10 @@ func NewSpokeResolver(localResources []unstructured.Unstructured) (*Resolver, er
 // templates lookup real cluster resources (Infrastructure, DNS) that can only
 // be partially faked. A failure here does NOT indicate a broken policy; it
 // just means CI couldn't fully exercise that code path.
+//
+// Every document gets ctx as its template context, which the managed cluster
+// never provides; ResolveSpokePolicies resolves with the contexts the
+// config-policy-controller uses.
 func (r *Resolver) ResolveSpokeTemplates(rawYAML string, ctx ...HubContext) ResolvePolicyResult {
 	docs := splitYAMLDocuments(rawYAML)
 	var resolved []string
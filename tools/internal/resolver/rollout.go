@@ -0,0 +1,160 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/valueslint"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// DefaultRolloutTags are the versions docs/gradual-rollout.md deploys side by
+// side.
+var DefaultRolloutTags = []string{"0.0.1", "0.0.2"}
+
+// RolloutTier is one release of a gradual rollout (docs/gradual-rollout.md):
+// the hub.yaml and managed.yaml profiles with versionedClusterSets at tag,
+// deployed as release as-<sanitized tag>. The tag is set as
+// autoshiftGitBranchTag; OCI mode derives the same suffix from
+// autoshiftOciVersion, so git mode stands in for both.
+func RolloutTier(tag string) Tier {
+	release := "as" + valueslint.ClusterSetSuffix(tag)
+	return Tier{
+		Name:        release,
+		Release:     release,
+		Cluster:     "hub",
+		ValuesFiles: []string{"global.yaml", "clustersets/hub.yaml", "clustersets/managed.yaml"},
+		Values: map[string]interface{}{
+			"versionedClusterSets":  true,
+			"autoshiftGitBranchTag": tag,
+		},
+	}
+}
+
+// CheckRollout reports what would make releases deployed side by side on one
+// hub interfere:
+//
+//   - two releases declaring, or binding, the same clusterset;
+//   - a release binding a clusterset into a namespace other than its own, or
+//     binding a clusterset it does not declare;
+//   - a policy landing on a cluster in another release's clusterset — with
+//     ExclusiveClusterSetLabel a cluster is in exactly one clusterset, so this
+//     is the only way two releases can both select it;
+//   - two releases sharing a policy namespace, or rendering an object with the
+//     same identity, which their Applications would fight over.
+func CheckRollout(runs []TierRun) []string {
+	var out []string
+	seenMsg := map[string]bool{}
+	report := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		if !seenMsg[msg] {
+			seenMsg[msg] = true
+			out = append(out, msg)
+		}
+	}
+
+	owner := map[string]string{}
+	namespaces := map[string]string{}
+	var fleet []FleetCluster
+	for _, run := range runs {
+		rel := run.Tier.Release
+		for _, set := range run.ClusterSets {
+			if prev, ok := owner[set]; ok {
+				report("clusterset %s is declared by both %s and %s", set, prev, rel)
+				continue
+			}
+			owner[set] = rel
+		}
+		ns := run.Deployment.Namespace()
+		if prev, ok := namespaces[ns]; ok {
+			report("policy namespace %s is used by both %s and %s", ns, prev, rel)
+		}
+		namespaces[ns] = rel
+		fleet = append(fleet, run.Clusters()...)
+	}
+
+	bound := map[string]string{}
+	for _, run := range runs {
+		rel, ns := run.Tier.Release, run.Deployment.Namespace()
+		bindingNamespaces := make([]string, 0, len(run.Placements.ClusterSets))
+		for bns := range run.Placements.ClusterSets {
+			bindingNamespaces = append(bindingNamespaces, bns)
+		}
+		sort.Strings(bindingNamespaces)
+		for _, bns := range bindingNamespaces {
+			for _, set := range run.Placements.ClusterSets[bns] {
+				switch {
+				case bns != ns:
+					report("%s binds clusterset %s into namespace %s, not its own %s", rel, set, bns, ns)
+				case owner[set] == "":
+					report("%s binds clusterset %s, which no release declares", rel, set)
+				case owner[set] != rel:
+					report("%s binds clusterset %s, which %s owns", rel, set, owner[set])
+				}
+				if prev, ok := bound[set]; ok && prev != rel {
+					report("clusterset %s is bound by both %s and %s", set, prev, rel)
+				}
+				bound[set] = rel
+			}
+		}
+
+		for _, d := range run.Placements.Decide(fleet) {
+			if o := owner[d.Cluster.ClusterSet]; o != rel {
+				report("%s: policy %s (placement %s) lands on %s in clusterset %s, which %s owns",
+					rel, d.Policy, d.Placement, d.Cluster.Name, d.Cluster.ClusterSet, o)
+			}
+		}
+	}
+
+	rendered := map[string]string{}
+	for _, run := range runs {
+		rel := run.Tier.Release
+		for _, id := range objectIDs(run.Rendered + "\n---\n" + run.Resolved) {
+			if prev, ok := rendered[id]; ok && prev != rel {
+				report("%s is rendered by both %s and %s", id, prev, rel)
+				continue
+			}
+			rendered[id] = rel
+		}
+	}
+	return out
+}
+
+// objectIDs returns the group/Kind/namespace/name of every top-level object in
+// multiDocYAML, sorted and deduplicated. Objects inside a Policy are the
+// spoke's, not the hub's, and are not listed.
+func objectIDs(multiDocYAML string) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, doc := range splitYAMLDocuments(multiDocYAML) {
+		var obj struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if strings.TrimSpace(doc) == "" || sigsyaml.Unmarshal([]byte(doc), &obj) != nil || obj.Kind == "" || obj.Metadata.Name == "" {
+			continue
+		}
+		group := ""
+		if i := strings.LastIndex(obj.APIVersion, "/"); i >= 0 {
+			group = obj.APIVersion[:i]
+		}
+		id := obj.Kind + " " + obj.Metadata.Name
+		if group != "" {
+			id = obj.Kind + "." + group + " " + obj.Metadata.Name
+		}
+		if obj.Metadata.Namespace != "" {
+			id += " in " + obj.Metadata.Namespace
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,112 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRolloutTier(t *testing.T) {
+	tier := RolloutTier("0.0.2")
+	if tier.Release != "as-0-0-2" {
+		t.Errorf("release = %q, want as-0-0-2", tier.Release)
+	}
+	if tier.Values["autoshiftGitBranchTag"] != "0.0.2" || tier.Values["versionedClusterSets"] != true {
+		t.Errorf("values = %v", tier.Values)
+	}
+}
+
+// rolloutRun builds a release whose values declare hub and managed with
+// suffix, and whose resolved output is foundation (the ManagedClusterSetBindings)
+// plus one policy placed by placementSpec.
+func rolloutRun(release, suffix, foundation, placementSpec string) TierRun {
+	tier := Tier{Name: release, Release: release, Cluster: "hub"}
+	values := map[string]interface{}{
+		"clusterSetSuffix": suffix,
+		"hubClusterSets": map[string]interface{}{
+			"hub" + suffix: map[string]interface{}{"labels": map[string]interface{}{"self-managed": "true"}},
+		},
+		"managedClusterSets": map[string]interface{}{
+			"managed" + suffix: map[string]interface{}{"labels": map[string]interface{}{}},
+		},
+	}
+	ns := "policies-" + release
+	tr := &TierRender{
+		Tier:        tier,
+		Deployment:  Deployment{Placeholders: map[string]string{"POLICY_NAMESPACE": ns}},
+		Fleet:       tierFleet(tier, values),
+		ClusterSets: []string{"hub" + suffix, "managed" + suffix},
+		Rendered: fmt.Sprintf(`apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: %s-policies
+  namespace: openshift-gitops
+`, release),
+	}
+	resolved := foundation + fmt.Sprintf(`
+---
+apiVersion: cluster.open-cluster-management.io/v1beta1
+kind: Placement
+metadata:
+  name: placement-odf
+  namespace: %[1]s
+spec:
+%[2]s
+---
+apiVersion: policy.open-cluster-management.io/v1
+kind: PlacementBinding
+metadata:
+  name: binding-odf
+  namespace: %[1]s
+placementRef:
+  name: placement-odf
+  kind: Placement
+subjects:
+  - name: policy-odf
+    kind: Policy
+`, ns, placementSpec)
+	return NewTierRun(tr, resolved)
+}
+
+func bindings(ns string, sets ...string) string {
+	var b strings.Builder
+	for _, set := range sets {
+		fmt.Fprintf(&b, `
+---
+apiVersion: cluster.open-cluster-management.io/v1beta2
+kind: ManagedClusterSetBinding
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+spec:
+  clusterSet: %[1]s
+`, set, ns)
+	}
+	return b.String()
+}
+
+func TestCheckRollout(t *testing.T) {
+	clean := []TierRun{
+		rolloutRun("as-0-0-1", "-0-0-1", bindings("policies-as-0-0-1", "hub-0-0-1", "managed-0-0-1"), "  predicates: []"),
+		rolloutRun("as-0-0-2", "-0-0-2", bindings("policies-as-0-0-2", "hub-0-0-2", "managed-0-0-2"), "  predicates: []"),
+	}
+	if got := CheckRollout(clean); len(got) != 0 {
+		t.Errorf("side-by-side releases: %v", got)
+	}
+
+	broken := []TierRun{
+		rolloutRun("as-0-0-1", "-0-0-1", bindings("policies-as-0-0-1", "hub-0-0-1", "managed-0-0-1"), "  predicates: []"),
+		// 0.0.2 also binds 0.0.1's managed clusterset, and its placement names it.
+		rolloutRun("as-0-0-2", "-0-0-2", bindings("policies-as-0-0-2", "hub-0-0-2", "managed-0-0-1"), "  clusterSets: [managed-0-0-1]"),
+	}
+	broken[1].Rendered = broken[0].Rendered
+	want := []string{
+		"as-0-0-2 binds clusterset managed-0-0-1, which as-0-0-1 owns",
+		"clusterset managed-0-0-1 is bound by both as-0-0-1 and as-0-0-2",
+		"as-0-0-2: policy policy-odf (placement placement-odf) lands on managed-0-0-1-spoke in clusterset managed-0-0-1, which as-0-0-1 owns",
+		"ApplicationSet.argoproj.io as-0-0-1-policies in openshift-gitops is rendered by both as-0-0-1 and as-0-0-2",
+	}
+	if got := CheckRollout(broken); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CheckRollout:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
@@ -0,0 +1,357 @@
+package resolver
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// How a Secret value reaches the object a policy creates.
+const (
+	FlowVerbatim  = "verbatim"  // the field is the value, as stored or decoded
+	FlowTemplated = "templated" // the value is part of a longer string
+	FlowProtected = "protected" // the hub encrypted it (protect, fromSecret, copySecretData)
+	FlowReadOnly  = "read only" // a template read the Secret, but no value landed anywhere
+)
+
+// SecretFlow is one Secret value a policy template read, and where it went.
+type SecretFlow struct {
+	Policy  string // chart, e.g. stable/cluster-install; set by RunDeployment
+	Profile string // "primary" or the NamedContext name; set by RunDeployment
+	Side    string // "hub" for a {{hub ... hub}} template, "spoke" for {{ ... }}
+	Secret  string // namespace/name
+	Key     string // data or stringData key; empty for FlowReadOnly
+	Dest    string // "<Policy>/<Kind> <name>/<Kind> [ns/]name" of the object it lands in
+	Field   string // dotted path of the field in Dest
+	Mode    string // one of the Flow* constants
+}
+
+func (f SecretFlow) String() string {
+	if f.Mode == FlowReadOnly {
+		return fmt.Sprintf("%s [%s] %s: Secret %s: %s", f.Policy, f.Profile, f.Side, f.Secret, f.Mode)
+	}
+	return fmt.Sprintf("%s [%s] %s: Secret %s %s -> %s %s (%s)", f.Policy, f.Profile, f.Side, f.Secret, f.Key, f.Dest, f.Field, f.Mode)
+}
+
+// PrimaryProfile is SecretFlow.Profile for RunDeployment's primary context.
+const PrimaryProfile = "primary"
+
+var readsSecretRe = regexp.MustCompile(`fromSecret|copySecretData|protect|"Secret"`)
+
+// readsSecrets reports whether the templates in rawYAML may read a Secret,
+// which is when a tracing RunDeployment traces them.
+func readsSecrets(rawYAML string) bool {
+	return readsSecretRe.MatchString(rawYAML)
+}
+
+const markerPrefix = "secretflow["
+
+// encodedMarkerPrefix starts every base64-encoded marker: the encoding of the
+// first 9 bytes of markerPrefix, which no later byte changes.
+var encodedMarkerPrefix = base64.StdEncoding.EncodeToString([]byte(markerPrefix))[:12]
+
+// secretRef is the Secret and key a marker stands for.
+type secretRef struct{ secret, key string }
+
+// markSecrets returns resources with every Secret value replaced by a marker
+// naming it — base64-encoded in data — and the markers, both forms, by the
+// value they stand for. The EncryptionKeySecret is left alone, so the spoke
+// can still decrypt.
+func markSecrets(resources []unstructured.Unstructured) ([]unstructured.Unstructured, map[string]secretRef) {
+	markers := map[string]secretRef{}
+	out := make([]unstructured.Unstructured, 0, len(resources))
+	for _, u := range resources {
+		if u.GetAPIVersion() != "v1" || u.GetKind() != "Secret" || u.GetName() == EncryptionKeySecret {
+			out = append(out, u)
+			continue
+		}
+		u = *u.DeepCopy()
+		id := u.GetNamespace() + "/" + u.GetName()
+		for _, field := range []string{"data", "stringData"} {
+			values, _ := u.Object[field].(map[string]interface{})
+			for key := range values {
+				marker := markerPrefix + id + "/" + key + "]"
+				encoded := base64.StdEncoding.EncodeToString([]byte(marker))
+				markers[marker] = secretRef{id, key}
+				markers[encoded] = secretRef{id, key}
+				if field == "data" {
+					values[key] = encoded
+				} else {
+					values[key] = marker
+				}
+			}
+		}
+		out = append(out, u)
+	}
+	return out, markers
+}
+
+// TraceSecretFlows resolves rawYAML for c again, with every Secret value in
+// hubResources and spokeResources replaced by a marker, and follows the
+// markers into the objects the policies create: a marker in the hub output
+// was read by a hub template, one that only turns up after the spoke pass by
+// a spoke template. A marker inside object-templates-raw the hub output
+// still holds spoke templates in lands in the ConfigurationPolicy itself. A
+// Secret a template read without any value landing in an object is reported
+// as FlowReadOnly. Since Secret values are markers here, a template that
+// branches on a value may take another branch than in the real run.
+func TraceSecretFlows(rawYAML string, c HubContext, hubResources, spokeResources []unstructured.Unstructured) ([]SecretFlow, error) {
+	markedHub, markers := markSecrets(hubResources)
+	markedSpoke, spokeMarkers := markSecrets(spokeResources)
+	for k, v := range spokeMarkers {
+		markers[k] = v
+	}
+	t := flowTracer{markers: markers, key: TestEncryptionKey(c.ManagedClusterName)}
+
+	hub, err := NewResolver(markedHub)
+	if err != nil {
+		return nil, fmt.Errorf("hub resolver: %w", err)
+	}
+	hubOut := hub.ResolvePolicy(rawYAML, c).Resolved
+	flows := t.collect(hubOut, "hub")
+	flows = append(flows, readOnlyFlows(hub.UsedResources(), flows, "hub")...)
+
+	spoke, err := NewSpokeResolver(markedSpoke)
+	if err != nil {
+		return nil, fmt.Errorf("spoke resolver: %w", err)
+	}
+	spokeOut := spoke.ResolveSpokePolicies(stripStringDefaults(hubOut)).Resolved
+	// A value the hub already placed is passed on, not read again.
+	seen := map[string]bool{}
+	for _, f := range flows {
+		seen[f.Secret+" "+f.Key] = true
+	}
+	var spokeFlows []SecretFlow
+	for _, f := range t.collect(spokeOut, "spoke") {
+		if !seen[f.Secret+" "+f.Key] {
+			spokeFlows = append(spokeFlows, f)
+		}
+	}
+	spokeFlows = append(spokeFlows, readOnlyFlows(spoke.UsedResources(), spokeFlows, "spoke")...)
+	return append(flows, spokeFlows...), nil
+}
+
+// readOnlyFlows returns a FlowReadOnly flow for every Secret in used that no
+// flow names.
+func readOnlyFlows(used []unstructured.Unstructured, flows []SecretFlow, side string) []SecretFlow {
+	landed := map[string]bool{}
+	for _, f := range flows {
+		landed[f.Secret] = true
+	}
+	read := map[string]bool{}
+	for _, u := range used {
+		if u.GetAPIVersion() == "v1" && u.GetKind() == "Secret" && u.GetName() != EncryptionKeySecret {
+			read[u.GetNamespace()+"/"+u.GetName()] = true
+		}
+	}
+	var out []SecretFlow
+	for _, id := range sortedSet(read) {
+		if !landed[id] {
+			out = append(out, SecretFlow{Side: side, Secret: id, Mode: FlowReadOnly})
+		}
+	}
+	return out
+}
+
+// flowTracer finds markers in resolved policies.
+type flowTracer struct {
+	markers map[string]secretRef
+	key     []byte // the cluster's encryption key, to look inside encrypted values
+}
+
+// collect returns the flows of the markers in the Policies of resolved.
+func (t flowTracer) collect(resolved, side string) []SecretFlow {
+	var flows []SecretFlow
+	for _, doc := range splitYAMLDocuments(resolved) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil || obj["kind"] != "Policy" {
+			continue
+		}
+		policyName, _ := nestedString(obj, "metadata", "name")
+		spec, _ := obj["spec"].(map[string]interface{})
+		policyTemplates, _ := spec["policy-templates"].([]interface{})
+		for _, pt := range policyTemplates {
+			pt, _ := pt.(map[string]interface{})
+			def, _ := pt["objectDefinition"].(map[string]interface{})
+			if def == nil {
+				continue
+			}
+			defU := unstructured.Unstructured{Object: def}
+			prefix := policyName + "/" + defU.GetKind() + " " + defU.GetName()
+			var iv []byte
+			if s := defU.GetAnnotations()[EncryptionIVAnnotation]; s != "" {
+				iv, _ = base64.StdEncoding.DecodeString(s)
+			}
+			emit := func(dest string, v interface{}) {
+				walkStrings(v, "", func(path, s string) {
+					for _, m := range t.find(s, iv) {
+						flows = append(flows, SecretFlow{Side: side, Secret: m.secret, Key: m.key, Dest: dest, Field: path, Mode: m.mode})
+					}
+				})
+			}
+			if defU.GetKind() != "ConfigurationPolicy" {
+				emit(prefix, def)
+				continue
+			}
+			cpSpec, _ := def["spec"].(map[string]interface{})
+			entries, _ := cpSpec["object-templates"].([]interface{})
+			if raw, ok := cpSpec["object-templates-raw"].(string); ok {
+				var rawEntries []interface{}
+				if err := sigsyaml.Unmarshal([]byte(raw), &rawEntries); err == nil {
+					entries = append(entries, rawEntries...)
+				} else {
+					emit(prefix, raw) // still holds spoke templates
+				}
+			}
+			for _, e := range entries {
+				e, _ := e.(map[string]interface{})
+				od, _ := e["objectDefinition"].(map[string]interface{})
+				if od == nil {
+					continue
+				}
+				odU := unstructured.Unstructured{Object: od}
+				id := odU.GetName()
+				if ns := odU.GetNamespace(); ns != "" {
+					id = ns + "/" + id
+				}
+				emit(prefix+"/"+odU.GetKind()+" "+id, od)
+			}
+		}
+	}
+	return flows
+}
+
+type markerMatch struct {
+	secretRef
+	mode string
+}
+
+var encryptedValueRe = regexp.MustCompile(regexp.QuoteMeta(encryptedPrefix) + `([a-zA-Z0-9+/=]+)`)
+
+// find returns the markers in s: verbatim when s is one, templated when s
+// holds one among other text, protected when it is inside an encrypted value.
+func (t flowTracer) find(s string, iv []byte) []markerMatch {
+	var out []markerMatch
+	for _, m := range encryptedValueRe.FindAllStringSubmatch(s, -1) {
+		plain, err := decryptValue(t.key, iv, m[1])
+		if err != nil {
+			continue
+		}
+		for _, found := range t.plainMarkers(plain) {
+			out = append(out, markerMatch{found.secretRef, FlowProtected})
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	return t.plainMarkers(s)
+}
+
+func (t flowTracer) plainMarkers(s string) []markerMatch {
+	if ref, ok := t.markers[s]; ok {
+		return []markerMatch{{ref, FlowVerbatim}}
+	}
+	if !strings.Contains(s, markerPrefix) && !strings.Contains(s, encodedMarkerPrefix) {
+		return nil
+	}
+	var out []markerMatch
+	for _, m := range sortedMarkerKeys(t.markers) {
+		if strings.Contains(s, m) {
+			out = append(out, markerMatch{t.markers[m], FlowTemplated})
+		}
+	}
+	return out
+}
+
+func sortedMarkerKeys(m map[string]secretRef) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decryptValue reverses protect: AES-CBC with PKCS#7 padding, base64 encoded.
+func decryptValue(key, iv []byte, encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return "", fmt.Errorf("not an encrypted value")
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > len(plain) {
+		return "", fmt.Errorf("bad padding")
+	}
+	return string(plain[:len(plain)-pad]), nil
+}
+
+// walkStrings calls fn with every string below v and its dotted path.
+func walkStrings(v interface{}, path string, fn func(path, s string)) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			walkStrings(v[k], p, fn)
+		}
+	case []interface{}:
+		for i, child := range v {
+			walkStrings(child, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case string:
+		fn(path, v)
+	}
+}
+
+// SortSecretFlows orders flows by policy, profile, side, Secret, key and
+// destination, and drops duplicates.
+func SortSecretFlows(flows []SecretFlow) []SecretFlow {
+	sort.SliceStable(flows, func(i, j int) bool {
+		a, b := flows[i], flows[j]
+		for _, p := range [][2]string{{a.Policy, b.Policy}, {a.Profile, b.Profile}, {a.Side, b.Side}, {a.Secret, b.Secret}, {a.Key, b.Key}, {a.Dest, b.Dest}, {a.Field, b.Field}} {
+			if p[0] != p[1] {
+				return p[0] < p[1]
+			}
+		}
+		return false
+	})
+	var out []SecretFlow
+	for i, f := range flows {
+		if i > 0 && f == flows[i-1] {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// AllSecretFlows returns the secret flows of every chart and profile in
+// results, sorted.
+func AllSecretFlows(results []ChartResult) []SecretFlow {
+	var flows []SecretFlow
+	for _, res := range results {
+		flows = append(flows, res.SecretFlows...)
+		for _, cr := range res.ExtraResults {
+			flows = append(flows, cr.SecretFlows...)
+		}
+	}
+	return SortSecretFlows(flows)
+}
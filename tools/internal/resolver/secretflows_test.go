@@ -0,0 +1,78 @@
+package resolver
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestTraceSecretFlows(t *testing.T) {
+	secret := func(ns, name string, data map[string]string) unstructured.Unstructured {
+		d := map[string]interface{}{}
+		for k, v := range data {
+			d[k] = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1", "kind": "Secret",
+			"metadata": map[string]interface{}{"name": name, "namespace": ns},
+			"data":     d,
+		}}
+	}
+	hubRes := []unstructured.Unstructured{
+		secret("hub-secrets", "creds", map[string]string{"password": "p", "user": "u"}),
+		secret("hub-secrets", "gate", map[string]string{"enabled": "true"}),
+	}
+	spokeRes := append([]unstructured.Unstructured{
+		secret("openshift-config", "pull-secret", map[string]string{".dockerconfigjson": "{}"}),
+		EncryptionKeyObject("c1"),
+	}, hubRes...)
+
+	raw := configurationPolicy(`object-templates:
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: Secret
+    metadata:
+      name: copied
+      namespace: app
+    data:
+      password: '{{hub fromSecret "hub-secrets" "creds" "password" hub}}'
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: settings
+      namespace: app
+    data:
+      url: '{{hub printf "db://%s@db" ((lookup "v1" "Secret" "hub-secrets" "creds").data.user | base64dec) hub}}'
+      gate: '{{hub if (lookup "v1" "Secret" "hub-secrets" "gate").data hub}}on{{hub end hub}}'
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: Secret
+    metadata:
+      name: pull
+      namespace: app
+    data: '{{ copySecretData "openshift-config" "pull-secret" | toLiteral }}'
+`)
+	flows, err := TraceSecretFlows(raw, HubContext{ManagedClusterName: "c1"}, hubRes, spokeRes)
+	if err != nil {
+		t.Fatalf("TraceSecretFlows: %v", err)
+	}
+	var got []string
+	for _, f := range SortSecretFlows(flows) {
+		got = append(got, f.String())
+	}
+	want := []string{
+		" [] hub: Secret hub-secrets/creds password -> policy-test/ConfigurationPolicy test/Secret app/copied data.password (protected)",
+		" [] hub: Secret hub-secrets/creds user -> policy-test/ConfigurationPolicy test/ConfigMap app/settings data.url (templated)",
+		" [] hub: Secret hub-secrets/gate: read only",
+		" [] spoke: Secret openshift-config/pull-secret .dockerconfigjson -> policy-test/ConfigurationPolicy test/Secret app/pull data..dockerconfigjson (verbatim)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("flows:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
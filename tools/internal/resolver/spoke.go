@@ -0,0 +1,415 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/stolostron/go-template-utils/v7/pkg/templates"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// objectContext is the template context the config-policy-controller gives an
+// entry of a ConfigurationPolicy's object-templates: the namespace and name of
+// the object it is evaluated for, and that object when it exists.
+// object-templates-raw and every other spoke template get no context at all.
+type objectContext struct {
+	ObjectNamespace string
+	ObjectName      string
+	Object          map[string]interface{}
+}
+
+// namespaceSelector is a ConfigurationPolicy's spec.namespaceSelector.
+type namespaceSelector struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+	metav1.LabelSelector
+}
+
+// ResolveSpokePolicies resolves spoke {{ }} templates the way the
+// config-policy-controller on a managed cluster does. Unlike
+// ResolveSpokeTemplates, no document sees a HubContext:
+//
+//   - object-templates-raw, and everything outside a ConfigurationPolicy's
+//     object-templates, resolves with no context, so a spoke template reading
+//     .ManagedClusterLabels, .ManagedClusterName or .PolicyMetadata fails
+//     here as it would on the cluster.
+//   - each entry of object-templates resolves once per object it applies to,
+//     with an objectContext. Without a namespace in its objectDefinition, the
+//     ConfigurationPolicy's namespaceSelector picks the namespaces from the
+//     resolver's Namespaces; without a name, the entry's objectSelector picks
+//     the objects from the resolver's objects of that kind. Each evaluation
+//     becomes its own entry in the resolved output.
+//
+// A selector that matches nothing is reported as an error: the entry is never
+// evaluated, so a Namespace or object is missing from testdata.
+//
+// A policy template the hub annotated with EncryptionIVAnnotation has its
+// encrypted values decrypted with the key in the resolver's
+// EncryptionKeySecret (see SpokeObjects).
+func (r *Resolver) ResolveSpokePolicies(rawYAML string) ResolvePolicyResult {
+	var resolved []string
+	var errs []string
+
+	for _, doc := range splitYAMLDocuments(rawYAML) {
+		doc = strings.TrimSpace(doc)
+		if doc == "" {
+			continue
+		}
+		if !strings.Contains(doc, "{{") && !strings.Contains(doc, encryptedPrefix) {
+			resolved = append(resolved, doc)
+			continue
+		}
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil {
+			resolved = append(resolved, doc)
+			continue
+		}
+		kind, _ := obj["kind"].(string)
+		name, _ := nestedString(obj, "metadata", "name")
+
+		var docErrs []string
+		if kind == "Policy" {
+			spec, _ := obj["spec"].(map[string]interface{})
+			policyTemplates, _ := spec["policy-templates"].([]interface{})
+			for _, pt := range policyTemplates {
+				pt, _ := pt.(map[string]interface{})
+				def, _ := pt["objectDefinition"].(map[string]interface{})
+				if def == nil {
+					continue
+				}
+				defKind, _ := def["kind"].(string)
+				defName, _ := nestedString(def, "metadata", "name")
+				var defErrs []string
+				opts, err := r.decryptOptions(def)
+				if err != nil {
+					defErrs = []string{err.Error()}
+				} else if defKind == "ConfigurationPolicy" {
+					defErrs = r.resolveConfigurationPolicy(def, opts)
+				} else if v, err := r.resolveValue(def, nil, opts); err != nil {
+					defErrs = []string{explainSpokeError(err)}
+				} else {
+					pt["objectDefinition"] = v
+				}
+				for _, e := range defErrs {
+					docErrs = append(docErrs, fmt.Sprintf("%s %s: %s %s: spoke resolve: %s", kind, name, defKind, defName, e))
+				}
+			}
+		} else if v, err := r.resolveValue(obj, nil, &templates.ResolveOptions{}); err != nil {
+			docErrs = []string{fmt.Sprintf("%s %s: spoke resolve: %s", kind, name, explainSpokeError(err))}
+		} else {
+			obj = v.(map[string]interface{})
+		}
+		errs = append(errs, docErrs...)
+
+		out, err := sigsyaml.Marshal(obj)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s %s: marshal: %v", kind, name, err))
+			resolved = append(resolved, doc)
+			continue
+		}
+		resolved = append(resolved, string(out))
+	}
+
+	return ResolvePolicyResult{
+		Resolved: joinYAMLDocuments(resolved),
+		Errors:   errs,
+	}
+}
+
+// resolveConfigurationPolicy resolves cp in place: its spec apart from
+// object-templates with no context, then each object template once per object
+// it applies to. An entry that fails keeps its unresolved form.
+func (r *Resolver) resolveConfigurationPolicy(cp map[string]interface{}, opts *templates.ResolveOptions) []string {
+	spec, _ := cp["spec"].(map[string]interface{})
+	if spec == nil {
+		return nil
+	}
+	objectTemplates, _ := spec["object-templates"].([]interface{})
+	delete(spec, "object-templates")
+
+	var errs []string
+	v, err := r.resolveValue(spec, nil, opts)
+	if err != nil {
+		errs = append(errs, explainSpokeError(err))
+	} else {
+		spec = v.(map[string]interface{})
+		cp["spec"] = spec
+	}
+	if objectTemplates == nil {
+		return errs
+	}
+
+	var nsSel *namespaceSelector
+	if raw, ok := spec["namespaceSelector"]; ok {
+		nsSel = &namespaceSelector{}
+		if data, err := json.Marshal(raw); err != nil || json.Unmarshal(data, nsSel) != nil {
+			errs = append(errs, "namespaceSelector is not a valid selector")
+			nsSel = nil
+		}
+	}
+
+	var expanded []interface{}
+	for i, ot := range objectTemplates {
+		ot, _ := ot.(map[string]interface{})
+		contexts, err := r.objectContexts(ot, nsSel)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("object-templates[%d]: %v", i, err))
+			expanded = append(expanded, ot)
+			continue
+		}
+		for _, ctx := range contexts {
+			v, err := r.resolveValue(ot, ctx, opts)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("object-templates[%d] (%s): %s", i, ctx, explainSpokeError(err)))
+				expanded = append(expanded, ot)
+				continue
+			}
+			expanded = append(expanded, v)
+		}
+	}
+	spec["object-templates"] = expanded
+	return errs
+}
+
+func (c objectContext) String() string {
+	if c.ObjectNamespace == "" {
+		return c.ObjectName
+	}
+	return c.ObjectNamespace + "/" + c.ObjectName
+}
+
+// objectContexts returns the contexts the controller evaluates the object
+// template ot with.
+func (r *Resolver) objectContexts(ot map[string]interface{}, nsSel *namespaceSelector) ([]objectContext, error) {
+	def, _ := ot["objectDefinition"].(map[string]interface{})
+	literal := func(keys ...string) string {
+		s, _ := nestedString(def, keys...)
+		if strings.Contains(s, "{{") {
+			return ""
+		}
+		return s
+	}
+	apiVersion, kind := literal("apiVersion"), literal("kind")
+	namespace, name := literal("metadata", "namespace"), literal("metadata", "name")
+
+	namespaces := []string{namespace}
+	if namespace == "" && nsSel != nil && !clusterScoped(apiVersion, kind) {
+		namespaces = r.selectNamespaces(*nsSel)
+		if len(namespaces) == 0 {
+			return nil, fmt.Errorf("namespaceSelector selects no Namespace in testdata, so the controller never evaluates %s — add one to tools/testdata", kind)
+		}
+	}
+
+	rawSel, hasObjectSelector := ot["objectSelector"]
+	if name != "" || !hasObjectSelector {
+		var out []objectContext
+		for _, ns := range namespaces {
+			out = append(out, objectContext{ObjectNamespace: ns, ObjectName: name, Object: r.localObject(apiVersion, kind, ns, name)})
+		}
+		return out, nil
+	}
+
+	sel, err := labelSelector(rawSel)
+	if err != nil {
+		return nil, fmt.Errorf("objectSelector: %v", err)
+	}
+	var out []objectContext
+	for _, ns := range namespaces {
+		for _, u := range r.local {
+			if u.GetAPIVersion() != apiVersion || u.GetKind() != kind || (ns != "" && u.GetNamespace() != ns) {
+				continue
+			}
+			if sel.Matches(labels.Set(u.GetLabels())) {
+				out = append(out, objectContext{ObjectNamespace: u.GetNamespace(), ObjectName: u.GetName(), Object: u.DeepCopy().Object})
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("objectSelector matches no %s %s in testdata, so the controller never evaluates it — add one to tools/testdata", apiVersion, kind)
+	}
+	return out, nil
+}
+
+// selectNamespaces returns the resolver's Namespaces sel picks: those its
+// label selector matches (all, without one) whose name matches an include
+// pattern (any, without one) and no exclude pattern. A selector with neither
+// includes nor labels picks nothing. Like the API server, every Namespace
+// carries a kubernetes.io/metadata.name label.
+func (r *Resolver) selectNamespaces(sel namespaceSelector) []string {
+	hasLabels := len(sel.MatchLabels) > 0 || len(sel.MatchExpressions) > 0
+	if len(sel.Include) == 0 && !hasLabels {
+		return nil
+	}
+	labelSel := labels.Everything()
+	if hasLabels {
+		var err error
+		if labelSel, err = metav1.LabelSelectorAsSelector(&sel.LabelSelector); err != nil {
+			return nil
+		}
+	}
+	matchAny := func(patterns []string, name string) bool {
+		for _, p := range patterns {
+			if ok, _ := filepath.Match(p, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	var out []string
+	for _, u := range r.local {
+		if u.GetAPIVersion() != "v1" || u.GetKind() != "Namespace" {
+			continue
+		}
+		name := u.GetName()
+		set := labels.Set{"kubernetes.io/metadata.name": name}
+		for k, v := range u.GetLabels() {
+			set[k] = v
+		}
+		if !labelSel.Matches(set) ||
+			(len(sel.Include) > 0 && !matchAny(sel.Include, name)) ||
+			matchAny(sel.Exclude, name) {
+			continue
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
+// clusterScopedKinds are the cluster-scoped kinds a policy in this repo
+// creates or may reasonably create, by API group. A namespaceSelector does
+// not apply to them. A kind missing here is taken to be namespaced and
+// evaluated once per selected Namespace, so add a cluster-scoped kind when a
+// policy starts managing one.
+var clusterScopedKinds = map[schema.GroupKind]bool{
+	{Group: "", Kind: "Namespace"}:        true,
+	{Group: "", Kind: "Node"}:             true,
+	{Group: "", Kind: "PersistentVolume"}: true,
+	{Group: "addon.open-cluster-management.io", Kind: "ClusterManagementAddOn"}:     true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:   true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}: true,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:               true,
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                           true,
+	{Group: "cdi.kubevirt.io", Kind: "StorageProfile"}:                              true,
+	{Group: "cert-manager.io", Kind: "ClusterIssuer"}:                               true,
+	{Group: "cluster.open-cluster-management.io", Kind: "ClusterClaim"}:             true,
+	{Group: "cluster.open-cluster-management.io", Kind: "ManagedCluster"}:           true,
+	{Group: "cluster.open-cluster-management.io", Kind: "ManagedClusterSet"}:        true,
+	{Group: "config.openshift.io", Kind: "APIServer"}:                               true,
+	{Group: "config.openshift.io", Kind: "ClusterOperator"}:                         true,
+	{Group: "config.openshift.io", Kind: "ClusterVersion"}:                          true,
+	{Group: "config.openshift.io", Kind: "Console"}:                                 true,
+	{Group: "config.openshift.io", Kind: "DNS"}:                                     true,
+	{Group: "config.openshift.io", Kind: "Image"}:                                   true,
+	{Group: "config.openshift.io", Kind: "ImageDigestMirrorSet"}:                    true,
+	{Group: "config.openshift.io", Kind: "ImageTagMirrorSet"}:                       true,
+	{Group: "config.openshift.io", Kind: "Infrastructure"}:                          true,
+	{Group: "config.openshift.io", Kind: "Ingress"}:                                 true,
+	{Group: "config.openshift.io", Kind: "Network"}:                                 true,
+	{Group: "config.openshift.io", Kind: "OAuth"}:                                   true,
+	{Group: "config.openshift.io", Kind: "OperatorHub"}:                             true,
+	{Group: "config.openshift.io", Kind: "Proxy"}:                                   true,
+	{Group: "config.openshift.io", Kind: "Scheduler"}:                               true,
+	{Group: "console.openshift.io", Kind: "ConsoleLink"}:                            true,
+	{Group: "console.openshift.io", Kind: "ConsoleNotification"}:                    true,
+	{Group: "console.openshift.io", Kind: "ConsolePlugin"}:                          true,
+	{Group: "hive.openshift.io", Kind: "ClusterImageSet"}:                           true,
+	{Group: "imageregistry.operator.openshift.io", Kind: "Config"}:                  true,
+	{Group: "machineconfiguration.openshift.io", Kind: "ContainerRuntimeConfig"}:    true,
+	{Group: "machineconfiguration.openshift.io", Kind: "KubeletConfig"}:             true,
+	{Group: "machineconfiguration.openshift.io", Kind: "MachineConfig"}:             true,
+	{Group: "machineconfiguration.openshift.io", Kind: "MachineConfigPool"}:         true,
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                              true,
+	{Group: "nmstate.io", Kind: "NodeNetworkConfigurationPolicy"}:                   true,
+	{Group: "operator.openshift.io", Kind: "Console"}:                               true,
+	{Group: "operator.openshift.io", Kind: "ImageContentSourcePolicy"}:              true,
+	{Group: "operator.openshift.io", Kind: "Network"}:                               true,
+	{Group: "performance.openshift.io", Kind: "PerformanceProfile"}:                 true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                       true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                true,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                             true,
+	{Group: "security.openshift.io", Kind: "SecurityContextConstraints"}:            true,
+	{Group: "snapshot.storage.k8s.io", Kind: "VolumeSnapshotClass"}:                 true,
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                    true,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                 true,
+	{Group: "trident.netapp.io", Kind: "TridentOrchestrator"}:                       true,
+	{Group: "user.openshift.io", Kind: "Group"}:                                     true,
+}
+
+// clusterScoped reports whether apiVersion and kind name a cluster-scoped
+// kind, whatever testdata holds.
+func clusterScoped(apiVersion, kind string) bool {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	return err == nil && clusterScopedKinds[gv.WithKind(kind).GroupKind()]
+}
+
+// localObject returns the resolver's object with the identity, or nil.
+func (r *Resolver) localObject(apiVersion, kind, namespace, name string) map[string]interface{} {
+	if name == "" {
+		return nil
+	}
+	for _, u := range r.local {
+		if u.GetAPIVersion() == apiVersion && u.GetKind() == kind && u.GetNamespace() == namespace && u.GetName() == name {
+			return u.DeepCopy().Object
+		}
+	}
+	return nil
+}
+
+func labelSelector(raw interface{}) (labels.Selector, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var ls metav1.LabelSelector
+	if err := json.Unmarshal(data, &ls); err != nil {
+		return nil, err
+	}
+	return metav1.LabelSelectorAsSelector(&ls)
+}
+
+// resolveValue resolves the spoke templates in v with ctx (nil for none) and
+// opts, decrypting its encrypted values when opts enables it, and returns v
+// with them replaced.
+func (r *Resolver) resolveValue(v interface{}, ctx interface{}, opts *templates.ResolveOptions) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(string(data), "{{") && !(opts.DecryptionEnabled && strings.Contains(string(data), encryptedPrefix)) {
+		return v, nil
+	}
+	result, err := r.inner.ResolveTemplate(data, ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(result.ResolvedJSON, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var cantEvaluateRe = regexp.MustCompile(`can't evaluate field (\w+) in type`)
+
+// explainSpokeError adds to err what the controller's context lacks when a
+// spoke template reads a field it does not have.
+func explainSpokeError(err error) string {
+	m := cantEvaluateRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err.Error()
+	}
+	switch m[1] {
+	case "ManagedClusterName", "ManagedClusterLabels", "PolicyMetadata":
+		return fmt.Sprintf("%v — .%s is hub-only context, the managed cluster's config-policy-controller has none; read it in a {{hub ... hub}} template", err, m[1])
+	case "ObjectNamespace", "ObjectName", "Object":
+		return fmt.Sprintf("%v — .%s is only set in a ConfigurationPolicy's object-templates, not object-templates-raw", err, m[1])
+	}
+	return err.Error()
+}
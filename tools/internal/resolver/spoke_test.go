@@ -0,0 +1,225 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func spokeTestResources() []unstructured.Unstructured {
+	ns := func(name string, lbls map[string]interface{}) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1", "kind": "Namespace",
+			"metadata": map[string]interface{}{"name": name, "labels": lbls},
+		}}
+	}
+	cm := func(namespace, name, tier string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1", "kind": "ConfigMap",
+			"metadata": map[string]interface{}{"name": name, "namespace": namespace, "labels": map[string]interface{}{"tier": tier}},
+			"data":     map[string]interface{}{"size": name + "-size"},
+		}}
+	}
+	return []unstructured.Unstructured{
+		ns("app-a", map[string]interface{}{"team": "a"}),
+		ns("app-b", nil),
+		ns("other", map[string]interface{}{"team": "a"}),
+		cm("app-a", "web", "front"),
+		cm("app-a", "db", "back"),
+	}
+}
+
+// configurationPolicy wraps a ConfigurationPolicy spec in a Policy the way
+// PolicyGenerator renders it.
+func configurationPolicy(spec string) string {
+	return `apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+metadata:
+  name: policy-test
+  namespace: policies-autoshift
+spec:
+  policy-templates:
+  - objectDefinition:
+      apiVersion: policy.open-cluster-management.io/v1
+      kind: ConfigurationPolicy
+      metadata:
+        name: test
+      spec:
+` + indent(spec, 8)
+}
+
+func indent(s string, n int) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = pad + l
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestResolveSpokePolicies_NamespaceSelector(t *testing.T) {
+	r, err := NewSpokeResolver(spokeTestResources())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := r.ResolveSpokePolicies(configurationPolicy(`namespaceSelector:
+  include: ["app-*"]
+object-templates:
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: marker
+    data:
+      ns: '{{ .ObjectNamespace }}'
+      existing: '{{ .Object.metadata.name | default "none" }}'
+`))
+	if len(res.Errors) > 0 {
+		t.Fatalf("errors: %v", res.Errors)
+	}
+	for _, want := range []string{"ns: app-a", "ns: app-b", "existing: none"} {
+		if !strings.Contains(res.Resolved, want) {
+			t.Errorf("resolved output lacks %q:\n%s", want, res.Resolved)
+		}
+	}
+	if strings.Contains(res.Resolved, "ns: other") {
+		t.Errorf("namespace other is not included:\n%s", res.Resolved)
+	}
+
+	res = r.ResolveSpokePolicies(configurationPolicy(`namespaceSelector:
+  matchLabels:
+    team: a
+  exclude: ["other"]
+object-templates:
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: web
+    data:
+      ns: '{{ .ObjectNamespace }}'
+      size: '{{ .Object.data.size }}'
+`))
+	if len(res.Errors) > 0 {
+		t.Fatalf("errors: %v", res.Errors)
+	}
+	if !strings.Contains(res.Resolved, "ns: app-a") || !strings.Contains(res.Resolved, "size: web-size") || strings.Contains(res.Resolved, "ns: app-b") {
+		t.Errorf("matchLabels/exclude selection wrong:\n%s", res.Resolved)
+	}
+}
+
+func TestResolveSpokePolicies_NamespaceSelectorClusterScoped(t *testing.T) {
+	// No ClusterRole in testdata: scope comes from the kind, not the fixtures.
+	r, err := NewSpokeResolver(spokeTestResources())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := r.ResolveSpokePolicies(configurationPolicy(`namespaceSelector:
+  include: ["app-*"]
+object-templates:
+- complianceType: musthave
+  objectDefinition:
+    apiVersion: rbac.authorization.k8s.io/v1
+    kind: ClusterRole
+    metadata:
+      name: reader
+      annotations:
+        ns: 'x{{ .ObjectNamespace }}'
+`))
+	if len(res.Errors) > 0 {
+		t.Fatalf("errors: %v", res.Errors)
+	}
+	if n := strings.Count(res.Resolved, "kind: ClusterRole"); n != 1 || !strings.Contains(res.Resolved, "ns: x\n") {
+		t.Errorf("ClusterRole evaluated %d times, want once without a namespace:\n%s", n, res.Resolved)
+	}
+}
+
+func TestResolveSpokePolicies_ObjectSelector(t *testing.T) {
+	r, err := NewSpokeResolver(spokeTestResources())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := r.ResolveSpokePolicies(configurationPolicy(`object-templates:
+- complianceType: musthave
+  objectSelector:
+    matchLabels:
+      tier: front
+  objectDefinition:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      namespace: app-a
+      annotations:
+        seen: '{{ .ObjectName }}={{ .Object.data.size }}'
+`))
+	if len(res.Errors) > 0 {
+		t.Fatalf("errors: %v", res.Errors)
+	}
+	if !strings.Contains(res.Resolved, "seen: web=web-size") || strings.Contains(res.Resolved, "seen: db") {
+		t.Errorf("objectSelector expansion wrong:\n%s", res.Resolved)
+	}
+
+	res = r.ResolveSpokePolicies(configurationPolicy(`object-templates:
+- complianceType: musthave
+  objectSelector:
+    matchLabels:
+      tier: missing
+  objectDefinition:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      namespace: app-a
+      annotations:
+        seen: '{{ .ObjectName }}'
+`))
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0], "objectSelector matches no v1 ConfigMap") {
+		t.Errorf("errors = %v, want one objectSelector miss", res.Errors)
+	}
+}
+
+func TestResolveSpokePolicies_HubOnlyContext(t *testing.T) {
+	r, err := NewSpokeResolver(spokeTestResources())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]string{
+		".ManagedClusterLabels": "hub-only context",
+		".PolicyMetadata":       "hub-only context",
+		".ObjectName":           "not object-templates-raw",
+	} {
+		res := r.ResolveSpokePolicies(configurationPolicy(`object-templates-raw: |
+  - complianceType: musthave
+    objectDefinition:
+      apiVersion: v1
+      kind: ConfigMap
+      metadata:
+        name: x
+        namespace: app-a
+      data:
+        v: '{{ ` + field + ` }}'
+`))
+		if len(res.Errors) != 1 || !strings.Contains(res.Errors[0], want) || !strings.HasPrefix(res.Errors[0], "Policy policy-test: ConfigurationPolicy test: ") {
+			t.Errorf("%s: errors = %v, want one mentioning %q", field, res.Errors, want)
+		}
+	}
+
+	// The same template resolves with ResolveSpokeTemplates, which hands every
+	// document the hub context.
+	res := r.ResolveSpokeTemplates(configurationPolicy(`object-templates-raw: |
+  - complianceType: musthave
+    objectDefinition:
+      apiVersion: v1
+      kind: ConfigMap
+      metadata:
+        name: x
+        namespace: app-a
+      data:
+        v: '{{ .ManagedClusterName }}'
+`), HubContext{ManagedClusterName: "spoke"})
+	if len(res.Errors) > 0 {
+		t.Errorf("ResolveSpokeTemplates: %v", res.Errors)
+	}
+}
@@ -0,0 +1,231 @@
+package resolver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// StubPlaceholder is the value a stub gives every field its template reads.
+const StubPlaceholder = "CHANGEME"
+
+// StubFile is a testdata file of objects of one kind: stubs, or objects
+// imported from a cluster (ImportFiles).
+type StubFile struct {
+	Name    string   // stub-<resource>[.<group>].yaml
+	IDs     []string // identity of each object, see stubID
+	Docs    []string // one YAML document per object; a stub's has a comment naming its lookup
+	Lookups []Lookup // nil for imported objects
+}
+
+// Stubs turns failed lookups into testdata stubs, one file per kind and one
+// object per identity. Each object carries the lookup's namespace, name and
+// selector labels, and StubPlaceholder (base64 in a Secret's data) at every
+// field the template reads. A list lookup or a non-literal name gets a
+// stub-<kind> name to edit.
+func Stubs(lookups []Lookup) []StubFile {
+	byFile := map[string]*StubFile{}
+	index := map[string]int{}
+	for _, l := range lookups {
+		name := stubFileName(l.APIVersion, l.Kind)
+		f := byFile[name]
+		if f == nil {
+			f = &StubFile{Name: name}
+			byFile[name] = f
+		}
+		obj := stubObject(l)
+		id := stubID(obj)
+		if i, ok := index[name+"\x00"+id]; ok {
+			// Two lookups of one object (e.g. a get and a list): one stub with
+			// both lookups' fields.
+			prev := f.Lookups[i]
+			prev.Fields = mergeSorted(prev.Fields, l.Fields)
+			prev.Policies = mergeSorted(prev.Policies, l.Policies)
+			f.Lookups[i] = prev
+			f.Docs[i] = stubDoc(prev)
+			continue
+		}
+		index[name+"\x00"+id] = len(f.Docs)
+		f.IDs = append(f.IDs, id)
+		f.Docs = append(f.Docs, stubDoc(l))
+		f.Lookups = append(f.Lookups, l)
+	}
+
+	names := make([]string, 0, len(byFile))
+	for name := range byFile {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]StubFile, 0, len(names))
+	for _, name := range names {
+		out = append(out, *byFile[name])
+	}
+	return out
+}
+
+// WriteStubs writes files into dir. A file that already exists keeps its
+// content and gets only the objects it does not hold yet appended, so
+// re-running after editing a stub never overwrites the edit. It returns the
+// paths it wrote to.
+func WriteStubs(dir string, files []StubFile) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var written []string
+	for _, f := range files {
+		path := filepath.Join(dir, f.Name)
+		existing, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return written, err
+		}
+		have := map[string]bool{}
+		for _, doc := range splitYAMLDocuments(string(existing)) {
+			var obj map[string]interface{}
+			if sigsyaml.Unmarshal([]byte(doc), &obj) == nil && obj != nil {
+				have[stubID(obj)] = true
+			}
+		}
+
+		var b strings.Builder
+		b.Write(existing)
+		added := 0
+		for i, doc := range f.Docs {
+			if have[f.IDs[i]] {
+				continue
+			}
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString("\n")
+			}
+			b.WriteString("---\n")
+			b.WriteString(doc)
+			added++
+		}
+		if added == 0 {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// stubFileName names the stub file for a kind after its resource, the way a
+// CRD is named: stub-clusterversions.config.openshift.io.yaml.
+func stubFileName(apiVersion, kind string) string {
+	return resourceFileName("stub-", apiVersion, kind)
+}
+
+func resourceFileName(prefix, apiVersion, kind string) string {
+	group, _ := splitAPIVersion(apiVersion)
+	name := prefix + kindToResource(kind)
+	if group != "" {
+		name += "." + group
+	}
+	return name + ".yaml"
+}
+
+// stubID is the identity LoadTestResources' consumers match lookups on.
+func stubID(obj map[string]interface{}) string {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	ns, _ := nestedString(obj, "metadata", "namespace")
+	name, _ := nestedString(obj, "metadata", "name")
+	return strings.Join([]string{apiVersion, kind, ns, name}, "/")
+}
+
+func stubObject(l Lookup) map[string]interface{} {
+	name := l.Name
+	if name == "" {
+		name = "stub-" + strings.ToLower(l.Kind)
+	}
+	metadata := map[string]interface{}{"name": name}
+	if l.Namespace != "" {
+		metadata["namespace"] = l.Namespace
+	}
+	if sel, err := labels.Parse(l.Selector); err == nil && l.Selector != "" {
+		reqs, _ := sel.Requirements()
+		lbls := map[string]interface{}{}
+		for _, req := range reqs {
+			switch req.Operator() {
+			case selection.Equals, selection.DoubleEquals, selection.In:
+				lbls[req.Key()] = req.Values().List()[0]
+			case selection.Exists:
+				lbls[req.Key()] = ""
+			}
+		}
+		if len(lbls) > 0 {
+			metadata["labels"] = lbls
+		}
+	}
+	obj := map[string]interface{}{
+		"apiVersion": l.APIVersion,
+		"kind":       l.Kind,
+		"metadata":   metadata,
+	}
+
+	// Shortest paths first, so a deeper read replaces a placeholder its
+	// parent path left.
+	paths := make([][]string, 0, len(l.Fields))
+	for _, f := range l.Fields {
+		paths = append(paths, splitFieldPath(f))
+	}
+	sort.SliceStable(paths, func(i, j int) bool { return len(paths[i]) < len(paths[j]) })
+	for _, keys := range paths {
+		if len(keys) == 0 {
+			continue
+		}
+		switch keys[0] {
+		case "apiVersion", "kind":
+			continue
+		case "metadata":
+			if len(keys) < 2 || keys[1] == "name" || keys[1] == "namespace" {
+				continue
+			}
+		}
+		value := StubPlaceholder
+		if l.Kind == "Secret" && keys[0] == "data" {
+			value = base64.StdEncoding.EncodeToString([]byte(StubPlaceholder))
+		}
+		setStubField(obj, keys, value)
+	}
+	return obj
+}
+
+// setStubField sets obj[keys...] = value, replacing a placeholder on the way
+// with a map and leaving a map already at the leaf alone.
+func setStubField(obj map[string]interface{}, keys []string, value string) {
+	cur := obj
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := cur[k].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			cur[k] = next
+		}
+		cur = next
+	}
+	leaf := keys[len(keys)-1]
+	if _, isMap := cur[leaf].(map[string]interface{}); !isMap {
+		cur[leaf] = value
+	}
+}
+
+func stubDoc(l Lookup) string {
+	data, _ := sigsyaml.Marshal(stubObject(l)) // maps of strings always marshal
+	header := fmt.Sprintf("# Stub for %s, read by %s.\n", l, strings.Join(l.Policies, ", "))
+	if l.Name == "" {
+		header += "# Set the name (and namespace) the template expects.\n"
+	}
+	if len(l.Fields) > 0 {
+		header += fmt.Sprintf("# Replace each %s with a value the template can use.\n", StubPlaceholder)
+	}
+	return header + string(data)
+}
@@ -216,7 +216,14 @@ func installVariant(fileName string) string {
 // full rendering coverage).
 //
 // clusterName is used for the synthetic test cluster entry (lint-cluster).
+// The deployment values (policy namespace, dryRun, evaluation intervals,
+// clusterset suffix, gitops namespace) are DefaultDeployment's.
 func WriteTestValues(tmpDir, clusterName string, cfg *ExampleConfigs) (string, error) {
+	return WriteTestValuesFor(tmpDir, clusterName, cfg, DefaultDeployment())
+}
+
+// WriteTestValuesFor is WriteTestValues with dep's deployment values.
+func WriteTestValuesFor(tmpDir, clusterName string, cfg *ExampleConfigs, dep Deployment) (string, error) {
 	hubLabels := map[string]interface{}{"self-managed": "true"}
 	var hubConfig interface{}
 	var clusterConfig interface{}
@@ -262,15 +269,19 @@ func WriteTestValues(tmpDir, clusterName string, cfg *ExampleConfigs) (string, e
 		clusterEntry["config"] = clusterConfig
 	}
 
+	gitopsNamespace := dep.GitopsNamespace
+	if gitopsNamespace == "" {
+		gitopsNamespace = "openshift-gitops"
+	}
 	values := map[string]interface{}{
-		"policy_namespace":  "policies-autoshift",
-		"gitopsNamespace":   "openshift-gitops",
-		"clusterSetSuffix":  "",
+		"policy_namespace": dep.Namespace(),
+		"gitopsNamespace":  gitopsNamespace,
+		"clusterSetSuffix": dep.Placeholders["CLUSTER_SET_SUFFIX"],
 		"autoshift": map[string]interface{}{
-			"dryRun": false,
+			"dryRun": dep.DryRun(),
 			"evaluationInterval": map[string]interface{}{
-				"compliant":    "10m",
-				"noncompliant": "30s",
+				"compliant":    dep.Placeholders["EVAL_COMPLIANT"],
+				"noncompliant": dep.Placeholders["EVAL_NONCOMPLIANT"],
 			},
 		},
 		"hubClusterSets": map[string]interface{}{
@@ -0,0 +1,406 @@
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Tier is one AutoShift instance in a hub-of-hubs topology
+// (docs/hub-of-hubs.md): its own release, policy namespace and values, running
+// on one cluster and reaching only the clusters its ACM manages.
+type Tier struct {
+	Name string
+	// Release is the ArgoCD Application name; the policy namespace is
+	// policies-<Release>.
+	Release string
+	// Cluster is the cluster the instance runs on.
+	Cluster string
+	// Parent names the tier whose ACM manages Cluster, or "" for the top hub,
+	// which manages itself.
+	Parent string
+	// ValuesFiles are the files the Application renders the autoshift chart
+	// with, relative to autoshift/values.
+	ValuesFiles []string
+	// Values are set on top of ValuesFiles, as an Application's inline helm
+	// values are.
+	Values map[string]interface{}
+}
+
+// HubOfHubsTiers are the instances of the "Naming and namespaces per tier"
+// table in docs/hub-of-hubs.md. The per-spoke clusters/*.yaml files the table
+// lists are site-specific and not in the repo; the spoke hubs render with the
+// managed clusterset alone.
+func HubOfHubsTiers() []Tier {
+	return []Tier{
+		{
+			Name: "hubofhubs", Release: "autoshift", Cluster: "hubofhubs",
+			ValuesFiles: []string{"global.yaml", "clustersets/hubofhubs.yaml", "clustersets/hub1.yaml", "clustersets/hub2.yaml"},
+		},
+		{
+			Name: "hub1", Release: "hub1", Cluster: "hub1", Parent: "hubofhubs",
+			ValuesFiles: []string{"global.yaml", "clustersets/hub1.yaml", "clustersets/managed.yaml"},
+		},
+		{
+			Name: "hub2", Release: "hub2", Cluster: "hub2", Parent: "hubofhubs",
+			ValuesFiles: []string{"global.yaml", "clustersets/hub2.yaml", "clustersets/managed.yaml"},
+		},
+	}
+}
+
+// TierCluster is a fleet cluster with the part it plays in the topology.
+type TierCluster struct {
+	FleetCluster
+	// Hub is the hub's cluster name when the cluster is a hub (the tier's own
+	// cluster for a self-managed entry), "" for a spoke.
+	Hub string
+}
+
+// TierRender is a tier's autoshift chart render, reduced to what its policies
+// render and are placed with.
+type TierRender struct {
+	Tier       Tier
+	Deployment Deployment
+	// Fleet is the clusters the tier's ACM manages, one per clusterset.
+	Fleet []TierCluster
+	// ClusterSets are every clusterset the tier's values declare, suffixed.
+	ClusterSets []string
+	// Rendered is the autoshift chart output.
+	Rendered string
+}
+
+// RenderTier renders the autoshift chart as tier t's Application does and
+// writes the policy values it produces into workDir.
+func RenderTier(chartDir, valuesDir, workDir string, t Tier) (*TierRender, error) {
+	var files []string
+	for _, f := range t.ValuesFiles {
+		files = append(files, filepath.Join(valuesDir, f))
+	}
+	if len(t.Values) > 0 {
+		data, err := sigsyaml.Marshal(t.Values)
+		if err != nil {
+			return nil, err
+		}
+		inline := filepath.Join(workDir, t.Name+"-inline-values.yaml")
+		if err := os.WriteFile(inline, data, 0o644); err != nil {
+			return nil, err
+		}
+		files = append(files, inline)
+	}
+	out, err := HelmTemplateRelease(t.Release, chartDir, files...)
+	if err != nil {
+		return nil, fmt.Errorf("tier %s: %w", t.Name, err)
+	}
+	return ParseTierRender(out, t, workDir)
+}
+
+// ParseTierRender reads the deployment a tier's policies get from the
+// rendered autoshift chart: the plugin env and valuesObject of the
+// ApplicationSet, and the valuesObject of each dedicated Application. The
+// fleet comes from the cluster-labels values, the only ones that carry every
+// clusterset's labels.
+func ParseTierRender(rendered string, t Tier, workDir string) (*TierRender, error) {
+	dep := Deployment{Placeholders: map[string]string{}, ChartValuesFiles: map[string]string{}}
+	var labelValues map[string]interface{}
+
+	writeValues := func(chart string, values map[string]interface{}) (string, error) {
+		data, err := sigsyaml.Marshal(values)
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(workDir, fmt.Sprintf("%s-%s-values.yaml", t.Name, chart))
+		return path, os.WriteFile(path, data, 0o644)
+	}
+
+	for _, doc := range splitYAMLDocuments(rendered) {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil || obj == nil {
+			continue
+		}
+		switch obj["kind"] {
+		case "ApplicationSet":
+			gens, _ := digSlice(obj, "spec", "generators")
+			for _, g := range gens {
+				gen, _ := g.(map[string]interface{})
+				for _, body := range gen {
+					sources, _ := digSlice(body, "template", "spec", "sources")
+					for _, src := range sources {
+						env, _ := digSlice(src, "plugin", "env")
+						for _, e := range env {
+							kv, _ := e.(map[string]interface{})
+							if name, ok := kv["name"].(string); ok {
+								dep.Placeholders[name] = fmt.Sprint(kv["value"])
+							}
+						}
+						if vo, ok := digMap(src, "helm", "valuesObject"); ok {
+							path, err := writeValues("policies", vo)
+							if err != nil {
+								return nil, err
+							}
+							dep.ValuesFile = path
+						}
+					}
+				}
+			}
+		case "Application":
+			name, _ := digString(obj, "metadata", "name")
+			chart := strings.TrimPrefix(name, t.Release+"-")
+			sources, _ := digSlice(obj, "spec", "sources")
+			for _, src := range sources {
+				vo, ok := digMap(src, "helm", "valuesObject")
+				if !ok {
+					continue
+				}
+				path, err := writeValues(chart, vo)
+				if err != nil {
+					return nil, err
+				}
+				dep.ChartValuesFiles[chart] = path
+				if chart == "cluster-labels" {
+					labelValues = vo
+				}
+			}
+		}
+	}
+
+	if dep.Namespace() == "" {
+		return nil, fmt.Errorf("tier %s: the ApplicationSet sets no POLICY_NAMESPACE", t.Name)
+	}
+	if labelValues == nil {
+		return nil, fmt.Errorf("tier %s: no %s-cluster-labels Application rendered", t.Name, t.Release)
+	}
+	tr := &TierRender{Tier: t, Deployment: dep, Fleet: tierFleet(t, labelValues), Rendered: rendered}
+	for _, bucket := range []string{"hubClusterSets", "managedClusterSets"} {
+		sets, _ := labelValues[bucket].(map[string]interface{})
+		tr.ClusterSets = append(tr.ClusterSets, sortedKeys(sets)...)
+	}
+	sort.Strings(tr.ClusterSets)
+	return tr, nil
+}
+
+// tierFleet derives the clusters a tier's ACM manages from its cluster-labels
+// values, one representative cluster per clusterset:
+//
+//   - a self-managed hub clusterset is the tier's own local-cluster;
+//   - any other hub clusterset is a spoke hub of that name, except the tier's
+//     own cluster: a spoke hub lists itself (self-managed: 'false') for its
+//     ConfigMaps, but its ACM does not manage it;
+//   - a managed clusterset is a leaf spoke.
+//
+// Labels are the clusterset's, prefixed as cluster-labels stamps them.
+func tierFleet(t Tier, values map[string]interface{}) []TierCluster {
+	suffix, _ := values["clusterSetSuffix"].(string)
+	var fleet []TierCluster
+	add := func(set, name, hub string, labels map[string]interface{}) {
+		c := TierCluster{Hub: hub, FleetCluster: FleetCluster{
+			Name:       name,
+			ClusterSet: set,
+			Labels: map[string]string{
+				"name": name,
+				"cluster.open-cluster-management.io/clusterset": set,
+			},
+		}}
+		for k, v := range labels {
+			c.Labels["autoshift.io/"+k] = fmt.Sprint(v)
+		}
+		if name == "local-cluster" {
+			c.Labels["local-cluster"] = "true"
+		}
+		fleet = append(fleet, c)
+	}
+
+	hubs, _ := values["hubClusterSets"].(map[string]interface{})
+	for _, set := range sortedKeys(hubs) {
+		labels, _ := digMap(hubs[set], "labels")
+		name := strings.TrimSuffix(set, suffix)
+		switch {
+		case fmt.Sprint(labels["self-managed"]) == "true":
+			add(set, "local-cluster", t.Cluster, labels)
+		case name == t.Cluster:
+			// Listed for its ConfigMaps only; not in this tier's fleet.
+		default:
+			add(set, name, name, labels)
+		}
+	}
+	spokes, _ := values["managedClusterSets"].(map[string]interface{})
+	for _, set := range sortedKeys(spokes) {
+		labels, _ := digMap(spokes[set], "labels")
+		add(set, set+"-spoke", "", labels)
+	}
+	return fleet
+}
+
+// Clusters returns the fleet as Placements see it.
+func (tr *TierRender) Clusters() []FleetCluster {
+	out := make([]FleetCluster, len(tr.Fleet))
+	for i, c := range tr.Fleet {
+		out[i] = c.FleetCluster
+	}
+	return out
+}
+
+// TierRun is a tier together with its resolved policies.
+type TierRun struct {
+	*TierRender
+	// Resolved is every policy's resolved output, as multi-document YAML.
+	Resolved   string
+	Placements *Placements
+}
+
+// NewTierRun pairs a tier render with its policies' resolved output.
+func NewTierRun(tr *TierRender, resolved string) TierRun {
+	return TierRun{TierRender: tr, Resolved: resolved, Placements: ParsePlacements(resolved)}
+}
+
+// CheckTiers reports every policy that lands on a cluster its tier must not
+// target. An AutoShift instance can only place onto clusters its own ACM
+// manages, so:
+//
+//   - a spoke hub's instance never targets the spoke hub itself — the tier
+//     above configures it;
+//   - a tier targets a spoke hub only if it is that hub's parent;
+//   - a tier never targets the leaf spokes of a clusterset one of its child
+//     tiers manages — they are two boundaries away.
+func CheckTiers(runs []TierRun) []string {
+	byCluster := map[string]Tier{}
+	children := map[string][]Tier{}
+	for _, run := range runs {
+		byCluster[run.Tier.Cluster] = run.Tier
+		if run.Tier.Parent != "" {
+			children[run.Tier.Parent] = append(children[run.Tier.Parent], run.Tier)
+		}
+	}
+	childSets := map[string]map[string]string{} // tier → spoke clusterset → child tier
+	for _, run := range runs {
+		if run.Tier.Parent == "" {
+			continue
+		}
+		for _, c := range run.Fleet {
+			if c.Hub != "" {
+				continue
+			}
+			if childSets[run.Tier.Parent] == nil {
+				childSets[run.Tier.Parent] = map[string]string{}
+			}
+			childSets[run.Tier.Parent][c.ClusterSet] = run.Tier.Name
+		}
+	}
+
+	var out []string
+	seen := map[string]bool{}
+	for _, run := range runs {
+		t := run.Tier
+		byName := map[string]TierCluster{}
+		for _, c := range run.Fleet {
+			byName[c.Name] = c
+		}
+		for _, d := range run.Placements.Decide(run.Clusters()) {
+			c := byName[d.Cluster.Name]
+			var msg string
+			switch {
+			case c.Hub == t.Cluster && t.Parent != "":
+				msg = fmt.Sprintf("lands on %s itself, which %s's ACM does not manage — configure it from tier %s", t.Cluster, t.Cluster, t.Parent)
+			case c.Hub != "" && c.Hub != t.Cluster && byCluster[c.Hub].Name != "" && byCluster[c.Hub].Parent != t.Name:
+				msg = fmt.Sprintf("lands on spoke hub %s, which tier %s manages", c.Hub, parentName(byCluster[c.Hub]))
+			case c.Hub == "" && childSets[t.Name][c.ClusterSet] != "":
+				msg = fmt.Sprintf("lands on leaf spokes of clusterset %s, which tier %s manages and %s cannot see", c.ClusterSet, childSets[t.Name][c.ClusterSet], t.Name)
+			default:
+				continue
+			}
+			line := fmt.Sprintf("tier %s: policy %s (placement %s) %s", t.Name, d.Policy, d.Placement, msg)
+			if !seen[line] {
+				seen[line] = true
+				out = append(out, line)
+			}
+		}
+	}
+	return out
+}
+
+func parentName(t Tier) string {
+	if t.Parent == "" {
+		return t.Name
+	}
+	return t.Parent
+}
+
+// ForeignNamespaces lists the references in multiDocYAML to a policy namespace
+// other than ns: metadata.namespace and Policy dependencies, both of which a
+// deployment fills from its own namespace. A policies-autoshift left in another
+// release's render is a hardcoded namespace that only works for the default
+// release.
+func ForeignNamespaces(ns, multiDocYAML string) []string {
+	var out []string
+	for i, doc := range splitYAMLDocuments(multiDocYAML) {
+		var obj struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				Dependencies []struct {
+					Name      string `json:"name"`
+					Namespace string `json:"namespace"`
+				} `json:"dependencies"`
+			} `json:"spec"`
+		}
+		if strings.TrimSpace(doc) == "" || sigsyaml.Unmarshal([]byte(doc), &obj) != nil {
+			continue
+		}
+		foreign := func(n string) bool { return strings.HasPrefix(n, "policies-") && n != ns }
+		id := docIdentity(doc, i)
+		if foreign(obj.Metadata.Namespace) {
+			out = append(out, fmt.Sprintf("%s is in namespace %s, want %s", id, obj.Metadata.Namespace, ns))
+		}
+		for _, dep := range obj.Spec.Dependencies {
+			if foreign(dep.Namespace) {
+				out = append(out, fmt.Sprintf("%s depends on %s/%s, want namespace %s", id, dep.Namespace, dep.Name, ns))
+			}
+		}
+	}
+	return out
+}
+
+func digMap(v interface{}, path ...string) (map[string]interface{}, bool) {
+	for _, p := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v = m[p]
+	}
+	m, ok := v.(map[string]interface{})
+	return m, ok
+}
+
+func digSlice(v interface{}, path ...string) ([]interface{}, bool) {
+	parent, ok := digMap(v, path[:len(path)-1]...)
+	if !ok {
+		return nil, false
+	}
+	s, ok := parent[path[len(path)-1]].([]interface{})
+	return s, ok
+}
+
+func digString(v interface{}, path ...string) (string, bool) {
+	parent, ok := digMap(v, path[:len(path)-1]...)
+	if !ok {
+		return "", false
+	}
+	s, ok := parent[path[len(path)-1]].(string)
+	return s, ok
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
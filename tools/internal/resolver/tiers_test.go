@@ -0,0 +1,197 @@
+package resolver
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// tierRender is the part of an autoshift chart render ParseTierRender reads,
+// for a release "hub1" whose values list hub1 itself and a managed clusterset.
+const tierRender = `
+---
+# Source: autoshift/templates/autoshift-app-set.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: hub1-policies
+spec:
+  generators:
+  - git:
+      template:
+        spec:
+          sources:
+          - path: '{{ .path.path }}'
+            plugin:
+              name: policy-generator
+              env:
+              - name: POLICY_NAMESPACE
+                value: policies-hub1
+              - name: REMEDIATION
+                value: enforce
+              - name: CLUSTER_SET_SUFFIX
+                value: ''
+  - git:
+      template:
+        spec:
+          sources:
+          - path: '{{ .path.path }}'
+            helm:
+              valuesObject:
+                policy_namespace: policies-hub1
+---
+# Source: autoshift/templates/cluster-labels-configmaps.yaml
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: "hub1-cluster-labels"
+spec:
+  sources:
+    - path: policies/stable/cluster-labels
+      helm:
+        valuesObject:
+          policy_namespace: policies-hub1
+          clusterSetSuffix:
+          hubClusterSets:
+            hub1:
+              labels:
+                self-managed: 'false'
+                cluster-type: 'hub'
+          managedClusterSets:
+            managed:
+              labels:
+                odf: 'true'
+                cluster-type: 'spoke'
+`
+
+func TestParseTierRender(t *testing.T) {
+	dir := t.TempDir()
+	tier := Tier{Name: "hub1", Release: "hub1", Cluster: "hub1", Parent: "hubofhubs"}
+	tr, err := ParseTierRender(tierRender, tier, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dep := tr.Deployment
+	if dep.Namespace() != "policies-hub1" || dep.Placeholders["REMEDIATION"] != "enforce" {
+		t.Errorf("placeholders = %v", dep.Placeholders)
+	}
+	if got := dep.valuesFor("/repo/policies/stable/cluster-labels", "test.yaml"); got != dep.ChartValuesFiles["cluster-labels"] {
+		t.Errorf("cluster-labels renders with %s, want its Application's values", got)
+	}
+	if got := dep.valuesFor("/repo/policies/stable/openshift-gitops", "test.yaml"); got != dep.ValuesFile || got == "" {
+		t.Errorf("openshift-gitops renders with %q, want the ApplicationSet's values", got)
+	}
+	data, err := os.ReadFile(dep.ValuesFile)
+	if err != nil || !strings.Contains(string(data), "policy_namespace: policies-hub1") {
+		t.Errorf("policy values file: %s, %v", data, err)
+	}
+
+	// hub1 lists itself for its ConfigMaps, but its own ACM does not manage it.
+	if len(tr.Fleet) != 1 {
+		t.Fatalf("fleet = %+v, want only the managed spoke", tr.Fleet)
+	}
+	spoke := tr.Fleet[0]
+	if spoke.Hub != "" || spoke.ClusterSet != "managed" || spoke.Labels["autoshift.io/odf"] != "true" ||
+		spoke.Labels["cluster.open-cluster-management.io/clusterset"] != "managed" {
+		t.Errorf("spoke = %+v", spoke)
+	}
+
+	if _, err := ParseTierRender("kind: ConfigMap\n", tier, dir); err == nil {
+		t.Error("render without an ApplicationSet: want error")
+	}
+}
+
+// tierPolicies binds one policy to every cluster of a tier's clustersets.
+func tierPolicies(ns string, sets ...string) *Placements {
+	return &Placements{
+		Placements:  []Placement{{Name: "placement-all", Namespace: ns}},
+		Bound:       map[string][]string{ns + "/placement-all": {"policy-all"}},
+		ClusterSets: map[string][]string{ns: sets},
+	}
+}
+
+func TestCheckTiers(t *testing.T) {
+	hoh := Tier{Name: "hubofhubs", Cluster: "hubofhubs"}
+	hub1 := Tier{Name: "hub1", Cluster: "hub1", Parent: "hubofhubs"}
+	hub2 := Tier{Name: "hub2", Cluster: "hub2", Parent: "hubofhubs"}
+	values := func(hubs map[string]string, spokes ...string) map[string]interface{} {
+		hv := map[string]interface{}{}
+		for set, selfManaged := range hubs {
+			hv[set] = map[string]interface{}{"labels": map[string]interface{}{"self-managed": selfManaged}}
+		}
+		sv := map[string]interface{}{}
+		for _, set := range spokes {
+			sv[set] = map[string]interface{}{"labels": map[string]interface{}{}}
+		}
+		return map[string]interface{}{"hubClusterSets": hv, "managedClusterSets": sv}
+	}
+	run := func(tier Tier, v map[string]interface{}) TierRun {
+		fleet := tierFleet(tier, v)
+		var sets []string
+		for _, c := range fleet {
+			sets = append(sets, c.ClusterSet)
+		}
+		return TierRun{
+			TierRender: &TierRender{Tier: tier, Fleet: fleet},
+			Placements: tierPolicies("policies-"+tier.Name, sets...),
+		}
+	}
+
+	clean := []TierRun{
+		run(hoh, values(map[string]string{"hubofhubs": "true", "hub1": "false", "hub2": "false"})),
+		run(hub1, values(map[string]string{"hub1": "false"}, "managed")),
+		run(hub2, values(map[string]string{"hub2": "false"}, "managed")),
+	}
+	if got := CheckTiers(clean); len(got) != 0 {
+		t.Errorf("documented topology: %v", got)
+	}
+
+	broken := []TierRun{
+		// The top hub also loads managed.yaml: it cannot see hub1's spokes.
+		run(hoh, values(map[string]string{"hubofhubs": "true", "hub1": "false"}, "managed")),
+		// hub1 marks itself self-managed, so its own instance targets hub1.
+		run(hub1, values(map[string]string{"hub1": "true", "hub2": "false"}, "managed")),
+		run(hub2, values(map[string]string{"hub2": "false"})),
+	}
+	want := []string{
+		"tier hubofhubs: policy policy-all (placement placement-all) lands on leaf spokes of clusterset managed, which tier hub1 manages and hubofhubs cannot see",
+		"tier hub1: policy policy-all (placement placement-all) lands on hub1 itself, which hub1's ACM does not manage — configure it from tier hubofhubs",
+		"tier hub1: policy policy-all (placement placement-all) lands on spoke hub hub2, which tier hubofhubs manages",
+	}
+	if got := CheckTiers(broken); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CheckTiers:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestForeignNamespaces(t *testing.T) {
+	got := ForeignNamespaces("policies-hub1", `
+apiVersion: policy.open-cluster-management.io/v1
+kind: Policy
+metadata:
+  name: policy-storage-cluster
+  namespace: policies-hub1
+spec:
+  dependencies:
+    - name: policy-odf-operator-install
+      namespace: policies-autoshift
+---
+apiVersion: cluster.open-cluster-management.io/v1beta1
+kind: Placement
+metadata:
+  name: placement-odf
+  namespace: policies-autoshift
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: openshift-storage
+`)
+	want := []string{
+		"Policy/policy-storage-cluster (document 1) depends on policies-autoshift/policy-odf-operator-install, want namespace policies-hub1",
+		"Placement/placement-odf (document 2) is in namespace policies-autoshift, want policies-hub1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ForeignNamespaces:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
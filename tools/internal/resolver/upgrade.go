@@ -0,0 +1,310 @@
+package resolver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// The four policies policies/stable/openshift-upgrade renders, in the order
+// they run (docs/ocp-upgrade.md).
+const (
+	UpgradeChannelPolicy = "policy-openshift-upgrade-channel"
+	UpgradeAllowedPolicy = "policy-openshift-upgrade-allowed"
+	UpgradePolicy        = "policy-openshift-upgrade"
+	UpgradeStatusPolicy  = "policy-openshift-upgrade-status"
+)
+
+// UpgradeCase is one row of the openshift-upgrade evaluation matrix: the
+// labels the cluster's clusterset sets, and the ClusterVersion status the
+// spoke's lookup returns.
+type UpgradeCase struct {
+	Name    string
+	Target  string // autoshift.io/openshift-version; "" leaves the label unset
+	Channel string // autoshift.io/openshift-upgrade-channel; "" leaves the label unset
+
+	Current    string   // latest Completed history entry; "" when none has completed
+	InProgress string   // a Partial history entry above Current, with Progressing=True
+	Desired    string   // status.desired.version; defaults to InProgress, then Current
+	Available  []string // status.availableUpdates
+}
+
+// labels returns the ManagedClusterLabels the case sets.
+func (c UpgradeCase) labels() map[string]string {
+	l := map[string]string{"autoshift.io/openshift-upgrade": "true"}
+	if c.Target != "" {
+		l["autoshift.io/openshift-version"] = c.Target
+	}
+	if c.Channel != "" {
+		l["autoshift.io/openshift-upgrade-channel"] = c.Channel
+	}
+	return l
+}
+
+// ClusterVersion returns the ClusterVersion "version" with the case's status.
+func (c UpgradeCase) ClusterVersion() unstructured.Unstructured {
+	var history []interface{}
+	if c.InProgress != "" {
+		history = append(history, map[string]interface{}{"state": "Partial", "version": c.InProgress})
+	}
+	if c.Current != "" {
+		history = append(history, map[string]interface{}{"state": "Completed", "version": c.Current})
+	}
+	desired := c.Desired
+	if desired == "" {
+		desired = c.InProgress
+	}
+	if desired == "" {
+		desired = c.Current
+	}
+	var available []interface{}
+	for _, v := range c.Available {
+		available = append(available, map[string]interface{}{"version": v})
+	}
+	progressing := "False"
+	if c.InProgress != "" {
+		progressing = "True"
+	}
+
+	status := map[string]interface{}{
+		"desired": map[string]interface{}{"version": desired},
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Available", "status": "True"},
+			map[string]interface{}{"type": "Progressing", "status": progressing},
+		},
+	}
+	if history != nil {
+		status["history"] = history
+	}
+	if available != nil {
+		status["availableUpdates"] = available
+	}
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "ClusterVersion",
+		"metadata":   map[string]interface{}{"name": "version"},
+		"spec":       map[string]interface{}{"clusterID": "fake-cluster-id-0001"},
+		"status":     status,
+	}}
+}
+
+// UpgradeOutcome is what the four openshift-upgrade policies decide for one
+// case.
+type UpgradeOutcome struct {
+	Channel       string // spec.channel the channel policy enforces; "" when it asserts nothing
+	Allowed       bool   // the allowed check is Compliant
+	Upstream      string // spec.upstream the upgrade policy asserts; "" when it asserts nothing
+	DesiredUpdate string // spec.desiredUpdate.version the upgrade policy asserts
+	Reached       bool   // the status check is Compliant
+}
+
+// Upgrade names the branch the upgrade policy takes: "blocked" when it would
+// change the ClusterVersion but its dependency on the allowed check holds it
+// back, "desiredUpdate <version>" when it starts an upgrade, "upstream only"
+// while one is already in flight, and "no-op" when it asserts nothing.
+func (o UpgradeOutcome) Upgrade() string {
+	switch {
+	case o.Upstream == "":
+		return "no-op"
+	case !o.Allowed:
+		return "blocked"
+	case o.DesiredUpdate != "":
+		return "desiredUpdate " + o.DesiredUpdate
+	default:
+		return "upstream only"
+	}
+}
+
+func (o UpgradeOutcome) String() string {
+	channel := o.Channel
+	if channel == "" {
+		channel = "unset"
+	}
+	return fmt.Sprintf("channel=%s allowed=%s upgrade=%s status=%s",
+		channel, compliance(o.Allowed), o.Upgrade(), compliance(o.Reached))
+}
+
+func compliance(ok bool) string {
+	if ok {
+		return "Compliant"
+	}
+	return "NonCompliant"
+}
+
+// EvaluateUpgrade resolves every openshift-upgrade policy in policyDir for c,
+// without kustomize: each manifest's object-templates-raw is wrapped in a
+// ConfigurationPolicy the way PolicyGenerator wraps it, resolved on the hub
+// with the case's labels, then on the spoke against the case's ClusterVersion
+// with the config-policy-controller's contexts (see ResolveSpokePolicies).
+// The resolved object templates are read back into an UpgradeOutcome.
+func EvaluateUpgrade(policyDir string, c UpgradeCase) (UpgradeOutcome, error) {
+	raw, err := upgradePolicies(policyDir)
+	if err != nil {
+		return UpgradeOutcome{}, err
+	}
+
+	hub, err := NewResolver(nil)
+	if err != nil {
+		return UpgradeOutcome{}, err
+	}
+	res := hub.ResolvePolicy(raw, HubContext{ManagedClusterName: "upgrade-cluster", ManagedClusterLabels: c.labels()})
+	if len(res.Errors) > 0 {
+		return UpgradeOutcome{}, fmt.Errorf("%s: hub templates: %s", c.Name, strings.Join(res.Errors, "; "))
+	}
+	spoke, err := NewSpokeResolver([]unstructured.Unstructured{c.ClusterVersion()})
+	if err != nil {
+		return UpgradeOutcome{}, err
+	}
+	res = spoke.ResolveSpokePolicies(res.Resolved)
+	if len(res.Errors) > 0 {
+		return UpgradeOutcome{}, fmt.Errorf("%s: spoke templates: %s", c.Name, strings.Join(res.Errors, "; "))
+	}
+
+	templates := map[string][]objectTemplate{}
+	for _, doc := range splitYAMLDocuments(res.Resolved) {
+		var pol struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				PolicyTemplates []struct {
+					ObjectDefinition struct {
+						Spec struct {
+							Raw string `json:"object-templates-raw"`
+						} `json:"spec"`
+					} `json:"objectDefinition"`
+				} `json:"policy-templates"`
+			} `json:"spec"`
+		}
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		if err := sigsyaml.Unmarshal([]byte(doc), &pol); err != nil {
+			return UpgradeOutcome{}, fmt.Errorf("%s: parse resolved policy: %w", c.Name, err)
+		}
+		for _, pt := range pol.Spec.PolicyTemplates {
+			var ots []objectTemplate
+			if err := sigsyaml.Unmarshal([]byte(pt.ObjectDefinition.Spec.Raw), &ots); err != nil {
+				return UpgradeOutcome{}, fmt.Errorf("%s: %s: resolved object-templates-raw is not a list of object templates: %w",
+					c.Name, pol.Metadata.Name, err)
+			}
+			templates[pol.Metadata.Name] = append(templates[pol.Metadata.Name], ots...)
+		}
+	}
+
+	// The inform checks force NonCompliant with a mustnothave on the
+	// ClusterVersion, which always exists; anything else they leave Compliant.
+	nonCompliant := func(policy string) bool {
+		for _, ot := range templates[policy] {
+			if ot.ComplianceType == "mustnothave" && ot.ObjectDefinition.Kind == "ClusterVersion" {
+				return true
+			}
+		}
+		return false
+	}
+	var o UpgradeOutcome
+	for _, ot := range templates[UpgradeChannelPolicy] {
+		if ot.ComplianceType == "musthave" && ot.ObjectDefinition.Spec.Channel != "" {
+			o.Channel = ot.ObjectDefinition.Spec.Channel
+		}
+	}
+	for _, ot := range templates[UpgradePolicy] {
+		if ot.ComplianceType == "musthave" {
+			o.Upstream = ot.ObjectDefinition.Spec.Upstream
+			o.DesiredUpdate = ot.ObjectDefinition.Spec.DesiredUpdate.Version
+		}
+	}
+	o.Allowed = !nonCompliant(UpgradeAllowedPolicy)
+	o.Reached = !nonCompliant(UpgradeStatusPolicy)
+	return o, nil
+}
+
+// objectTemplate is the part of a resolved ClusterVersion object template the
+// matrix reads.
+type objectTemplate struct {
+	ComplianceType   string `json:"complianceType"`
+	ObjectDefinition struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Channel       string `json:"channel"`
+			Upstream      string `json:"upstream"`
+			DesiredUpdate struct {
+				Version string `json:"version"`
+			} `json:"desiredUpdate"`
+		} `json:"spec"`
+	} `json:"objectDefinition"`
+}
+
+// upgradePolicies reads policyDir's policy-generator-config.yaml and returns
+// one Policy per policy it lists, carrying its manifests' object-templates-raw.
+// Each of the four openshift-upgrade policies must be present, so a rename
+// fails here instead of silently emptying the matrix.
+func upgradePolicies(policyDir string) (string, error) {
+	cfgPath := filepath.Join(policyDir, "policy-generator-config.yaml")
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		return "", err
+	}
+	var cfg struct {
+		Policies []struct {
+			Name      string `json:"name"`
+			Manifests []struct {
+				Path string `json:"path"`
+			} `json:"manifests"`
+		} `json:"policies"`
+	}
+	if err := sigsyaml.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("%s: %w", cfgPath, err)
+	}
+
+	found := map[string]bool{}
+	var docs []string
+	for _, p := range cfg.Policies {
+		var policyTemplates []interface{}
+		for _, m := range p.Manifests {
+			path := filepath.Join(policyDir, m.Path)
+			mdata, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			var manifest struct {
+				Raw string `json:"object-templates-raw"`
+			}
+			if err := sigsyaml.Unmarshal(mdata, &manifest); err != nil {
+				return "", fmt.Errorf("%s: %w", path, err)
+			}
+			if manifest.Raw == "" {
+				return "", fmt.Errorf("%s: no object-templates-raw", path)
+			}
+			policyTemplates = append(policyTemplates, map[string]interface{}{
+				"objectDefinition": map[string]interface{}{
+					"apiVersion": "policy.open-cluster-management.io/v1",
+					"kind":       "ConfigurationPolicy",
+					"metadata":   map[string]interface{}{"name": p.Name},
+					"spec":       map[string]interface{}{"object-templates-raw": manifest.Raw},
+				},
+			})
+		}
+		doc, err := sigsyaml.Marshal(map[string]interface{}{
+			"apiVersion": "policy.open-cluster-management.io/v1",
+			"kind":       "Policy",
+			"metadata":   map[string]interface{}{"name": p.Name, "namespace": "policies-autoshift"},
+			"spec":       map[string]interface{}{"policy-templates": policyTemplates},
+		})
+		if err != nil {
+			return "", err
+		}
+		found[p.Name] = true
+		docs = append(docs, string(doc))
+	}
+	for _, name := range []string{UpgradeChannelPolicy, UpgradeAllowedPolicy, UpgradePolicy, UpgradeStatusPolicy} {
+		if !found[name] {
+			return "", fmt.Errorf("%s: policy %s not found", cfgPath, name)
+		}
+	}
+	return joinYAMLDocuments(docs), nil
+}
@@ -0,0 +1,71 @@
+package resolver
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestEvaluateUpgrade_Matrix resolves policies/stable/openshift-upgrade for
+// every upgrade edge case and checks which branch each policy takes. The
+// ClusterVersion status is what the spoke's lookup returns at that point in the
+// upgrade; see docs/ocp-upgrade.md for the sequence.
+func TestEvaluateUpgrade_Matrix(t *testing.T) {
+	dir := filepath.Join(repoRoot(t), "policies", "stable", "openshift-upgrade")
+	cases := []struct {
+		UpgradeCase
+		want string
+	}{
+		{UpgradeCase{Name: "no target", Current: "4.22.5", Available: []string{"4.22.8"}},
+			"channel=unset allowed=Compliant upgrade=no-op status=Compliant"},
+		{UpgradeCase{Name: "z-stream available", Target: "4.22.8", Channel: "stable-4.22", Current: "4.22.5", Available: []string{"4.22.8"}},
+			"channel=stable-4.22 allowed=Compliant upgrade=desiredUpdate 4.22.8 status=NonCompliant"},
+		{UpgradeCase{Name: "target unavailable", Target: "4.22.9", Channel: "stable-4.22", Current: "4.22.5", Available: []string{"4.22.8"}},
+			"channel=stable-4.22 allowed=NonCompliant upgrade=blocked status=NonCompliant"},
+		{UpgradeCase{Name: "target not x.y.z", Target: "4.22", Channel: "stable-4.22", Current: "4.22.5", Available: []string{"4.22.8"}},
+			"channel=stable-4.22 allowed=NonCompliant upgrade=no-op status=NonCompliant"},
+		// The channel policy switches to stable-4.23 first; until the CVO
+		// recomputes availableUpdates for it the target is not offered.
+		{UpgradeCase{Name: "y-stream before channel switch", Target: "4.23.2", Channel: "stable-4.23", Current: "4.22.5", Available: []string{"4.22.8"}},
+			"channel=stable-4.23 allowed=NonCompliant upgrade=blocked status=NonCompliant"},
+		{UpgradeCase{Name: "y-stream after channel switch", Target: "4.23.2", Channel: "stable-4.23", Current: "4.22.5", Available: []string{"4.22.8", "4.23.2"}},
+			"channel=stable-4.23 allowed=Compliant upgrade=desiredUpdate 4.23.2 status=NonCompliant"},
+		// availableUpdates empties while the CVO upgrades; status.desired keeps
+		// the target allowed, and the upgrade policy leaves desiredUpdate alone.
+		{UpgradeCase{Name: "in progress", Target: "4.22.8", Channel: "stable-4.22", Current: "4.22.5", InProgress: "4.22.8"},
+			"channel=stable-4.22 allowed=Compliant upgrade=upstream only status=NonCompliant"},
+		{UpgradeCase{Name: "in progress toward another version", Target: "4.22.8", Channel: "stable-4.22", Current: "4.22.5", InProgress: "4.22.7", Available: []string{"4.22.8"}},
+			"channel=stable-4.22 allowed=Compliant upgrade=upstream only status=NonCompliant"},
+		{UpgradeCase{Name: "completed", Target: "4.22.8", Channel: "stable-4.22", Current: "4.22.8"},
+			"channel=stable-4.22 allowed=Compliant upgrade=no-op status=Compliant"},
+		{UpgradeCase{Name: "past target", Target: "4.22.8", Channel: "stable-4.22", Current: "4.22.10", Available: []string{"4.22.11"}},
+			"channel=stable-4.22 allowed=Compliant upgrade=no-op status=Compliant"},
+		{UpgradeCase{Name: "no completed history", Target: "4.22.8", Current: "", InProgress: "4.22.5"},
+			"channel=unset allowed=NonCompliant upgrade=blocked status=NonCompliant"},
+	}
+	for _, tc := range cases {
+		got, err := EvaluateUpgrade(dir, tc.UpgradeCase)
+		if err != nil {
+			t.Errorf("%s: %v", tc.Name, err)
+			continue
+		}
+		if got.String() != tc.want {
+			t.Errorf("%s:\n  got  %s\n  want %s", tc.Name, got, tc.want)
+		}
+	}
+}
+
+func TestEvaluateUpgrade_RenamedPolicy(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFile(t, dir, "policy-generator-config.yaml", `
+policies:
+  - name: policy-openshift-upgrade-channel
+    manifests:
+      - path: channel.yaml
+`)
+	mustWriteFile(t, dir, "channel.yaml", "object-templates-raw: |\n  []\n")
+	_, err := EvaluateUpgrade(dir, UpgradeCase{Name: "renamed"})
+	if err == nil || !strings.Contains(err.Error(), "policy policy-openshift-upgrade-allowed not found") {
+		t.Errorf("err = %v, want the missing allowed check named", err)
+	}
+}
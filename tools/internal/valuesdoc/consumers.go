@@ -0,0 +1,95 @@
+package valuesdoc
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/configkeys"
+	"github.com/auto-shift/autoshiftv2/tools/internal/labels"
+)
+
+// helmEscapeRe matches a Helm action that only prints a string literal, which
+// is how a Helm policy escapes its ACM delimiters: {{ "{{hub" }}, {{ "}}" }}.
+var helmEscapeRe = regexp.MustCompile("\\{\\{-?\\s*(?:\"([^\"]*)\"|`([^`]*)`)\\s*-?\\}\\}")
+
+// unescapeHelm replaces each string-literal action in src with the string, so
+// a Helm policy's ACM templates read as they do once rendered.
+func unescapeHelm(src string) string {
+	return helmEscapeRe.ReplaceAllStringFunc(src, func(m string) string {
+		sub := helmEscapeRe.FindStringSubmatch(m)
+		return sub[1] + sub[2]
+	})
+}
+
+// ScanConsumers finds, for each key in ref, the policies under policiesRoot
+// whose sources read it. The .yaml and .tpl files of each policy directory are
+// scanned as one document, Helm escapes removed, with the label and config
+// read scanners the pipeline applies to rendered output — so the reference
+// needs neither helm nor kustomize.
+func ScanConsumers(policiesRoot string, ref *Reference) (Consumers, error) {
+	declared := make(map[string]bool, len(ref.Labels))
+	for _, e := range ref.Labels {
+		declared[e.Key] = true
+	}
+	consumers := Consumers{Labels: map[string][]string{}, Config: map[string][]string{}}
+	tiers, err := os.ReadDir(policiesRoot)
+	if err != nil {
+		return consumers, err
+	}
+	for _, tier := range tiers {
+		if !tier.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(policiesRoot, tier.Name()))
+		if err != nil {
+			return consumers, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			policy := tier.Name() + "/" + e.Name()
+			src, err := policySources(filepath.Join(policiesRoot, policy))
+			if err != nil {
+				return consumers, err
+			}
+			for _, key := range labels.ScanReads(src, declared) {
+				consumers.Labels[key] = append(consumers.Labels[key], policy)
+			}
+			for _, key := range configkeys.ScanReads(src) {
+				consumers.Config[key] = append(consumers.Config[key], policy)
+			}
+		}
+	}
+	for _, m := range []map[string][]string{consumers.Labels, consumers.Config} {
+		for key := range m {
+			sort.Strings(m[key])
+		}
+	}
+	return consumers, nil
+}
+
+// policySources concatenates the .yaml and .tpl files under dir, in path
+// order, with Helm escapes removed.
+func policySources(dir string) (string, error) {
+	var b strings.Builder
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if ext := filepath.Ext(path); ext != ".yaml" && ext != ".tpl" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		b.WriteString(unescapeHelm(string(data)))
+		b.WriteString("\n---\n")
+		return nil
+	})
+	return b.String(), err
+}
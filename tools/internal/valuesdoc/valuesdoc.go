@@ -0,0 +1,493 @@
+// Package valuesdoc generates the label and config key reference in
+// docs/values-reference.md from the _example*.yaml files.
+//
+// The examples are walked as yaml.v3 nodes so the comments that document each
+// key survive: the banner above a group of labels becomes its section, the
+// comment above or beside a key its description. Which policies consume each
+// key comes from scanning each policy's templates (ScanConsumers), so the page
+// only changes when the examples or the policies do.
+package valuesdoc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DocFile is the reference page, relative to the repo root.
+const DocFile = "docs/values-reference.md"
+
+// The generated reference sits between these markers; everything outside them
+// is hand-written and preserved.
+const (
+	BeginMarker = "<!-- BEGIN GENERATED: values reference. Do not edit; run `make values-reference`. -->"
+	EndMarker   = "<!-- END GENERATED: values reference -->"
+)
+
+// Entry documents one label or top-level config key.
+type Entry struct {
+	Key string
+	// Example is the value from the first example declaring the key; maps and
+	// lists are summarised rather than reproduced.
+	Example string
+	// Section is the title of the banner the key sits under. Labels inherit
+	// the last banner in their block; config keys only carry their own.
+	Section string
+	// Description is the comment above and beside the key, banners removed.
+	Description string
+	// Files are the declaring example files, relative to the values root.
+	Files []string
+}
+
+// Section is one banner from the examples: its first line is the title, any
+// further lines a note shown under it.
+type Section struct {
+	Title string
+	Note  string
+}
+
+// Reference is every documented key, in the order the examples declare them.
+type Reference struct {
+	Labels   []*Entry
+	Config   []*Entry
+	Sections []Section
+}
+
+// Consumers maps each key to the policies (e.g. "stable/metallb") that read
+// it, see ScanConsumers.
+type Consumers struct {
+	Labels map[string][]string
+	Config map[string][]string
+}
+
+// Collect walks every _example*.yaml under valuesRoot. Files under clustersets/
+// are read first so sections follow the main catalog's order; a key declared
+// again later only adds its file.
+func Collect(valuesRoot string) (*Reference, error) {
+	var files []string
+	err := filepath.Walk(valuesRoot, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name := info.Name()
+		if strings.HasPrefix(name, "_example") && filepath.Ext(name) == ".yaml" {
+			rel, err := filepath.Rel(valuesRoot, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		ci, cj := strings.HasPrefix(files[i], "clustersets/"), strings.HasPrefix(files[j], "clustersets/")
+		if ci != cj {
+			return ci
+		}
+		return files[i] < files[j]
+	})
+
+	c := &collector{ref: &Reference{}, labels: map[string]*Entry{}, config: map[string]*Entry{}, sections: map[string]bool{}}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(valuesRoot, f))
+		if err != nil {
+			return nil, err
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", f, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		c.file, c.lines = f, strings.Split(string(data), "\n")
+		walk(doc.Content[0], c.block)
+	}
+	return c.ref, nil
+}
+
+// walk finds every labels: and config: mapping and hands it to visit. Like the
+// label and config key extractors, it does not descend into those blocks.
+func walk(n *yaml.Node, visit func(block string, m *yaml.Node)) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if v.Kind != yaml.MappingNode {
+			continue
+		}
+		if k.Value == "labels" || k.Value == "config" {
+			visit(k.Value, v)
+			continue
+		}
+		walk(v, visit)
+	}
+}
+
+type collector struct {
+	ref            *Reference
+	labels, config map[string]*Entry
+	sections       map[string]bool
+	file           string
+	lines          []string
+}
+
+// block records the keys of one labels: or config: mapping.
+func (c *collector) block(kind string, m *yaml.Node) {
+	section := ""
+	var prev *Entry
+	prevLine := 0
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k, v := m.Content[i], m.Content[i+1]
+		cont, head := c.headComment(k, prevLine)
+		if prev != nil && cont != "" && prev.Files[0] == c.file {
+			prev.Description = strings.TrimSpace(prev.Description + " " + cont)
+		}
+		prevLine = 0
+		if v.Kind == yaml.ScalarNode {
+			prevLine = k.Line
+		}
+		banner, note, desc := splitComment(head)
+		if other := namedKey(desc); other != "" && other != k.Value {
+			// Written for a commented-out key, not this one.
+			desc = ""
+		}
+		if kind == "config" {
+			section = ""
+			if note != "" {
+				desc = strings.TrimSpace(note + " " + desc)
+			}
+		}
+		if banner != "" {
+			section = banner
+			if kind == "labels" && !c.sections[banner] {
+				c.sections[banner] = true
+				c.ref.Sections = append(c.ref.Sections, Section{Title: banner, Note: note})
+			}
+		}
+		if lc := commentText(v.LineComment); lc != "" {
+			desc = strings.TrimSpace(desc + " " + lc)
+		} else if lc := commentText(k.LineComment); lc != "" {
+			desc = strings.TrimSpace(desc + " " + lc)
+		}
+
+		seen, list := c.labels, &c.ref.Labels
+		if kind == "config" {
+			seen, list = c.config, &c.ref.Config
+		}
+		e, ok := seen[k.Value]
+		if !ok {
+			e = &Entry{Key: k.Value, Example: summarize(v), Section: section, Description: desc}
+			seen[k.Value] = e
+			*list = append(*list, e)
+		} else if e.Description == "" {
+			e.Description = desc
+		}
+		if !contains(e.Files, c.file) {
+			e.Files = append(e.Files, c.file)
+		}
+		prev = e
+	}
+}
+
+// headComment reads the comment lines directly above key k from the source.
+// When they start right below a scalar key on prevLine, the leading lines
+// indented deeper than k continue that key's line comment (yaml.v3 would hand
+// them to k) and are returned separately as cont.
+func (c *collector) headComment(k *yaml.Node, prevLine int) (cont, head string) {
+	start := k.Line - 1 // index of the key's own line
+	for start > 0 {
+		t := strings.TrimSpace(c.lines[start-1])
+		if t != "" && !strings.HasPrefix(t, "#") {
+			break
+		}
+		start--
+	}
+	var contLines, headLines []string
+	for _, line := range c.lines[start : k.Line-1] {
+		t := strings.TrimSpace(line)
+		if t == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if start == prevLine && len(headLines) == 0 && indent > k.Column-1 {
+			contLines = append(contLines, t)
+			continue
+		}
+		headLines = append(headLines, t)
+	}
+	return commentText(strings.Join(contLines, "\n")), strings.Join(headLines, "\n")
+}
+
+// namedKeyRe matches a description that opens on a key: either the key itself
+// commented out ("# quay-db-mode: external") or a colon naming it in the first
+// sentence ("DERIVED — do not set: cluster-type (...)").
+var namedKeyRe = regexp.MustCompile(`^(?:([a-z][a-zA-Z0-9-]*):(?:\s|$)|[^.]*?:\s+([a-z][a-z0-9]*(?:-[a-z0-9]+)+)\b)`)
+
+// namedKey returns the key a description opens on, or "".
+func namedKey(desc string) string {
+	m := namedKeyRe.FindStringSubmatch(desc)
+	if m == nil {
+		return ""
+	}
+	return m[1] + m[2]
+}
+
+// splitComment separates a head comment into the banner between ===== rule
+// lines (title and note) and the description after the last rule.
+func splitComment(c string) (title, note, desc string) {
+	var bannerLines, descLines []string
+	rules := 0
+	for _, line := range strings.Split(c, "\n") {
+		text := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if isRule(text) {
+			rules++
+			descLines = nil
+			if rules%2 == 1 {
+				// A new banner opens; only the last one above a key counts.
+				bannerLines = nil
+			}
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		if rules%2 == 1 {
+			bannerLines = append(bannerLines, text)
+		} else {
+			descLines = append(descLines, text)
+		}
+	}
+	if len(bannerLines) > 0 {
+		title, note = bannerLines[0], strings.Join(bannerLines[1:], " ")
+	}
+	return title, note, strings.Join(descLines, " ")
+}
+
+// commentText strips the comment markers from a comment, banners included.
+func commentText(c string) string {
+	_, _, desc := splitComment(c)
+	return desc
+}
+
+func isRule(s string) bool {
+	return len(s) >= 10 && strings.Trim(s, "=-") == ""
+}
+
+// summarize renders an example value for a table cell.
+func summarize(v *yaml.Node) string {
+	switch v.Kind {
+	case yaml.MappingNode:
+		var keys []string
+		for i := 0; i < len(v.Content); i += 2 {
+			keys = append(keys, v.Content[i].Value)
+		}
+		if len(keys) == 0 {
+			return "{}"
+		}
+		return "map: " + strings.Join(keys, ", ")
+	case yaml.SequenceNode:
+		return fmt.Sprintf("list (%d items)", len(v.Content))
+	case yaml.AliasNode:
+		return "*" + v.Value
+	}
+	if v.Tag == "!!null" {
+		return "null"
+	}
+	if v.Value == "" {
+		return "''"
+	}
+	return v.Value
+}
+
+// Render writes the generated section, markers included.
+func Render(ref *Reference, consumers Consumers) []byte {
+	var b bytes.Buffer
+	b.WriteString(BeginMarker + "\n")
+	// Descriptions are YAML comments, not prose written for the style guide.
+	b.WriteString("<!-- vale off -->\n\n")
+	b.WriteString("## Label and config key reference\n\n")
+	b.WriteString("Generated from the `_example*.yaml` files under `autoshift/values/` and the keys each policy's templates read. ")
+	b.WriteString("The policies come from scanning each policy's unrendered `.yaml` and `.tpl` sources with Helm escapes removed, not from rendering the charts, ")
+	b.WriteString("so a key read only through a name built at render time is not listed. ")
+	b.WriteString("The example is the value the example file sets; a key with no policies listed is declared but not read by any policy template.\n\n")
+
+	b.WriteString("### Labels\n\n")
+	b.WriteString("Set under `labels:` in a clusterset or cluster file. Each becomes the `autoshift.io/<label>` cluster label.\n")
+	bySection := map[string][]*Entry{}
+	for _, e := range ref.Labels {
+		bySection[e.Section] = append(bySection[e.Section], e)
+	}
+	sections := append([]Section{{Title: ""}}, ref.Sections...)
+	for _, sec := range sections {
+		entries := bySection[sec.Title]
+		if len(entries) == 0 {
+			continue
+		}
+		title := sec.Title
+		if title == "" {
+			title = "General"
+		}
+		fmt.Fprintf(&b, "\n#### %s\n\n", strings.TrimRight(title, ":"))
+		if sec.Note != "" {
+			b.WriteString(sec.Note + "\n\n")
+		}
+		writeHeader(&b, "Label")
+		for _, e := range entries {
+			writeRow(&b, e, consumers.Labels[e.Key])
+		}
+	}
+
+	b.WriteString("\n### Config keys\n\n")
+	b.WriteString("Top-level keys under `config:` in a clusterset or cluster file. Each is rendered into the cluster's rendered-config ConfigMap.\n\n")
+	writeHeader(&b, "Key")
+	config := append([]*Entry(nil), ref.Config...)
+	sort.SliceStable(config, func(i, j int) bool { return config[i].Key < config[j].Key })
+	for _, e := range config {
+		d := *e
+		if d.Description == "" {
+			d.Description = d.Section
+		} else if d.Section != "" && !strings.Contains(d.Description, d.Section) {
+			sep := ". "
+			if strings.ContainsAny(d.Section[len(d.Section)-1:], ".:;") {
+				sep = " "
+			}
+			d.Description = d.Section + sep + d.Description
+		}
+		writeRow(&b, &d, consumers.Config[e.Key])
+	}
+
+	b.WriteString("\n<!-- vale on -->\n")
+	b.WriteString(EndMarker + "\n")
+	return b.Bytes()
+}
+
+func writeHeader(b *bytes.Buffer, first string) {
+	fmt.Fprintf(b, "| %s | Example | Description | Declared in | Tier | Policies |\n", first)
+	b.WriteString("|---|---|---|---|---|---|\n")
+}
+
+func writeRow(b *bytes.Buffer, e *Entry, policies []string) {
+	policies = dedupe(policies)
+	tiers := map[string]bool{}
+	names := make([]string, len(policies))
+	for i, p := range policies {
+		if dir := path.Dir(p); dir != "." {
+			tiers[dir] = true
+		}
+		names[i] = "`" + p + "`"
+	}
+	tierList := make([]string, 0, len(tiers))
+	for t := range tiers {
+		tierList = append(tierList, t)
+	}
+	sort.Strings(tierList)
+
+	files := make([]string, len(e.Files))
+	for i, f := range e.Files {
+		files[i] = "`" + f + "`"
+	}
+	fmt.Fprintf(b, "| `%s` | %s | %s | %s | %s | %s |\n",
+		e.Key,
+		code(e.Example),
+		cell(e.Description),
+		strings.Join(files, "<br>"),
+		orDash(strings.Join(tierList, ", ")),
+		orDash(strings.Join(names, "<br>")))
+}
+
+// code wraps an example value in a code span, using a longer fence when the
+// value itself contains a backtick.
+func code(s string) string {
+	s = cell(s)
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Generate returns doc, the reference page of the repo at repoRoot, with its
+// generated section rebuilt from the examples under autoshift/values and the
+// policies under policies.
+func Generate(repoRoot string, doc []byte) ([]byte, error) {
+	ref, err := Collect(filepath.Join(repoRoot, "autoshift", "values"))
+	if err != nil {
+		return nil, err
+	}
+	consumers, err := ScanConsumers(filepath.Join(repoRoot, "policies"), ref)
+	if err != nil {
+		return nil, err
+	}
+	out, err := Splice(doc, Render(ref, consumers))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", DocFile, err)
+	}
+	return out, nil
+}
+
+// Splice replaces the generated section of doc with generated. A doc without
+// markers gets the section appended.
+func Splice(doc, generated []byte) ([]byte, error) {
+	begin := bytes.Index(doc, []byte(BeginMarker))
+	end := bytes.Index(doc, []byte(EndMarker))
+	switch {
+	case begin < 0 && end < 0:
+		out := append([]byte{}, bytes.TrimRight(doc, "\n")...)
+		out = append(out, "\n\n"...)
+		return append(out, generated...), nil
+	case begin < 0 || end < begin:
+		return nil, fmt.Errorf("generated section markers are missing or out of order")
+	}
+	end += len(EndMarker)
+	if end < len(doc) && doc[end] == '\n' {
+		end++
+	}
+	out := append([]byte{}, doc[:begin]...)
+	out = append(out, generated...)
+	return append(out, doc[end:]...), nil
+}
+
+func dedupe(s []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, x := range s {
+		if !seen[x] {
+			seen[x] = true
+			out = append(out, x)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
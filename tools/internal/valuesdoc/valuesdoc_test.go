@@ -0,0 +1,211 @@
+package valuesdoc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeExample(t *testing.T, dir, name, body string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	writeExample(t, dir, "clustersets/_example.yaml", `
+hubClusterSets:
+  hub:
+    config:
+      # GitOps teams
+      gitops:
+        teams: {dev: {}}
+    labels:
+      # =====================
+      # Core
+      # =====================
+      self-managed: 'true'   # registers the hub
+                             # as self-managed.
+      policy-namespace: ''
+
+      # =====================
+      # MetalLB
+      # Opt in per clusterset.
+      # =====================
+      # Enable MetalLB
+      metallb: 'true'
+      # DERIVED — do not set: cluster-type (hub or spoke).
+      metallb-quota-memory:
+`)
+	writeExample(t, dir, "clusters/_example.yaml", `
+clusters:
+  c1:
+    labels:
+      metallb: 'false'
+`)
+	writeExample(t, dir, "clustersets/hub.yaml", `
+hubClusterSets:
+  hub:
+    labels:
+      not-an-example: 'true'
+`)
+
+	ref, err := Collect(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byKey := map[string]*Entry{}
+	for _, e := range ref.Labels {
+		byKey[e.Key] = e
+	}
+	if len(ref.Labels) != 4 || byKey["not-an-example"] != nil {
+		t.Fatalf("labels = %d entries, want the 4 example labels", len(ref.Labels))
+	}
+
+	sm := byKey["self-managed"]
+	if sm.Section != "Core" || sm.Description != "registers the hub as self-managed." || sm.Example != "true" {
+		t.Errorf("self-managed = %+v", sm)
+	}
+	if pn := byKey["policy-namespace"]; pn.Description != "" || pn.Example != "''" {
+		t.Errorf("policy-namespace picked up %+v; the previous key's continuation must not leak", pn)
+	}
+	m := byKey["metallb"]
+	if m.Section != "MetalLB" || m.Description != "Enable MetalLB" {
+		t.Errorf("metallb = %+v", m)
+	}
+	if strings.Join(m.Files, ",") != "clustersets/_example.yaml,clusters/_example.yaml" {
+		t.Errorf("metallb files = %v", m.Files)
+	}
+	if q := byKey["metallb-quota-memory"]; q.Example != "null" || q.Section != "MetalLB" || q.Description != "" {
+		t.Errorf("metallb-quota-memory = %+v", q)
+	}
+
+	if len(ref.Sections) != 2 || ref.Sections[1].Note != "Opt in per clusterset." {
+		t.Errorf("sections = %+v", ref.Sections)
+	}
+	if len(ref.Config) != 1 || ref.Config[0].Key != "gitops" || ref.Config[0].Example != "map: teams" {
+		t.Errorf("config = %+v", ref.Config)
+	}
+}
+
+func TestRender(t *testing.T) {
+	ref := &Reference{
+		Labels: []*Entry{
+			{Key: "metallb", Example: "true", Section: "MetalLB", Description: "a | b", Files: []string{"clustersets/_example.yaml"}},
+			{Key: "unused", Example: "x", Section: "MetalLB", Files: []string{"clustersets/_example.yaml"}},
+		},
+		Config:   []*Entry{{Key: "gitops", Example: "map: teams", Section: "GitOps:", Description: "teams", Files: []string{"clustersets/_example.yaml"}}},
+		Sections: []Section{{Title: "MetalLB", Note: "Opt in."}},
+	}
+	out := string(Render(ref, Consumers{
+		Labels: map[string][]string{"metallb": {"stable/metallb", "stable/metallb", "community/x"}},
+		Config: map[string][]string{"gitops": {"stable/gitops"}},
+	}))
+
+	for _, want := range []string{
+		BeginMarker,
+		"#### MetalLB\n\nOpt in.\n",
+		"| `metallb` | `true` | a \\| b | `clustersets/_example.yaml` | community, stable | `community/x`<br>`stable/metallb` |",
+		"| `unused` | `x` |  | `clustersets/_example.yaml` | - | - |",
+		"| `gitops` | `map: teams` | GitOps: teams |",
+		EndMarker,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("render missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestSplice(t *testing.T) {
+	gen := []byte(BeginMarker + "\nnew\n" + EndMarker + "\n")
+
+	out, err := Splice([]byte("# Doc\n\nhand-written\n"), gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# Doc\n\nhand-written\n\n" + string(gen); string(out) != want {
+		t.Errorf("append:\n%s", out)
+	}
+
+	doc := "# Doc\n" + BeginMarker + "\nold\n" + EndMarker + "\ntail\n"
+	out, err = Splice([]byte(doc), gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# Doc\n" + string(gen) + "tail\n"; string(out) != want {
+		t.Errorf("replace:\n%s", out)
+	}
+
+	if _, err := Splice([]byte(EndMarker+"\n"+BeginMarker), gen); err == nil {
+		t.Error("markers out of order: want error")
+	}
+}
+
+func TestScanConsumers(t *testing.T) {
+	dir := t.TempDir()
+	writeExample(t, dir, "stable/metallb/templates/policy.yaml", `metadata:
+  name: '{{ "{{hub" }} index .ManagedClusterLabels "autoshift.io/metallb-ippool-1" {{ "hub}}" }}'
+data: '{{ "{{hub-" }} $config := (fromConfigMap "" "rendered-config" "config" | fromYaml) {{ "hub}}" }}{{ "{{hub" }} index $config "metallb" {{ "hub}}" }}'
+`)
+	writeExample(t, dir, "stable/gitops/policy-generator-config.yaml", `placement:
+  labelSelector:
+    matchExpressions:
+    - key: autoshift.io/gitops
+      operator: Exists
+`)
+	ref := &Reference{Labels: []*Entry{{Key: "metallb-ippool-1"}, {Key: "gitops"}}}
+	got, err := ScanConsumers(dir, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := got.Labels["metallb-ippool-1"]; len(l) != 1 || l[0] != "stable/metallb" {
+		t.Errorf("metallb-ippool-1 read by %v, want [stable/metallb]", l)
+	}
+	if l := got.Labels["gitops"]; len(l) != 1 || l[0] != "stable/gitops" {
+		t.Errorf("gitops read by %v, want [stable/gitops]", l)
+	}
+	if c := got.Config["metallb"]; len(c) != 1 || c[0] != "stable/metallb" {
+		t.Errorf("config metallb read by %v, want [stable/metallb]", c)
+	}
+}
+
+// TestReference_UpToDate fails when the generated section of
+// docs/values-reference.md no longer matches the examples and the policies,
+// i.e. someone changed either without regenerating the page.
+func TestReference_UpToDate(t *testing.T) {
+	root := repoRoot(t)
+	current, err := os.ReadFile(filepath.Join(root, DocFile))
+	if err != nil {
+		t.Fatalf("reading %s: %v", DocFile, err)
+	}
+	want, err := Generate(root, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != string(want) {
+		t.Errorf("%s is out of date — run `go run ./cmd/values-reference` from tools/", DocFile)
+	}
+}
+
+func repoRoot(t *testing.T) string {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		if _, err := os.Stat(filepath.Join(dir, "policies")); err == nil {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+	t.Fatal("could not locate repository root")
+	return ""
+}
@@ -0,0 +1,410 @@
+package valueslint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/valuesschema"
+)
+
+// Consistency finding kinds. Helm's validators (_validate-naming.tpl,
+// _validate-clustersets.tpl) only see the files passed to one render; these
+// need the whole values tree.
+const (
+	KindDuplicateCluster    = "duplicate cluster"
+	KindUnknownClusterSet   = "unknown clusterset"
+	KindDuplicateClusterSet = "duplicate clusterset"
+	KindClusterSetCollision = "clusterset name collision"
+)
+
+// ClusterSetSuffix mirrors autoshift.clusterSetSuffix in
+// autoshift/templates/_validate-naming.tpl and the versionTag handling in the
+// cluster-set-assignment policy: "-" plus the tag with "." and "/" replaced by
+// "-", lowercased.
+func ClusterSetSuffix(tag string) string {
+	if tag == "" {
+		return ""
+	}
+	return "-" + strings.ToLower(strings.NewReplacer(".", "-", "/", "-").Replace(tag))
+}
+
+// pos is where a value is defined.
+type pos struct {
+	file string
+	line int
+}
+
+func (p pos) String() string { return fmt.Sprintf("%s:%d", p.file, p.line) }
+
+// clusterSetDef is one clusterset entry in one file.
+type clusterSetDef struct {
+	bucket      string
+	key         string
+	selfManaged bool
+	at          pos
+}
+
+// clusterDef is one clusters: entry in one file.
+type clusterDef struct {
+	name       string
+	config     *yaml.Node
+	clusterSet string
+	versionTag string
+	at         pos
+	tagAt      pos
+}
+
+// Consistency checks the values tree under repoRoot as a whole:
+//
+//   - a cluster defined in several clusters/*.yaml files with different
+//     config (Helm merges whichever files a deployment lists, so the result
+//     depends on file order);
+//   - a cluster whose config.clusterSet names no clusterset in any file;
+//   - a clusterset key defined in several files. Self-managed hub profiles
+//     (hub.yaml, hub-minimal.yaml, ...) are alternatives and may share a key;
+//     every other definition is layered next to the others (hub1.yaml and
+//     hub2.yaml under a hub-of-hubs), so a shared key silently merges two
+//     clustersets into one;
+//   - two clusterset names that collide once versionedClusterSets appends
+//     the sanitized version suffix, for every tag the tree mentions.
+func Consistency(repoRoot string) ([]Finding, error) {
+	valuesRoot := filepath.Join(repoRoot, "autoshift", "values")
+	rel := func(p string) string {
+		r, err := filepath.Rel(repoRoot, p)
+		if err != nil {
+			return p
+		}
+		return filepath.ToSlash(r)
+	}
+
+	var sets []clusterSetDef
+	var clusters []clusterDef
+	var tags []tagDef
+	files, err := filepath.Glob(filepath.Join(valuesRoot, "*", "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	global := filepath.Join(valuesRoot, "global.yaml")
+	files = append(files, global)
+	sort.Strings(files)
+	for _, f := range files {
+		root, err := readNode(f)
+		if os.IsNotExist(err) && f == global {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if root == nil {
+			continue
+		}
+		name := rel(f)
+		if f == global {
+			tags = append(tags, globalTags(root, name)...)
+			continue
+		}
+		for _, bucket := range []string{"hubClusterSets", "managedClusterSets"} {
+			forEachEntry(root, bucket, func(k, v *yaml.Node) {
+				sm := mapValue(v, "labels")
+				selfManaged := sm != nil && scalarValue(sm, "self-managed") == "true"
+				sets = append(sets, clusterSetDef{bucket: bucket, key: k.Value, selfManaged: selfManaged, at: pos{name, k.Line}})
+			})
+		}
+		forEachEntry(root, "clusters", func(k, v *yaml.Node) {
+			c := clusterDef{name: k.Value, at: pos{name, k.Line}}
+			if cfg := mapValue(v, "config"); cfg != nil && cfg.Kind == yaml.MappingNode {
+				c.config = cfg
+				c.clusterSet = scalarValue(cfg, "clusterSet")
+				if t := mapKey(cfg, "versionTag"); t != nil {
+					c.versionTag = mapValue(cfg, "versionTag").Value
+					c.tagAt = pos{name, t.Line}
+				}
+			}
+			clusters = append(clusters, c)
+		})
+	}
+
+	var out []Finding
+	out = append(out, duplicateClusters(clusters)...)
+	out = append(out, unknownClusterSets(clusters, sets)...)
+	out = append(out, duplicateClusterSets(sets)...)
+	for _, c := range clusters {
+		if c.versionTag != "" {
+			tags = append(tags, tagDef{tag: c.versionTag, at: c.tagAt})
+		}
+	}
+	out = append(out, nameCollisions(sets, tags)...)
+	out = append(out, tagCollisions(tags)...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].File != out[j].File {
+			return out[i].File < out[j].File
+		}
+		return out[i].Line < out[j].Line
+	})
+	return out, nil
+}
+
+func duplicateClusters(clusters []clusterDef) []Finding {
+	var out []Finding
+	first := map[string]clusterDef{}
+	for _, c := range clusters {
+		prev, seen := first[c.name]
+		if !seen {
+			first[c.name] = c
+			continue
+		}
+		if prev.at.file == c.at.file || sameNode(prev.config, c.config) {
+			continue
+		}
+		out = append(out, Finding{
+			File: c.at.file, Line: c.at.line,
+			Path:    "clusters." + c.name,
+			Kind:    KindDuplicateCluster,
+			Message: fmt.Sprintf("also defined at %s with different config; the result depends on valueFiles order", prev.at),
+		})
+	}
+	return out
+}
+
+func unknownClusterSets(clusters []clusterDef, sets []clusterSetDef) []Finding {
+	known := map[string]bool{}
+	var keys []string
+	for _, s := range sets {
+		if !known[s.key] {
+			keys = append(keys, s.key)
+		}
+		known[s.key] = true
+	}
+	var out []Finding
+	for _, c := range clusters {
+		if c.clusterSet == "" || known[c.clusterSet] {
+			continue
+		}
+		msg := "no clusterset file defines it"
+		if best := valuesschema.Closest(c.clusterSet, keys); best != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", best)
+		}
+		out = append(out, Finding{
+			File: c.at.file, Line: c.at.line,
+			Path:    fmt.Sprintf("clusters.%s.config.clusterSet", c.name),
+			Kind:    KindUnknownClusterSet,
+			Message: fmt.Sprintf("%q: %s", c.clusterSet, msg),
+		})
+	}
+	return out
+}
+
+func duplicateClusterSets(sets []clusterSetDef) []Finding {
+	byKey := map[string][]clusterSetDef{}
+	var order []string
+	for _, s := range sets {
+		if byKey[s.key] == nil {
+			order = append(order, s.key)
+		}
+		byKey[s.key] = append(byKey[s.key], s)
+	}
+	var out []Finding
+	for _, key := range order {
+		defs := byKey[key]
+		if len(defs) < 2 {
+			continue
+		}
+		alternatives := true
+		for _, d := range defs {
+			if d.bucket != "hubClusterSets" || !d.selfManaged {
+				alternatives = false
+			}
+		}
+		if alternatives {
+			continue
+		}
+		for _, d := range defs[1:] {
+			out = append(out, Finding{
+				File: d.at.file, Line: d.at.line,
+				Path:    d.bucket + "." + key,
+				Kind:    KindDuplicateClusterSet,
+				Message: fmt.Sprintf("also defined at %s (%s); files deployed together merge into one clusterset", defs[0].at, defs[0].bucket),
+			})
+		}
+	}
+	return out
+}
+
+// tagDef is a version tag the tree mentions: global.yaml's branch/tag or OCI
+// version, or a cluster's config.versionTag.
+type tagDef struct {
+	tag string
+	at  pos
+}
+
+func globalTags(root *yaml.Node, file string) []tagDef {
+	var out []tagDef
+	for _, key := range []string{"autoshiftGitBranchTag", "autoshiftOciVersion"} {
+		if k := mapKey(root, key); k != nil {
+			if v := mapValue(root, key); v.Value != "" {
+				out = append(out, tagDef{tag: v.Value, at: pos{file, k.Line}})
+			}
+		}
+	}
+	return out
+}
+
+// nameCollisions reports distinct (clusterset, tag) pairs that produce the
+// same ManagedClusterSet name. The unversioned name (no tag) takes part too,
+// so a clusterset called hub-main collides with hub under branch main. Two
+// tags that sanitize to the same suffix collide for every clusterset and are
+// reported once, by tagCollisions.
+func nameCollisions(sets []clusterSetDef, tags []tagDef) []Finding {
+	type origin struct {
+		key, tag string
+		at       pos
+	}
+	keyAt := map[string]pos{}
+	var keys []string
+	for _, s := range sets {
+		if _, ok := keyAt[s.key]; !ok {
+			keyAt[s.key] = s.at
+			keys = append(keys, s.key)
+		}
+	}
+	byName := map[string][]origin{}
+	var names []string
+	add := func(o origin) {
+		name := o.key + ClusterSetSuffix(o.tag)
+		for _, prev := range byName[name] {
+			if prev.key == o.key && prev.tag == o.tag {
+				return
+			}
+		}
+		if byName[name] == nil {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], o)
+	}
+	for _, k := range keys {
+		add(origin{key: k, at: keyAt[k]})
+	}
+	for _, t := range tags {
+		for _, k := range keys {
+			add(origin{key: k, tag: t.tag, at: t.at})
+		}
+	}
+
+	var out []Finding
+	for _, name := range names {
+		origins := byName[name]
+		sameKey := true
+		for _, o := range origins {
+			sameKey = sameKey && o.key == origins[0].key
+		}
+		if len(origins) < 2 || sameKey {
+			continue
+		}
+		describe := make([]string, len(origins))
+		for i, o := range origins {
+			if o.tag == "" {
+				describe[i] = fmt.Sprintf("%q unversioned (%s)", o.key, o.at)
+			} else {
+				describe[i] = fmt.Sprintf("%q with tag %q (%s)", o.key, o.tag, o.at)
+			}
+		}
+		last := origins[len(origins)-1]
+		out = append(out, Finding{
+			File: last.at.file, Line: last.at.line,
+			Path:    name,
+			Kind:    KindClusterSetCollision,
+			Message: "ManagedClusterSet name produced by " + strings.Join(describe, " and "),
+		})
+	}
+	return out
+}
+
+// tagCollisions reports distinct version tags that sanitize to the same
+// suffix: both releases would claim the same clustersets.
+func tagCollisions(tags []tagDef) []Finding {
+	first := map[string]tagDef{}
+	reported := map[[2]string]bool{}
+	var out []Finding
+	for _, t := range tags {
+		suffix := ClusterSetSuffix(t.tag)
+		prev, seen := first[suffix]
+		if !seen {
+			first[suffix] = t
+			continue
+		}
+		pair := [2]string{prev.tag, t.tag}
+		if prev.tag == t.tag || reported[pair] {
+			continue
+		}
+		reported[pair] = true
+		out = append(out, Finding{
+			File: t.at.file, Line: t.at.line,
+			Path:    t.tag,
+			Kind:    KindClusterSetCollision,
+			Message: fmt.Sprintf("tag sanitizes to suffix %q, as does %q (%s); both releases would own the same clustersets", suffix, prev.tag, prev.at),
+		})
+	}
+	return out
+}
+
+func readNode(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+func forEachEntry(root *yaml.Node, bucket string, fn func(k, v *yaml.Node)) {
+	entries := mapValue(root, bucket)
+	if entries == nil || entries.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(entries.Content); i += 2 {
+		fn(entries.Content[i], entries.Content[i+1])
+	}
+}
+
+func mapKey(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i]
+		}
+	}
+	return nil
+}
+
+func scalarValue(n *yaml.Node, key string) string {
+	if v := mapValue(n, key); v != nil && v.Kind == yaml.ScalarNode {
+		return v.Value
+	}
+	return ""
+}
+
+// sameNode compares two YAML subtrees by value, ignoring comments, styles and
+// positions.
+func sameNode(a, b *yaml.Node) bool {
+	var av, bv interface{}
+	if a != nil {
+		_ = a.Decode(&av)
+	}
+	if b != nil {
+		_ = b.Decode(&bv)
+	}
+	return fmt.Sprint(av) == fmt.Sprint(bv)
+}
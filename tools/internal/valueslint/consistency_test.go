@@ -0,0 +1,93 @@
+package valueslint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConsistency(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "autoshift/values/global.yaml", "autoshiftGitBranchTag: release/1.0\n")
+	// Self-managed hub profiles are alternatives: sharing "hub" is fine.
+	writeFile(t, root, "autoshift/values/clustersets/hub.yaml", `
+hubClusterSets:
+  hub:
+    labels:
+      self-managed: 'true'
+`)
+	writeFile(t, root, "autoshift/values/clustersets/hub-minimal.yaml", `
+hubClusterSets:
+  hub:
+    labels:
+      self-managed: 'true'
+`)
+	writeFile(t, root, "autoshift/values/clustersets/hub1.yaml", `
+hubClusterSets:
+  hub1:
+    labels: {}
+`)
+	writeFile(t, root, "autoshift/values/clustersets/hub2.yaml", `
+hubClusterSets:
+  hub1:
+    labels: {}
+`)
+	writeFile(t, root, "autoshift/values/clustersets/managed.yaml", `
+managedClusterSets:
+  managed:
+    labels: {}
+  managed-release-1-0:
+    labels: {}
+`)
+	writeFile(t, root, "autoshift/values/clusters/a.yaml", `
+clusters:
+  c1:
+    config:
+      clusterSet: managed
+  c2:
+    config:
+      clusterSet: manged
+`)
+	writeFile(t, root, "autoshift/values/clusters/b.yaml", `
+clusters:
+  c1:
+    config:
+      clusterSet: managed
+      versionTag: '2.0'
+  c3:
+    config:
+      clusterSet: managed
+      versionTag: 2-0
+`)
+
+	findings, err := Consistency(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	want := []string{
+		`autoshift/values/clusters/a.yaml:6: clusters.c2.config.clusterSet: unknown clusterset: "manged": no clusterset file defines it (did you mean "managed"?)`,
+		`autoshift/values/clusters/b.yaml:3: clusters.c1: duplicate cluster: also defined at autoshift/values/clusters/a.yaml:3 with different config; the result depends on valueFiles order`,
+		`autoshift/values/clusters/b.yaml:10: 2-0: clusterset name collision: tag sanitizes to suffix "-2-0", as does "2.0" (autoshift/values/clusters/b.yaml:6); both releases would own the same clustersets`,
+		`autoshift/values/clustersets/hub2.yaml:3: hubClusterSets.hub1: duplicate clusterset: also defined at autoshift/values/clustersets/hub1.yaml:3 (hubClusterSets); files deployed together merge into one clusterset`,
+		`autoshift/values/global.yaml:1: managed-release-1-0: clusterset name collision: ManagedClusterSet name produced by "managed-release-1-0" unversioned (autoshift/values/clustersets/managed.yaml:5) and "managed" with tag "release/1.0" (autoshift/values/global.yaml:1)`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("findings:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestClusterSetSuffix(t *testing.T) {
+	for in, want := range map[string]string{
+		"":            "",
+		"main":        "-main",
+		"0.0.2":       "-0-0-2",
+		"Feature/ABC": "-feature-abc",
+	} {
+		if got := ClusterSetSuffix(in); got != want {
+			t.Errorf("ClusterSetSuffix(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Package valueslint checks the real values profiles (hub.yaml, managed.yaml,
+// sbx.yaml, ...) against the _example*.yaml files.
+//
+// The label contract and the config key checks only read the examples, and the
+// profiles are otherwise only rendered through Helm, which accepts any label
+// and reports schema violations without a line number. The lint reports label
+// keys no example declares, config paths the examples do not know, and values
+// whose type differs from the examples', each at its file and line.
+package valueslint
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/labels"
+	"github.com/auto-shift/autoshiftv2/tools/internal/valuesschema"
+)
+
+// AllowlistFile holds the label families profiles may extend, relative to the
+// repo root.
+const AllowlistFile = ".github/label-lint-allowlist.yaml"
+
+// Finding kinds.
+const (
+	KindUnknownLabel  = "unknown label"
+	KindUnknownConfig = "unknown config path"
+	KindUnknownField  = "unknown field"
+	KindType          = "type mismatch"
+	KindSchema        = "schema"
+)
+
+// Finding is one problem in a profile.
+type Finding struct {
+	File    string
+	Line    int
+	Path    string
+	Kind    string
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s: %s", f.File, f.Line, f.Path, f.Kind, f.Message)
+}
+
+// Linter compares profiles against what the examples declare.
+type Linter struct {
+	schema map[string]interface{}
+	// labels are the label keys the examples declare.
+	labels []string
+	known  map[string]bool
+	// families are the stems of numbered example labels (infra-nodes-zone for
+	// infra-nodes-zone-1): a profile may use any number in the family.
+	families map[string]bool
+	prefixes []string
+	// stamped are the labels AutoShift sets on a ManagedCluster itself (the
+	// allowlist's missing_ok), which no profile declares.
+	stamped map[string]bool
+}
+
+// New builds a linter from the chart schema, the labels declared in the
+// examples and the label prefixes profiles may extend freely.
+func New(schema map[string]interface{}, declared map[string]*labels.Declared, prefixes []string) *Linter {
+	l := &Linter{schema: schema, known: map[string]bool{}, families: map[string]bool{}, prefixes: prefixes}
+	for key := range declared {
+		l.labels = append(l.labels, key)
+		l.known[key] = true
+		if stem := numberedStem(key); stem != key {
+			l.families[stem] = true
+		}
+	}
+	sort.Strings(l.labels)
+	return l
+}
+
+// Load builds the linter for the repository at repoRoot.
+func Load(repoRoot string) (*Linter, error) {
+	schema, err := valuesschema.Build(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	declared, err := labels.ExtractDeclaredFromTree(filepath.Join(repoRoot, "autoshift", "values"), false)
+	if err != nil {
+		return nil, err
+	}
+	var prefixes []string
+	var stamped map[string]bool
+	allow, err := labels.LoadAllowlist(filepath.Join(repoRoot, AllowlistFile))
+	switch {
+	case err == nil:
+		prefixes, stamped = allow.ProfilePrefixes, allow.MissingOK
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	l := New(schema, declared, prefixes)
+	l.stamped = stamped
+	return l, nil
+}
+
+// Profiles returns the values files under valuesRoot that are not examples,
+// sorted.
+func Profiles(valuesRoot string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(valuesRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if filepath.Ext(name) == ".yaml" && !strings.HasPrefix(name, "_example") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// LintFile checks one values file. name is how findings refer to it.
+func (l *Linter) LintFile(path, name string) ([]Finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	var values interface{}
+	if err := root.Decode(&values); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", name, err)
+	}
+
+	var out []Finding
+	for _, v := range valuesschema.Violations(l.schema, l.schema, values) {
+		kind, msg := classify(v)
+		out = append(out, Finding{File: name, Line: lineOf(root, v.Path), Path: valuesschema.DottedPath(v.Path), Kind: kind, Message: msg})
+	}
+	out = append(out, l.lintLabels(root, name)...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Line < out[j].Line })
+	return out, nil
+}
+
+// LintTree checks every profile under the repo's autoshift/values directory.
+func (l *Linter) LintTree(repoRoot string) ([]Finding, error) {
+	files, err := Profiles(filepath.Join(repoRoot, "autoshift", "values"))
+	if err != nil {
+		return nil, err
+	}
+	var out []Finding
+	for _, f := range files {
+		rel, err := filepath.Rel(repoRoot, f)
+		if err != nil {
+			return nil, err
+		}
+		found, err := l.LintFile(f, filepath.ToSlash(rel))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, found...)
+	}
+	return out, nil
+}
+
+// lintLabels reports label keys under each entry that no example declares.
+// The chart schema accepts any label, so this is the only check on them.
+func (l *Linter) lintLabels(root *yaml.Node, name string) []Finding {
+	var out []Finding
+	for _, bucket := range valuesschema.EntryBuckets {
+		entries := mapValue(root, bucket)
+		if entries == nil || entries.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(entries.Content); i += 2 {
+			entry := entries.Content[i].Value
+			lbls := mapValue(entries.Content[i+1], "labels")
+			if lbls == nil || lbls.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(lbls.Content); j += 2 {
+				k := lbls.Content[j]
+				if l.knownLabel(k.Value) {
+					continue
+				}
+				msg := "no _example*.yaml file declares it"
+				if best := valuesschema.Closest(k.Value, l.labels); best != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", best)
+				}
+				out = append(out, Finding{
+					File:    name,
+					Line:    k.Line,
+					Path:    valuesschema.DottedPath([]string{bucket, entry, "labels", k.Value}),
+					Kind:    KindUnknownLabel,
+					Message: msg,
+				})
+			}
+		}
+	}
+	return out
+}
+
+func (l *Linter) knownLabel(key string) bool {
+	if l.known[key] {
+		return true
+	}
+	if stem := numberedStem(key); stem != key && l.families[stem] {
+		return true
+	}
+	for _, p := range l.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckClusterLabel checks key, an autoshift.io label without the prefix,
+// as a live ManagedCluster carries it: known when a profile may set it or
+// AutoShift stamps it. An unknown key comes with the closest declared one, or
+// "" when none is close.
+func (l *Linter) CheckClusterLabel(key string) (known bool, closest string) {
+	if l.knownLabel(key) || l.stamped[key] {
+		return true, ""
+	}
+	return false, valuesschema.Closest(key, l.labels)
+}
+
+// classify names a schema violation in the lint's terms.
+func classify(v valuesschema.Violation) (kind, msg string) {
+	switch v.Kind {
+	case valuesschema.KindUnknown:
+		// bucket.entry.config.<key>...: under config the examples define the
+		// shape; elsewhere it is a misspelt entry field such as lables.
+		hint := strings.TrimPrefix(v.Message, "unknown field")
+		if len(v.Path) > 3 && v.Path[2] == "config" {
+			return KindUnknownConfig, "no _example*.yaml file declares it" + hint
+		}
+		return KindUnknownField, "entries take only labels and config" + hint
+	case valuesschema.KindType:
+		switch {
+		case strings.Contains(v.Want, "object") && v.Got != "object":
+			return KindType, fmt.Sprintf("the examples give a map here, got %s", v.Got)
+		case v.Got == "object":
+			return KindType, fmt.Sprintf("the examples give %s here, got a map", v.Want)
+		}
+		return KindType, v.Message
+	}
+	return KindSchema, v.Message
+}
+
+// lineOf follows path through the node tree and returns the line of the
+// deepest element found: the key for a mapping, the item for a sequence.
+func lineOf(n *yaml.Node, path []string) int {
+	line := n.Line
+	for _, elem := range path {
+		var next *yaml.Node
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == elem {
+					line, next = n.Content[i].Line, n.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			var idx int
+			if _, err := fmt.Sscanf(elem, "[%d]", &idx); err == nil && idx < len(n.Content) {
+				next = n.Content[idx]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			return line
+		}
+		n = next
+	}
+	return line
+}
+
+func mapValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// numberedStem drops a trailing -<digits> segment, the same rule the pipeline
+// uses to match numbered labels (worker-nodes-zone-1 → worker-nodes-zone).
+func numberedStem(key string) string {
+	idx := strings.LastIndex(key, "-")
+	if idx < 0 || idx == len(key)-1 {
+		return key
+	}
+	for _, c := range key[idx+1:] {
+		if c < '0' || c > '9' {
+			return key
+		}
+	}
+	return key[:idx]
+}
@@ -0,0 +1,103 @@
+package valueslint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, root, rel, body string) {
+	t.Helper()
+	p := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLintTree(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, AllowlistFile, `
+missing_ok: []
+orphaned_ok: []
+profile_prefixes:
+  - team-
+`)
+	writeFile(t, root, "autoshift/values/clustersets/_example.yaml", `
+hubClusterSets:
+  hub:
+    labels:
+      metallb: 'true'
+      worker-nodes-zone-1: ''
+    config:
+      gitops:
+        teams:
+          dev: {ha: true}
+      mirror:
+        registry: r.example.com
+`)
+	writeFile(t, root, "autoshift/values/clustersets/hub.yaml", `
+hubClusterSets:
+  hub:
+    labels:
+      metallb: 'true'
+      metalb: 'true'
+      worker-nodes-zone-3: 'us-east-2c'
+      team-blue: 'hub'
+    config:
+      gitops:
+        teems: {}
+      mirror: r.example.com
+managedClusterSets:
+  spokes:
+    lables:
+      metallb: 'true'
+    config:
+      gitops:
+        teams: 'dev'
+      mirror:
+        registry: {host: r}
+`)
+
+	l, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := l.LintTree(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	want := []string{
+		`autoshift/values/clustersets/hub.yaml:6: hubClusterSets.hub.labels.metalb: unknown label: no _example*.yaml file declares it (did you mean "metallb"?)`,
+		`autoshift/values/clustersets/hub.yaml:11: hubClusterSets.hub.config.gitops.teems: unknown config path: no _example*.yaml file declares it (did you mean "teams"?)`,
+		`autoshift/values/clustersets/hub.yaml:12: hubClusterSets.hub.config.mirror: type mismatch: the examples give a map here, got string`,
+		`autoshift/values/clustersets/hub.yaml:15: managedClusterSets.spokes.lables: unknown field: entries take only labels and config (did you mean "labels"?)`,
+		`autoshift/values/clustersets/hub.yaml:19: managedClusterSets.spokes.config.gitops.teams: type mismatch: the examples give a map here, got string`,
+		`autoshift/values/clustersets/hub.yaml:21: managedClusterSets.spokes.config.mirror.registry: type mismatch: the examples give string here, got a map`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("findings:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestNumberedStem(t *testing.T) {
+	for in, want := range map[string]string{
+		"worker-nodes-zone-1": "worker-nodes-zone",
+		"metallb-bgp-12":      "metallb-bgp",
+		"nmstate":             "nmstate",
+		"zone-":               "zone-",
+		"acs-v2":              "acs-v2",
+	} {
+		if got := numberedStem(in); got != want {
+			t.Errorf("numberedStem(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
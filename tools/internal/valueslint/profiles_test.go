@@ -0,0 +1,57 @@
+//go:build integration
+
+package valueslint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// repoRoot walks up from the package directory to the repository root.
+func repoRoot(t *testing.T) string {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		if _, err := os.Stat(filepath.Join(dir, "policies")); err == nil {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+	t.Fatal("could not locate repository root")
+	return ""
+}
+
+// TestProfiles_MatchExamples lints every real values profile against the
+// examples. A finding is a typo in the profile, a label the examples should
+// declare, or a family prefix missing from the label lint allowlist.
+func TestProfiles_MatchExamples(t *testing.T) {
+	root := repoRoot(t)
+	l, err := Load(root)
+	if err != nil {
+		t.Fatalf("loading linter: %v", err)
+	}
+	findings, err := l.LintTree(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range findings {
+		t.Error(f)
+	}
+}
+
+// TestValues_Consistency runs the whole-tree checks Helm cannot make from the
+// files of a single render: duplicate clusters and clustersets across files,
+// clusterSet references to nothing, and versioned clusterset name collisions.
+func TestValues_Consistency(t *testing.T) {
+	findings, err := Consistency(repoRoot(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range findings {
+		t.Error(f)
+	}
+}
@@ -0,0 +1,275 @@
+package valuesschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/auto-shift/autoshiftv2/tools/internal/configkeys"
+)
+
+// Repository paths, relative to the repo root.
+const (
+	// RefinementsFile holds hand-written schema fragments per config key.
+	RefinementsFile = ".github/config-schema-refinements.yaml"
+	// ChartSchemaFile is the generated schema Helm validates values against.
+	ChartSchemaFile = "autoshift/values.schema.json"
+)
+
+// EntryBuckets are the top-level values keys whose entries carry labels and
+// config.
+var EntryBuckets = []string{"hubClusterSets", "managedClusterSets", "clusters"}
+
+// Examples holds the config samples collected from the _example*.yaml files.
+type Examples struct {
+	// Schemas maps each top-level config key to the merge of every example
+	// value declared for it.
+	Schemas map[string]*Schema
+	// Files maps each config key to the example files declaring it.
+	Files map[string][]string
+}
+
+// CollectExamples infers a schema for every key declared inside a config:
+// mapping in any _example*.yaml file under valuesRoot.
+func CollectExamples(valuesRoot string) (*Examples, error) {
+	ex := &Examples{Schemas: map[string]*Schema{}, Files: map[string][]string{}}
+	err := filepath.Walk(valuesRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name := filepath.Base(path)
+		if !strings.HasPrefix(name, "_example") || filepath.Ext(name) != ".yaml" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		for _, bucket := range EntryBuckets {
+			entries, _ := doc[bucket].(map[string]interface{})
+			for _, e := range entries {
+				entry, _ := e.(map[string]interface{})
+				config, _ := entry["config"].(map[string]interface{})
+				for key, v := range config {
+					ex.Schemas[key] = Merge(ex.Schemas[key], Infer(v))
+					if !containsString(ex.Files[key], name) {
+						ex.Files[key] = append(ex.Files[key], name)
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key := range ex.Files {
+		sort.Strings(ex.Files[key])
+	}
+	return ex, nil
+}
+
+// LoadRefinements reads the hand-written schema refinements: a map from config
+// key to a JSON Schema fragment deep-merged over the inferred definition. A
+// null value in a fragment removes the inferred keyword (typically required).
+// A missing file means no refinements.
+func LoadRefinements(path string) (map[string]map[string]interface{}, error) {
+	out := map[string]map[string]interface{}{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// keyedMarker is the refinement keyword that marks an object whose property
+// names are user-chosen (see Schema.Keyed). It is consumed by Definitions and
+// never reaches the generated schema.
+const keyedMarker = "x-keyed"
+
+// Definitions builds the schema of every config key: the inferred schema,
+// described with the examples it came from, with its refinement merged over
+// it. Refinements for keys no example declares become definitions of their
+// own, and openKeys (keys read by policies but documented nowhere) accept any
+// value so the chart schema does not reject them as unknown.
+func Definitions(ex *Examples, refinements map[string]map[string]interface{}, openKeys []string) map[string]map[string]interface{} {
+	defs := map[string]map[string]interface{}{}
+	for key, s := range ex.Schemas {
+		applyKeyed(s, refinements[key])
+		def := s.ToMap()
+		def["description"] = "Inferred from " + strings.Join(ex.Files[key], ", ") + "."
+		defs[key] = def
+	}
+	for _, key := range openKeys {
+		if _, ok := defs[key]; !ok {
+			defs[key] = map[string]interface{}{}
+		}
+	}
+	for key, ref := range refinements {
+		if defs[key] == nil {
+			defs[key] = map[string]interface{}{}
+		}
+		deepMerge(defs[key], stripKeyed(ref))
+	}
+	return defs
+}
+
+// applyKeyed walks a refinement fragment alongside the inferred schema and
+// collapses every object the fragment marks with x-keyed.
+func applyKeyed(s *Schema, ref map[string]interface{}) {
+	if s == nil || ref == nil {
+		return
+	}
+	if keyed, _ := ref[keyedMarker].(bool); keyed {
+		s.Keyed()
+	}
+	if props, ok := ref["properties"].(map[string]interface{}); ok {
+		for k, v := range props {
+			child, _ := v.(map[string]interface{})
+			applyKeyed(s.Properties[k], child)
+		}
+	}
+	if ap, ok := ref["additionalProperties"].(map[string]interface{}); ok {
+		applyKeyed(s.Values, ap)
+	}
+	if items, ok := ref["items"].(map[string]interface{}); ok {
+		applyKeyed(s.Items, items)
+	}
+}
+
+// stripKeyed returns a copy of a refinement fragment without x-keyed markers.
+func stripKeyed(ref map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(ref))
+	for k, v := range ref {
+		if k == keyedMarker {
+			continue
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			v = stripKeyed(m)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// deepMerge overlays src on dst: nested maps merge, null removes the keyword,
+// anything else replaces.
+func deepMerge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		sm, srcIsMap := v.(map[string]interface{})
+		dm, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			deepMerge(dm, sm)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// ChartSchema assembles the autoshift chart's values.schema.json. Only the
+// labels and config of clusterset and cluster entries are constrained; other
+// top-level values stay open so the schema never blocks a chart setting it
+// does not know about.
+func ChartSchema(defs map[string]map[string]interface{}) map[string]interface{} {
+	definitions := map[string]interface{}{}
+	configProps := map[string]interface{}{}
+	for key, def := range defs {
+		definitions["config."+key] = def
+		configProps[key] = map[string]interface{}{"$ref": "#/definitions/config." + key}
+	}
+	definitions["config"] = map[string]interface{}{
+		"type":                 []interface{}{"object", "null"},
+		"properties":           configProps,
+		"additionalProperties": false,
+	}
+	definitions["entry"] = map[string]interface{}{
+		"type": []interface{}{"object", "null"},
+		"properties": map[string]interface{}{
+			"labels": map[string]interface{}{
+				"type": []interface{}{"object", "null"},
+				// A label with no value is treated like '' by the chart.
+				"additionalProperties": map[string]interface{}{
+					"type": []interface{}{"string", "number", "boolean", "null"},
+				},
+			},
+			"config": map[string]interface{}{"$ref": "#/definitions/config"},
+		},
+		"additionalProperties": false,
+	}
+
+	props := map[string]interface{}{}
+	for _, bucket := range EntryBuckets {
+		props[bucket] = map[string]interface{}{
+			"type":                 []interface{}{"object", "null"},
+			"additionalProperties": map[string]interface{}{"$ref": "#/definitions/entry"},
+		}
+	}
+	return map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "AutoShift values",
+		"description": "Generated by tools/cmd/values-schema from the _example*.yaml files and .github/config-schema-refinements.yaml. Do not edit by hand.",
+		"type":        "object",
+		"properties":  props,
+		"definitions": definitions,
+	}
+}
+
+// Marshal renders a schema document as indented JSON with a trailing newline.
+// encoding/json sorts map keys, so the output is stable across runs.
+func Marshal(doc map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func containsString(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Build generates the chart schema for the repository at repoRoot from its
+// examples, refinements file and config key conventions.
+func Build(repoRoot string) (map[string]interface{}, error) {
+	ex, err := CollectExamples(filepath.Join(repoRoot, "autoshift", "values"))
+	if err != nil {
+		return nil, err
+	}
+	refinements, err := LoadRefinements(filepath.Join(repoRoot, RefinementsFile))
+	if err != nil {
+		return nil, err
+	}
+	conv, err := configkeys.LoadConventions(filepath.Join(repoRoot, ".github", "config-key-conventions.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	return ChartSchema(Definitions(ex, refinements, conv.MissingOK)), nil
+}
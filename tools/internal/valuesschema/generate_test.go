@@ -0,0 +1,107 @@
+package valuesschema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCollectExamples(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("_example.yaml", `
+hubClusterSets:
+  hub:
+    config:
+      gitops:
+        teams:
+          dev: {ha: true}
+`)
+	write("_example-aws.yaml", `
+clusters:
+  c1:
+    config:
+      gitops:
+        teams:
+          test: {ha: false}
+`)
+	write("hub.yaml", `
+hubClusterSets:
+  hub:
+    config:
+      notAnExample: {}
+`)
+
+	ex, err := CollectExamples(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ex.Schemas["notAnExample"]; ok {
+		t.Error("non-example profile was treated as a spec")
+	}
+	if want := []string{"_example-aws.yaml", "_example.yaml"}; !reflect.DeepEqual(ex.Files["gitops"], want) {
+		t.Errorf("Files[gitops] = %v, want %v", ex.Files["gitops"], want)
+	}
+
+	defs := Definitions(ex, map[string]map[string]interface{}{
+		"gitops": {"properties": map[string]interface{}{
+			"teams": map[string]interface{}{"x-keyed": true},
+		}},
+		"extra": {"type": "string"},
+	}, []string{"versionTag"})
+
+	teams := defs["gitops"]["properties"].(map[string]interface{})["teams"].(map[string]interface{})
+	if _, ok := teams["properties"]; ok {
+		t.Errorf("x-keyed map kept example team names: %v", teams)
+	}
+	if _, ok := teams["x-keyed"]; ok {
+		t.Error("x-keyed marker leaked into the schema")
+	}
+	if !reflect.DeepEqual(defs["extra"], map[string]interface{}{"type": "string"}) {
+		t.Errorf("refinement-only key = %v", defs["extra"])
+	}
+	if !reflect.DeepEqual(defs["versionTag"], map[string]interface{}{}) {
+		t.Errorf("open key = %v, want {}", defs["versionTag"])
+	}
+}
+
+func TestDeepMerge_NullRemoves(t *testing.T) {
+	dst := map[string]interface{}{
+		"required":   []interface{}{"a", "b"},
+		"properties": map[string]interface{}{"a": map[string]interface{}{"type": "string"}},
+	}
+	deepMerge(dst, map[string]interface{}{
+		"required":   nil,
+		"properties": map[string]interface{}{"a": map[string]interface{}{"enum": []interface{}{"x"}}},
+	})
+	want := map[string]interface{}{
+		"properties": map[string]interface{}{"a": map[string]interface{}{"type": "string", "enum": []interface{}{"x"}}},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("deepMerge = %v, want %v", dst, want)
+	}
+}
+
+func TestChartSchema_RejectsUnknownConfigKey(t *testing.T) {
+	doc := ChartSchema(map[string]map[string]interface{}{"acs": {"type": "object"}})
+	values := map[string]interface{}{
+		"managedClusterSets": map[string]interface{}{
+			"prod": map[string]interface{}{
+				"labels": map[string]interface{}{"acs": "true", "blank": nil},
+				"config": map[string]interface{}{"acs": map[string]interface{}{}, "acss": map[string]interface{}{}},
+			},
+		},
+		"autoshiftGitRepo": "https://example.com/repo.git",
+	}
+	got := Validate(doc, doc, values)
+	want := []string{`managedClusterSets.prod.config.acss: unknown field (did you mean "acs"?)`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate = %q, want %q", got, want)
+	}
+}
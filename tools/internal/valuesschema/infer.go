@@ -0,0 +1,206 @@
+// Package valuesschema derives JSON Schemas for AutoShift config sections from
+// the _example*.yaml files, which are the only specification of what each
+// policy accepts.
+//
+// Each top-level config key gets a schema inferred from every example that
+// declares it, merged with optional hand-written refinements. The same
+// definitions are assembled into autoshift/values.schema.json so `helm
+// template` rejects a values profile whose config does not match.
+package valuesschema
+
+import (
+	"sort"
+)
+
+// Schema is the subset of JSON Schema (draft-07) that inference produces.
+// Refinements are merged later on the generic map form, so they may use any
+// keyword; Validate understands the ones listed on it.
+type Schema struct {
+	Types      []string
+	Properties map[string]*Schema
+	Required   []string
+	Items      *Schema
+	// Values is the schema shared by every value of a map whose keys are
+	// chosen by the user (host names, team names), set by Keyed.
+	Values *Schema
+
+	// samples counts the example values merged into this schema. A field is
+	// only required when it appears in at least two samples: a single example
+	// shows what is possible, not what is mandatory.
+	samples int
+	// nullable records that some sample was null, which constrains nothing.
+	nullable bool
+}
+
+// Infer returns the schema of one example value as decoded by yaml.v3.
+func Infer(v interface{}) *Schema {
+	s := &Schema{samples: 1}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		s.Types = []string{"object"}
+		s.Properties = make(map[string]*Schema, len(t))
+		for k, child := range t {
+			s.Properties[k] = Infer(child)
+			s.Required = append(s.Required, k)
+		}
+		sort.Strings(s.Required)
+	case []interface{}:
+		s.Types = []string{"array"}
+		for _, item := range t {
+			if s.Items == nil {
+				s.Items = Infer(item)
+			} else {
+				s.Items = Merge(s.Items, Infer(item))
+			}
+		}
+	case string:
+		s.Types = []string{"string"}
+	case bool:
+		s.Types = []string{"boolean"}
+	case int, int64, uint64:
+		s.Types = []string{"integer"}
+	case float64:
+		s.Types = []string{"number"}
+	case nil:
+		s.nullable = true
+	}
+	return s
+}
+
+// Merge combines the schemas of two samples of the same field: types and
+// properties are unioned, required fields intersected.
+func Merge(a, b *Schema) *Schema {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	out := &Schema{
+		Types:    unionTypes(a.Types, b.Types),
+		samples:  a.samples + b.samples,
+		nullable: a.nullable || b.nullable,
+	}
+	if a.Properties != nil || b.Properties != nil {
+		out.Properties = map[string]*Schema{}
+		for k, v := range a.Properties {
+			out.Properties[k] = v
+		}
+		for k, v := range b.Properties {
+			out.Properties[k] = Merge(out.Properties[k], v)
+		}
+	}
+	// A null sample says nothing about which fields exist, so it does not
+	// narrow the required set.
+	switch {
+	case isNullSample(a):
+		out.Required = b.Required
+	case isNullSample(b):
+		out.Required = a.Required
+	default:
+		out.Required = intersect(a.Required, b.Required)
+	}
+	out.Items = Merge(a.Items, b.Items)
+	out.Values = Merge(a.Values, b.Values)
+	return out
+}
+
+// Keyed turns an object whose property names are data rather than fields into
+// a map: every property's schema is merged into Values and the names are
+// dropped, so any key is accepted as long as its value has the common shape.
+func (s *Schema) Keyed() {
+	names := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		s.Values = Merge(s.Values, s.Properties[k])
+	}
+	s.Properties = nil
+	s.Required = nil
+}
+
+// isNullSample reports whether s came only from null values.
+func isNullSample(s *Schema) bool {
+	return s.nullable && len(s.Types) == 0
+}
+
+// unionTypes merges two type lists. integer folds into number when both
+// appear, since JSON Schema's number already admits integers.
+func unionTypes(a, b []string) []string {
+	set := map[string]bool{}
+	for _, t := range a {
+		set[t] = true
+	}
+	for _, t := range b {
+		set[t] = true
+	}
+	if set["number"] {
+		delete(set, "integer")
+	}
+	out := make([]string, 0, len(set))
+	for t := range set {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func intersect(a, b []string) []string {
+	in := map[string]bool{}
+	for _, x := range b {
+		in[x] = true
+	}
+	var out []string
+	for _, x := range a {
+		if in[x] {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// ToMap renders s as a JSON Schema document. Objects with inferred properties
+// reject unknown keys, which is what turns a typo into a render failure; an
+// empty example map stays open because it shows no shape to enforce.
+func (s *Schema) ToMap() map[string]interface{} {
+	m := map[string]interface{}{}
+	types := s.Types
+	if s.nullable && len(types) > 0 {
+		types = append(append([]string{}, types...), "null")
+	}
+	switch len(types) {
+	case 0:
+	case 1:
+		m["type"] = types[0]
+	default:
+		list := make([]interface{}, len(types))
+		for i, t := range types {
+			list[i] = t
+		}
+		m["type"] = list
+	}
+	if len(s.Properties) > 0 {
+		props := make(map[string]interface{}, len(s.Properties))
+		for k, v := range s.Properties {
+			props[k] = v.ToMap()
+		}
+		m["properties"] = props
+		m["additionalProperties"] = false
+		if s.samples >= 2 && len(s.Required) > 0 {
+			req := make([]interface{}, len(s.Required))
+			for i, r := range s.Required {
+				req[i] = r
+			}
+			m["required"] = req
+		}
+	}
+	if s.Values != nil {
+		m["additionalProperties"] = s.Values.ToMap()
+	}
+	if s.Items != nil {
+		m["items"] = s.Items.ToMap()
+	}
+	return m
+}
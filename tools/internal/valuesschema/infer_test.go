@@ -0,0 +1,60 @@
+package valuesschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInfer_Merge(t *testing.T) {
+	a := Infer(map[string]interface{}{"name": "x", "port": 443, "tls": true})
+	b := Infer(map[string]interface{}{"name": "y", "port": 8.5})
+
+	got := Merge(a, b).ToMap()
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"port": map[string]interface{}{"type": "number"},
+			"tls":  map[string]interface{}{"type": "boolean"},
+		},
+		"additionalProperties": false,
+		"required":             []interface{}{"name", "port"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToMap = %#v\nwant %#v", got, want)
+	}
+}
+
+func TestInfer_SingleSampleRequiresNothing(t *testing.T) {
+	got := Infer(map[string]interface{}{"name": "x"}).ToMap()
+	if _, ok := got["required"]; ok {
+		t.Errorf("one example must not make its fields required: %v", got)
+	}
+}
+
+func TestInfer_NullSample(t *testing.T) {
+	s := Merge(Infer(nil), Merge(Infer(map[string]interface{}{"a": 1}), Infer(map[string]interface{}{"a": 2})))
+	got := s.ToMap()
+	if !reflect.DeepEqual(got["type"], []interface{}{"object", "null"}) {
+		t.Errorf("type = %v, want [object null]", got["type"])
+	}
+	if !reflect.DeepEqual(got["required"], []interface{}{"a"}) {
+		t.Errorf("a null sample narrowed required: %v", got["required"])
+	}
+}
+
+func TestKeyed(t *testing.T) {
+	s := Infer(map[string]interface{}{
+		"master-0": map[string]interface{}{"role": "master", "bmcIP": "10.0.0.1"},
+		"master-1": map[string]interface{}{"role": "master"},
+	})
+	s.Keyed()
+	got := s.ToMap()
+	if _, ok := got["properties"]; ok {
+		t.Errorf("keyed map kept its example names: %v", got)
+	}
+	values, _ := got["additionalProperties"].(map[string]interface{})
+	if !reflect.DeepEqual(values["required"], []interface{}{"role"}) {
+		t.Errorf("value required = %v, want [role]", values["required"])
+	}
+}
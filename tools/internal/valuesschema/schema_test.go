@@ -0,0 +1,83 @@
+//go:build integration
+
+package valuesschema
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// repoRoot walks up from the package directory to the repository root.
+func repoRoot(t *testing.T) string {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		if _, err := os.Stat(filepath.Join(dir, "policies")); err == nil {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+	t.Fatal("could not locate repository root")
+	return ""
+}
+
+// TestChartSchema_UpToDate fails when autoshift/values.schema.json no longer
+// matches what the examples and refinements generate, i.e. someone changed a
+// config section without regenerating the schema.
+func TestChartSchema_UpToDate(t *testing.T) {
+	root := repoRoot(t)
+	doc, err := Build(root)
+	if err != nil {
+		t.Fatalf("building schema: %v", err)
+	}
+	want, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshalling schema: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(root, ChartSchemaFile))
+	if err != nil {
+		t.Fatalf("reading %s: %v", ChartSchemaFile, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date — run `go run ./cmd/values-schema` from tools/", ChartSchemaFile)
+	}
+}
+
+// TestChartSchema_Profiles validates every values file in the repo, examples
+// and real profiles alike, against the generated schema. A failure is either a
+// typo in the profile or a refinement the schema is missing.
+func TestChartSchema_Profiles(t *testing.T) {
+	root := repoRoot(t)
+	doc, err := Build(root)
+	if err != nil {
+		t.Fatalf("building schema: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(root, "autoshift", "values", "*", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		rel, _ := filepath.Rel(root, f)
+		t.Run(rel, func(t *testing.T) {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values map[string]interface{}
+			if err := yaml.Unmarshal(data, &values); err != nil {
+				t.Fatalf("parsing: %v", err)
+			}
+			if errs := Validate(doc, doc, values); len(errs) > 0 {
+				t.Errorf("%d schema violations:\n  %s", len(errs), strings.Join(errs, "\n  "))
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package valuesschema
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Validate checks value against schema (the generic map form) and returns one
+// message per violation, each prefixed with the dotted path of the offending
+// value. root resolves local "#/definitions/<name>" references; pass schema
+// itself when it is the whole document.
+//
+// It implements the keywords the generator and the refinements file use:
+// $ref, type, enum, properties, required, additionalProperties, items,
+// pattern, maxLength and minimum. Helm applies the full draft-07 validator at
+// render time; this one exists so the same schema can be checked in Go against
+// every values profile without shelling out.
+func Validate(schema, root map[string]interface{}, value interface{}) []string {
+	var errs []string
+	for _, v := range Violations(schema, root, value) {
+		errs = append(errs, v.String())
+	}
+	return errs
+}
+
+// Violation kinds.
+const (
+	KindType     = "type"
+	KindEnum     = "enum"
+	KindRequired = "required"
+	KindUnknown  = "unknown"
+	KindValue    = "value" // pattern, maxLength or minimum
+	KindRef      = "ref"
+)
+
+// A Violation is one place where a value does not match the schema.
+type Violation struct {
+	// Path is the location of the offending value, one element per map key
+	// or "[i]" sequence index, so keys containing dots stay unambiguous.
+	Path []string
+	Kind string
+	// Got and Want are the JSON types involved in a KindType violation.
+	Got, Want string
+	Message   string
+}
+
+// String renders the violation as "dotted.path: message".
+func (v Violation) String() string {
+	return displayPath(DottedPath(v.Path)) + ": " + v.Message
+}
+
+// DottedPath joins a violation path as a.b[1].c.
+func DottedPath(path []string) string {
+	var b strings.Builder
+	for _, p := range path {
+		if b.Len() > 0 && !strings.HasPrefix(p, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// Violations is Validate with the violations left structured, for callers
+// that map them back to source positions.
+func Violations(schema, root map[string]interface{}, value interface{}) []Violation {
+	var vs []Violation
+	validate(schema, root, value, nil, &vs)
+	return vs
+}
+
+func validate(schema, root map[string]interface{}, value interface{}, path []string, vs *[]Violation) {
+	add := func(kind, msg string) {
+		*vs = append(*vs, Violation{Path: path, Kind: kind, Message: msg})
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := resolveRef(root, ref)
+		if err != nil {
+			add(KindRef, err.Error())
+			return
+		}
+		validate(target, root, value, path, vs)
+		return
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		got, want := jsonType(value), typeString(t)
+		*vs = append(*vs, Violation{Path: path, Kind: KindType, Got: got, Want: want, Message: fmt.Sprintf("got %s, want %s", got, want)})
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(enum, value) {
+		add(KindEnum, fmt.Sprintf("%v is not one of %v", value, enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, root, v, path, vs)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validate(items, root, item, childPath(path, fmt.Sprintf("[%d]", i)), vs)
+			}
+		}
+	case string:
+		if p, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(p); err == nil && !re.MatchString(v) {
+				add(KindValue, fmt.Sprintf("%q does not match %s", v, p))
+			}
+		}
+		if max, ok := toFloat(schema["maxLength"]); ok && float64(len(v)) > max {
+			add(KindValue, fmt.Sprintf("%q is %d chars, max %v", v, len(v), max))
+		}
+	}
+	if min, ok := toFloat(schema["minimum"]); ok {
+		if n, isNum := toFloat(value); isNum && n < min {
+			add(KindValue, fmt.Sprintf("%v is below the minimum %v", value, min))
+		}
+	}
+}
+
+func validateObject(schema, root map[string]interface{}, obj map[string]interface{}, path []string, vs *[]Violation) {
+	props, _ := schema["properties"].(map[string]interface{})
+
+	if req, ok := schema["required"].([]interface{}); ok {
+		for _, r := range req {
+			name, _ := r.(string)
+			if _, present := obj[name]; !present {
+				*vs = append(*vs, Violation{Path: path, Kind: KindRequired, Message: fmt.Sprintf("missing required field %q", name)})
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := childPath(path, k)
+		if ps, ok := props[k].(map[string]interface{}); ok {
+			validate(ps, root, obj[k], child, vs)
+			continue
+		}
+		switch ap := schema["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				*vs = append(*vs, Violation{Path: child, Kind: KindUnknown, Message: "unknown field" + suggestion(k, props)})
+			}
+		case map[string]interface{}:
+			validate(ap, root, obj[k], child, vs)
+		}
+	}
+}
+
+// suggestion names the closest known field when an unknown one looks like a
+// typo of it.
+func suggestion(key string, props map[string]interface{}) string {
+	names := make([]string, 0, len(props))
+	for p := range props {
+		names = append(names, p)
+	}
+	if best := Closest(key, names); best != "" {
+		return fmt.Sprintf(" (did you mean %q?)", best)
+	}
+	return ""
+}
+
+// Closest returns the candidate within edit distance 2 of key, ignoring case,
+// or "" when none is that close. Ties go to the alphabetically first.
+func Closest(key string, candidates []string) string {
+	sorted := append([]string(nil), candidates...)
+	sort.Strings(sorted)
+	best, bestDist := "", 3
+	for _, c := range sorted {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func resolveRef(root map[string]interface{}, ref string) (map[string]interface{}, error) {
+	const prefix = "#/definitions/"
+	if !strings.HasPrefix(ref, prefix) {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	defs, _ := root["definitions"].(map[string]interface{})
+	target, ok := defs[strings.TrimPrefix(ref, prefix)].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unresolved $ref %q", ref)
+	}
+	return target, nil
+}
+
+func matchesType(t interface{}, value interface{}) bool {
+	switch tt := t.(type) {
+	case string:
+		return matchesOne(tt, value)
+	case []interface{}:
+		for _, x := range tt {
+			if s, ok := x.(string); ok && matchesOne(s, value) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, s := range tt {
+			if matchesOne(s, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesOne(t string, value interface{}) bool {
+	actual := jsonType(value)
+	switch t {
+	case "number":
+		return actual == "integer" || actual == "number"
+	default:
+		return actual == t
+	}
+}
+
+// jsonType names the JSON Schema type of a value decoded by yaml.v3 or
+// encoding/json.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func typeString(t interface{}) string {
+	if list, ok := t.([]interface{}); ok {
+		parts := make([]string, len(list))
+		for i, x := range list {
+			parts[i] = fmt.Sprint(x)
+		}
+		return strings.Join(parts, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// childPath appends one element to a path without sharing the parent's
+// backing array, since sibling paths are built from the same parent.
+func childPath(parent []string, elem string) []string {
+	return append(parent[:len(parent):len(parent)], elem)
+}
+
+func displayPath(p string) string {
+	if p == "" {
+		return "(root)"
+	}
+	return p
+}
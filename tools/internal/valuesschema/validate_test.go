@@ -0,0 +1,77 @@
+package valuesschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	root := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"acs": map[string]interface{}{"$ref": "#/definitions/acs"},
+		},
+		"additionalProperties": false,
+		"definitions": map[string]interface{}{
+			"acs": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"auth":    map[string]interface{}{"type": "object"},
+					"mode":    map[string]interface{}{"enum": []interface{}{"a", "b"}},
+					"port":    map[string]interface{}{"type": "number", "minimum": 1},
+					"name":    map[string]interface{}{"type": "string", "maxLength": 3, "pattern": "^[a-z]+$"},
+					"servers": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+				"required":             []interface{}{"auth"},
+				"additionalProperties": false,
+			},
+		},
+	}
+
+	cases := []struct {
+		name  string
+		value interface{}
+		want  []string
+	}{
+		{
+			name:  "valid",
+			value: map[string]interface{}{"acs": map[string]interface{}{"auth": map[string]interface{}{}, "port": 443}},
+			want:  nil,
+		},
+		{
+			name:  "typo suggests the known field",
+			value: map[string]interface{}{"acs": map[string]interface{}{"auth": map[string]interface{}{}, "prot": 443}},
+			want:  []string{`acs.prot: unknown field (did you mean "port"?)`},
+		},
+		{
+			name:  "missing required and wrong type",
+			value: map[string]interface{}{"acs": map[string]interface{}{"port": "443"}},
+			want:  []string{`acs: missing required field "auth"`, "acs.port: got string, want number"},
+		},
+		{
+			name: "enum, minimum, string limits and items",
+			value: map[string]interface{}{"acs": map[string]interface{}{
+				"auth":    map[string]interface{}{},
+				"mode":    "c",
+				"port":    0,
+				"name":    "ABCD",
+				"servers": []interface{}{"a", 1},
+			}},
+			want: []string{
+				"acs.mode: c is not one of [a b]",
+				`acs.name: "ABCD" does not match ^[a-z]+$`,
+				`acs.name: "ABCD" is 4 chars, max 3`,
+				"acs.port: 0 is below the minimum 1",
+				"acs.servers[1]: got integer, want string",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Validate(root, root, tc.value)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Validate =\n  %q\nwant\n  %q", got, tc.want)
+			}
+		})
+	}
+}